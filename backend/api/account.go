@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
 	"simple_bank/constants"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
 	"simple_bank/pkg/token"
+	"strconv"
 
 	"github.com/jackc/pgx/v5/pgconn"
 
@@ -23,24 +26,40 @@ func (s *Server) createAccount(ctx *gin.Context) {
 	}
 	var req createAccountRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
 		return
 	}
 
 	authPayload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
-	fmt.Println("authPayload", authPayload)
+	// 验证邮箱之后才能创建账户
+	if !s.requireVerifiedEmail(ctx, authPayload.Username) {
+		return
+	}
+	// 客户只能为自己创建账户, 柜员与管理员可以为其他用户创建账户
+	if !middleware.CanAccessOwner(ctx, req.Owner) {
+		ctx.JSON(http.StatusForbidden, gin.H{
+			"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgAccountNotOwned),
+		})
+		return
+	}
 	arg := db.CreateAccountParams{
-		Owner:    authPayload.Username,
+		Owner:    req.Owner,
 		Balance:  0,
 		Currency: req.Currency,
 	}
-	account, err := s.store.CreateAccount(ctx, arg)
+	// 账户编号在创建之后才知道
+	var account db.Accounts
+	event := newAuditEvent(ctx, authPayload.Username, auditAccountCreate, auditResourceAccount, "")
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		var err error
+		account, err = q.CreateAccount(ctx, arg)
+		event.ResourceID = strconv.FormatInt(account.ID, 10)
+		event.After = account
+		return err
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
-			fmt.Printf("postgres sql err message is '%s' \n", pgErr.Message)
-			fmt.Printf("postgres sql err code is '%s' \n", pgErr.Code)
-
 			switch pgErr.Code {
 			case "23503":
 				ctx.JSON(http.StatusForbidden, gin.H{
@@ -69,7 +88,7 @@ func (s *Server) getAccount(ctx *gin.Context) {
 	var req getAccountRequest
 	// 绑定id到结构体
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
 		return
 	}
 	account, err := s.store.GetAccount(ctx, req.ID)
@@ -83,10 +102,9 @@ func (s *Server) getAccount(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
-	if account.Owner != payload.Username {
+	if !middleware.CanAccessOwner(ctx, account.Owner) {
 		ctx.JSON(http.StatusForbidden, gin.H{
-			"error": errors.New("该账户不属于该用户").Error(),
+			"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgAccountNotOwned),
 		})
 		return
 	}
@@ -99,6 +117,8 @@ func (s *Server) listAccount(ctx *gin.Context) {
 	type listAccountRequest struct {
 		PageID   uint32 `form:"page_id" binding:"required,gte=1"`
 		PageSize uint32 `form:"page_size" binding:"required,gte=5,lte=20"`
+		// 柜员与审计可以查询其他用户的账户, 默认为登录的用户
+		Owner string `form:"owner"`
 	}
 	var req listAccountRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
@@ -106,13 +126,23 @@ func (s *Server) listAccount(ctx *gin.Context) {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
 			return
 		}
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
 		return
 	}
 	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	owner := payload.Username
+	if req.Owner != "" {
+		if !middleware.CanAccessOwner(ctx, req.Owner) {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgAccountNotOwned),
+			})
+			return
+		}
+		owner = req.Owner
+	}
 
 	arg := db.ListAccountsParams{
-		Owner:  payload.Username,
+		Owner:  owner,
 		Limit:  int64(req.PageSize),
 		Offset: int64((req.PageID - 1) * req.PageSize),
 	}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"simple_bank/constants"
+	"simple_bank/pkg/rbac"
 	"simple_bank/pkg/token"
 	"testing"
 	"time"
@@ -45,7 +46,7 @@ func TestAccountAPI(t *testing.T) {
 			name:      "OK",
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, username, time.Minute)
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, username, rbac.RoleCustomer, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -61,7 +62,7 @@ func TestAccountAPI(t *testing.T) {
 			name:      "令牌的username与用户的username不匹配",
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "Unauthorized username", time.Minute)
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "Unauthorized username", rbac.RoleCustomer, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -70,7 +71,23 @@ func TestAccountAPI(t *testing.T) {
 					Return(account, nil)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		}, {
+			name:      "柜员查询其他用户的账户",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "teller", rbac.RoleTeller, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
 			},
 		}, {
 			name:      "未授权",
@@ -90,7 +107,7 @@ func TestAccountAPI(t *testing.T) {
 			name:      "NotFoundID",
 			accountID: int64(-1),
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, username, time.Minute)
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, username, rbac.RoleCustomer, time.Minute)
 			},
 
 			buildStubs: func(store *mockdb.MockStore) {
@@ -113,7 +130,9 @@ func TestAccountAPI(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
 			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
 
 			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/token"
+)
+
+// 解除用户名的登录锁定, 并清除该用户名的失败记录
+func (s *Server) unlockUserLogin(ctx *gin.Context) {
+	username := ctx.Param("username")
+	if err := s.auditUnlock(ctx, lockout.UserSubject(username), username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// 解除客户端IP的登录锁定, 并清除该IP的失败记录
+func (s *Server) unlockIPLogin(ctx *gin.Context) {
+	ip := net.ParseIP(ctx.Param("ip"))
+	if ip == nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("invalid ip address: %q", ctx.Param("ip"))))
+		return
+	}
+
+	if err := s.auditUnlock(ctx, lockout.IPSubject(ip.String()), ip.String()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// auditUnlock 解除登录锁定, 并在同一个事务中记录审计事件
+func (s *Server) auditUnlock(ctx *gin.Context, subject string, resourceID string) error {
+	event := newAuditEvent(ctx, "", auditLoginUnlock, auditResourceLogin, resourceID)
+	return s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		return s.lockout.WithQuerier(q).Unlock(ctx, subject)
+	})
+}
+
+// 修改用户的角色, 同时禁用该用户的所有会话与令牌, 重新登录之后新的角色生效
+func (s *Server) updateUserRole(ctx *gin.Context) {
+	type updateUserRoleRequest struct {
+		Role string `json:"role" binding:"required,role"`
+	}
+
+	var req updateUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	var user db.Users
+	username := ctx.Param("username")
+	event := newAuditEvent(ctx, "", auditUserRoleUpdate, auditResourceUser, username)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		before, err := q.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+		event.Before = newUserAuditSnapshot(before)
+
+		user, err = q.UpdateUserRole(ctx, db.UpdateUserRoleParams{
+			Username: username,
+			Role:     req.Role,
+		})
+		if err != nil {
+			return err
+		}
+		event.After = newUserAuditSnapshot(user)
+		return q.BlockUserSessions(ctx, user.Username)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err = s.revocations.RevokeAll(ctx, user.Username, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// 后台分页查询的参数
+type pageRequest struct {
+	PageID   uint32 `form:"page_id" binding:"required,gte=1"`
+	PageSize uint32 `form:"page_size" binding:"required,gte=5,lte=20"`
+}
+
+func (req pageRequest) limit() int64 {
+	return int64(req.PageSize)
+}
+
+func (req pageRequest) offset() int64 {
+	return int64((req.PageID - 1) * req.PageSize)
+}
+
+// 按用户名, 姓名或邮箱搜索用户
+func (s *Server) searchUsers(ctx *gin.Context) {
+	type searchUsersRequest struct {
+		Query string `form:"q"`
+		pageRequest
+	}
+
+	var req searchUsersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	users, err := s.store.SearchUsers(ctx, db.SearchUsersParams{
+		Query:  req.Query,
+		Limit:  req.limit(),
+		Offset: req.offset(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]userResponse, 0, len(users))
+	for _, user := range users {
+		rsp = append(rsp, newUserResponse(user))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type accountIDRequest struct {
+	ID int64 `uri:"id" binding:"required,gte=1"`
+}
+
+// getAdminAccount 按uri中的账户ID查询账户, 不存在时返回404
+func (s *Server) getAdminAccount(ctx *gin.Context) (db.Accounts, bool) {
+	var req accountIDRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return db.Accounts{}, false
+	}
+
+	account, err := s.store.GetAccount(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return db.Accounts{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.Accounts{}, false
+	}
+	return account, true
+}
+
+// requireOpenAccount 已关闭的账户不能解冻或调整余额, 关闭时返回409
+func requireOpenAccount(ctx *gin.Context, account db.Accounts) bool {
+	if account.ClosedAt != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgAccountClosed, account.ID)})
+		return false
+	}
+	return true
+}
+
+// 查询任意用户的账户与该账户的条目
+func (s *Server) getAccountWithEntries(ctx *gin.Context) {
+	var req pageRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	account, ok := s.getAdminAccount(ctx)
+	if !ok {
+		return
+	}
+
+	entries, err := s.store.ListEntry(ctx, db.ListEntryParams{
+		AccountID: account.ID,
+		Limit:     req.limit(),
+		Offset:    req.offset(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"account": account,
+		"entries": entries,
+	})
+}
+
+// 冻结账户, 冻结之后该账户不能转入或转出金额
+func (s *Server) freezeAccount(ctx *gin.Context) {
+	s.setAccountFrozen(ctx, true)
+}
+
+// 解冻账户
+func (s *Server) unfreezeAccount(ctx *gin.Context) {
+	s.setAccountFrozen(ctx, false)
+}
+
+func (s *Server) setAccountFrozen(ctx *gin.Context, frozen bool) {
+	account, ok := s.getAdminAccount(ctx)
+	if !ok || !requireOpenAccount(ctx, account) {
+		return
+	}
+
+	action := auditAccountUnfreeze
+	if frozen {
+		action = auditAccountFreeze
+	}
+	event := newAuditEvent(ctx, "", action, auditResourceAccount, strconv.FormatInt(account.ID, 10))
+	event.Before = account
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		var err error
+		account, err = q.SetAccountFrozen(ctx, db.SetAccountFrozenParams{
+			ID:       account.ID,
+			IsFrozen: frozen,
+		})
+		event.After = account
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, account)
+}
+
+// 修正账户余额, 写入条目并记录调整的原因与操作人, 不直接修改余额
+func (s *Server) adjustAccountBalance(ctx *gin.Context) {
+	type adjustAccountBalanceRequest struct {
+		Amount int64  `json:"amount" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+
+	var req adjustAccountBalanceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	account, ok := s.getAdminAccount(ctx)
+	if !ok || !requireOpenAccount(ctx, account) {
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	var result db.AdjustBalanceTxResult
+	event := newAuditEvent(ctx, payload.Username, auditBalanceAdjust, auditResourceAccount, strconv.FormatInt(account.ID, 10))
+	event.Before = account
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		result, err = s.store.AdjustBalanceTx(ctx, db.AdjustBalanceTxParams{
+			AccountID: account.ID,
+			Amount:    req.Amount,
+			Reason:    req.Reason,
+			CreatedBy: payload.Username,
+		})
+		event.After = result
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrNegativeBalance) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgNegativeBalance)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// 查询账户的余额调整记录
+func (s *Server) listAccountAdjustments(ctx *gin.Context) {
+	var req pageRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	account, ok := s.getAdminAccount(ctx)
+	if !ok {
+		return
+	}
+
+	adjustments, err := s.store.ListAccountAdjustments(ctx, db.ListAccountAdjustmentsParams{
+		AccountID: account.ID,
+		Limit:     req.limit(),
+		Offset:    req.offset(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, adjustments)
+}
+
+// 按时间倒序列出全行最近的转账记录
+func (s *Server) listRecentTransfers(ctx *gin.Context) {
+	var req pageRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	transfers, err := s.store.ListRecentTransfers(ctx, db.ListRecentTransfersParams{
+		Limit:  req.limit(),
+		Offset: req.offset(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, transfers)
+}
@@ -0,0 +1,447 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/rbac"
+)
+
+func TestUnlockLoginAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		url           string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "解除用户名锁定",
+			url:  "/admin/users/" + user.Username + "/unlock",
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "解除IP锁定",
+			url:  "/admin/ips/10.0.0.1/unlock",
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.IPSubject("10.0.0.1"))).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "IP无效",
+			url:  "/admin/ips/not-an-ip/unlock",
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "非管理员",
+			url:  "/admin/users/" + user.Username + "/unlock",
+			role: rbac.RoleCustomer,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, tc.url, nil)
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUpdateUserRoleAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			role: rbac.RoleAdmin,
+			body: gin.H{"role": rbac.RoleTeller},
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.Role = rbac.RoleTeller
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					UpdateUserRole(gomock.Any(), gomock.Eq(db.UpdateUserRoleParams{Username: user.Username, Role: rbac.RoleTeller})).
+					Times(1).
+					Return(updated, nil)
+				// 修改角色之后该用户需要重新登录
+				store.EXPECT().BlockUserSessions(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(nil)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, rbac.RoleTeller, rsp.Role)
+			},
+		},
+		{
+			name: "角色无效",
+			role: rbac.RoleAdmin,
+			body: gin.H{"role": "root"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserRole(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "用户不存在",
+			role: rbac.RoleAdmin,
+			body: gin.H{"role": rbac.RoleAuditor},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(1).Return(db.Users{}, sql.ErrNoRows)
+				store.EXPECT().UpdateUserRole(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().BlockUserSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "柜员不能修改角色",
+			role: rbac.RoleTeller,
+			body: gin.H{"role": rbac.RoleAdmin},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().UpdateUserRole(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, "/admin/users/"+user.Username+"/role", bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestFreezeAccountAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	account := randomAccount(t, admin.Username)
+
+	testCases := []struct {
+		name          string
+		url           string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "冻结账户",
+			url:  fmt.Sprintf("/admin/accounts/%d/freeze", account.ID),
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				frozen := account
+				frozen.IsFrozen = true
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Eq(db.SetAccountFrozenParams{ID: account.ID, IsFrozen: true})).
+					Times(1).
+					Return(frozen, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var got db.Accounts
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.True(t, got.IsFrozen)
+			},
+		},
+		{
+			name: "解冻账户",
+			url:  fmt.Sprintf("/admin/accounts/%d/unfreeze", account.ID),
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Eq(db.SetAccountFrozenParams{ID: account.ID, IsFrozen: false})).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "已关闭的账户不能解冻",
+			url:  fmt.Sprintf("/admin/accounts/%d/unfreeze", account.ID),
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				closedAt := time.Now()
+				closed := account
+				closed.IsFrozen = true
+				closed.ClosedAt = &closedAt
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(closed, nil)
+				store.EXPECT().SetAccountFrozen(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "账户不存在",
+			url:  fmt.Sprintf("/admin/accounts/%d/freeze", account.ID),
+			role: rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(db.Accounts{}, sql.ErrNoRows)
+				store.EXPECT().SetAccountFrozen(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "柜员不能冻结账户",
+			url:  fmt.Sprintf("/admin/accounts/%d/freeze", account.ID),
+			role: rbac.RoleTeller,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().SetAccountFrozen(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, tc.url, nil)
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAdjustAccountBalanceAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	account := randomAccount(t, admin.Username)
+
+	testCases := []struct {
+		name          string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			role: rbac.RoleAdmin,
+			body: gin.H{"amount": -10, "reason": "冲正重复入账"},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.AdjustBalanceTxParams{
+					AccountID: account.ID,
+					Amount:    -10,
+					Reason:    "冲正重复入账",
+					CreatedBy: admin.Username,
+				}
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					AdjustBalanceTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.AdjustBalanceTxResult{Account: account}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "余额不足",
+			role: rbac.RoleAdmin,
+			body: gin.H{"amount": -account.Balance - 1, "reason": "冲正"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().
+					AdjustBalanceTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AdjustBalanceTxResult{}, db.ErrNegativeBalance)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "缺少原因",
+			role: rbac.RoleAdmin,
+			body: gin.H{"amount": 10},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "审计不能修正余额",
+			role: rbac.RoleAuditor,
+			body: gin.H{"amount": 10, "reason": "补记"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().AdjustBalanceTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/accounts/%d/adjustments", account.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestSearchUsersAPI(t *testing.T) {
+	admin, _ := randomUser(t)
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		SearchUsers(gomock.Any(), gomock.Eq(db.SearchUsersParams{Query: user.Username, Limit: 5, Offset: 5})).
+		Times(1).
+		Return([]db.Users{user}, nil)
+	stubTokenNotRevoked(store)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	url := fmt.Sprintf("/admin/users?q=%s&page_id=2&page_size=5", user.Username)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, rbac.RoleAdmin, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var got []userResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	require.Equal(t, user.Username, got[0].Username)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg/apikey"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+type apiKeyResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// newAPIKeyResponse 不返回密钥的散列值
+func newAPIKeyResponse(apiKey db.ApiKeys) apiKeyResponse {
+	return apiKeyResponse{
+		ID:          apiKey.ID,
+		Name:        apiKey.Name,
+		Prefix:      apiKey.Prefix,
+		Permissions: apiKey.Permissions,
+		ExpiresAt:   apiKey.ExpiresAt,
+		LastUsedAt:  apiKey.LastUsedAt,
+		CreatedAt:   apiKey.CreatedAt,
+	}
+}
+
+type createAPIKeyResponse struct {
+	// 密钥的明文只在创建时返回这一次
+	Key    string         `json:"key"`
+	APIKey apiKeyResponse `json:"apiKey"`
+}
+
+// 创建API密钥, 授予的权限不能超出用户当前角色拥有的权限
+func (s *Server) createAPIKey(ctx *gin.Context) {
+	type createAPIKeyRequest struct {
+		Name        string     `json:"name" binding:"required"`
+		Permissions []string   `json:"permissions" binding:"required,min=1,dive,permission"`
+		ExpiresAt   *time.Time `json:"expiresAt" binding:"omitempty,gt"`
+	}
+
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	slices.Sort(req.Permissions)
+	permissions := slices.Compact(req.Permissions)
+	for _, permission := range permissions {
+		if rbac.ScopeOf(payload.Role, rbac.Permission(permission)) == rbac.ScopeNone {
+			ctx.JSON(http.StatusForbidden, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgAPIKeyNotGranted, permission)})
+			return
+		}
+	}
+
+	key, err := apikey.Generate()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var apiKey db.ApiKeys
+	id := uuid.New()
+	event := newAuditEvent(ctx, payload.Username, auditAPIKeyCreate, auditResourceAPIKey, id.String())
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		var err error
+		apiKey, err = q.CreateAPIKey(ctx, db.CreateAPIKeyParams{
+			ID:          id,
+			Username:    payload.Username,
+			Name:        req.Name,
+			Prefix:      key.Prefix,
+			HashedKey:   key.Hashed,
+			Permissions: permissions,
+			ExpiresAt:   req.ExpiresAt,
+		})
+		event.After = newAPIKeyResponse(apiKey)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, createAPIKeyResponse{
+		Key:    key.Plaintext,
+		APIKey: newAPIKeyResponse(apiKey),
+	})
+}
+
+// 列出当前用户的API密钥
+func (s *Server) listAPIKeys(ctx *gin.Context) {
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	apiKeys, err := s.store.ListAPIKeys(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		rsp = append(rsp, newAPIKeyResponse(apiKey))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// 删除API密钥, 删除之后该密钥立即失效, 只能删除自己的密钥
+func (s *Server) deleteAPIKey(ctx *gin.Context) {
+	type deleteAPIKeyRequest struct {
+		ID string `uri:"id" binding:"required,uuid"`
+	}
+
+	var req deleteAPIKeyRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	event := newAuditEvent(ctx, payload.Username, auditAPIKeyDelete, auditResourceAPIKey, req.ID)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		apiKey, err := q.DeleteAPIKey(ctx, db.DeleteAPIKeyParams{
+			ID:       uuid.MustParse(req.ID),
+			Username: payload.Username,
+		})
+		event.Before = newAPIKeyResponse(apiKey)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/apikey"
+	"simple_bank/pkg/rbac"
+)
+
+func TestCreateAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		role          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			role: rbac.RoleCustomer,
+			body: gin.H{
+				"name":        "nightly-report",
+				"permissions": []string{string(rbac.AccountsRead), string(rbac.AccountsRead)},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAPIKeyParams) (db.ApiKeys, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, []string{string(rbac.AccountsRead)}, arg.Permissions)
+						require.Nil(t, arg.ExpiresAt)
+						return db.ApiKeys{
+							ID:          arg.ID,
+							Username:    arg.Username,
+							Name:        arg.Name,
+							Prefix:      arg.Prefix,
+							HashedKey:   arg.HashedKey,
+							Permissions: arg.Permissions,
+							CreatedAt:   time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var rsp createAPIKeyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, len(rsp.Key) > len(rsp.APIKey.Prefix))
+				require.Equal(t, rsp.APIKey.Prefix, rsp.Key[:len(rsp.APIKey.Prefix)])
+				require.NotContains(t, recorder.Body.String(), pkg.HashSecret(rsp.Key))
+			},
+		},
+		{
+			name: "超出角色的权限",
+			role: rbac.RoleCustomer,
+			body: gin.H{
+				"name":        "unlock-bot",
+				"permissions": []string{string(rbac.LoginUnlock)},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "未知的权限",
+			role: rbac.RoleAdmin,
+			body: gin.H{
+				"name":        "bad",
+				"permissions": []string{"accounts:delete"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "过期时间已过去",
+			role: rbac.RoleCustomer,
+			body: gin.H{
+				"name":        "expired",
+				"permissions": []string{string(rbac.AccountsRead)},
+				"expiresAt":   time.Now().Add(-time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/api-keys", bytes.NewReader(data))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteAPIKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	id := uuid.New()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteAPIKey(gomock.Any(), gomock.Eq(db.DeleteAPIKeyParams{ID: id, Username: user.Username})).
+					Times(1).
+					Return(db.ApiKeys{ID: id, Username: user.Username}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "不存在或不属于该用户",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKeys{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/users/api-keys/"+id.String(), nil)
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	account := randomAccount(t, user.Username)
+	key, err := apikey.Generate()
+	require.NoError(t, err)
+
+	stubAPIKey := func(store *mockdb.MockStore, apiKey db.ApiKeys) {
+		store.EXPECT().
+			GetAPIKeyByHash(gomock.Any(), gomock.Eq(key.Hashed)).
+			Times(1).
+			Return(apiKey, nil)
+		store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+		store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	}
+	newAPIKey := func(permissions ...rbac.Permission) db.ApiKeys {
+		apiKey := db.ApiKeys{
+			ID:        uuid.New(),
+			Username:  user.Username,
+			Prefix:    key.Prefix,
+			HashedKey: key.Hashed,
+			CreatedAt: time.Now(),
+		}
+		for _, permission := range permissions {
+			apiKey.Permissions = append(apiKey.Permissions, string(permission))
+		}
+		return apiKey
+	}
+
+	testCases := []struct {
+		name          string
+		url           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			url:  fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				stubAPIKey(store, newAPIKey(rbac.AccountsRead))
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name: "密钥没有该权限",
+			url:  fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				stubAPIKey(store, newAPIKey(rbac.TransfersCreate))
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "角色没有该权限",
+			url:  "/admin/transfers?page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				stubAPIKey(store, newAPIKey(rbac.TransfersRead))
+				store.EXPECT().ListRecentTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "密钥已过期",
+			url:  fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				apiKey := newAPIKey(rbac.AccountsRead)
+				expiresAt := time.Now().Add(-time.Minute)
+				apiKey.ExpiresAt = &expiresAt
+				stubAPIKey(store, apiKey)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "密钥不存在",
+			url:  fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKeys{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "凭证管理接口不接受密钥",
+			url:  "/users/api-keys",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListAPIKeys(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+			request.Header.Set(constants.AuthorizationHeaderKey, "ApiKey "+key.Plaintext)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
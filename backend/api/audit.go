@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg/token"
+)
+
+// 审计事件的操作
+const (
+	auditUserCreate           = "user.create"
+	auditLoginSucceeded       = "user.login"
+	auditLoginFailed          = "user.login_failed"
+	auditStepUpFailed         = "user.step_up_failed"
+	auditPasswordChangeFailed = "user.password_change_failed"
+	auditTotpDisableFailed    = "user.totp_disable_failed"
+	auditUserDeleteFailed     = "user.delete_failed"
+	auditEmailChangeFailed    = "user.email_change_failed"
+	auditPasswordChange       = "user.password_change"
+	auditPasswordReset        = "user.password_reset"
+	auditUserDelete           = "user.delete"
+	auditProfileUpdate        = "user.profile_update"
+	auditEmailChangeRequest   = "user.email_change_request"
+	auditEmailVerify          = "user.email_verify"
+	auditTotpEnable           = "user.totp_enable"
+	auditTotpDisable          = "user.totp_disable"
+	auditLogoutAll            = "user.logout_all"
+	auditAPIKeyCreate         = "api_key.create"
+	auditAPIKeyDelete         = "api_key.delete"
+	auditOAuthClientCreate    = "oauth_client.create"
+	auditOAuthClientDelete    = "oauth_client.delete"
+	auditOAuthConsentGrant    = "oauth_consent.grant"
+	auditOAuthConsentRevoke   = "oauth_consent.revoke"
+	auditAccountCreate        = "account.create"
+	auditTransferCreate       = "transfer.create"
+	auditLoginUnlock          = "admin.login_unlock"
+	auditUserRoleUpdate       = "admin.user_role_update"
+	auditAccountFreeze        = "admin.account_freeze"
+	auditAccountUnfreeze      = "admin.account_unfreeze"
+	auditBalanceAdjust        = "admin.balance_adjust"
+	auditResourceUser         = "user"
+	auditResourceAccount      = "account"
+	auditResourceTransfer     = "transfer"
+	auditResourceLogin        = "login"
+	auditResourceAPIKey       = "api_key"
+	auditResourceOAuthClient  = "oauth_client"
+	auditResourceOAuthConsent = "oauth_consent"
+)
+
+// userAuditSnapshot 审计事件中用户的快照, 审计日志只能追加并且以散列链防止修改, 写入之后无法删除个人信息,
+// 因此只记录用户名, 角色, 状态与时间, 不记录邮箱与姓名
+type userAuditSnapshot struct {
+	Username          string     `json:"username"`
+	Role              string     `json:"role"`
+	IsEmailVerified   bool       `json:"isEmailVerified"`
+	PasswordChangedAt time.Time  `json:"passwordChangedAt"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+	DeletedAt         *time.Time `json:"deletedAt"`
+}
+
+func newUserAuditSnapshot(user db.Users) userAuditSnapshot {
+	return userAuditSnapshot{
+		Username:          user.Username,
+		Role:              user.Role,
+		IsEmailVerified:   user.IsEmailVerified,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+		DeletedAt:         user.DeletedAt,
+	}
+}
+
+// newAuditEvent 创建当前请求的审计事件, 已登录时操作人为令牌中的用户名, 否则为 actor
+func newAuditEvent(ctx *gin.Context, actor string, action string, resourceType string, resourceID string) *db.AuditEvent {
+	if payload, ok := ctx.Get(constants.AuthorizationPayloadKey); ok {
+		actor = payload.(*token.Payload).Username
+	}
+	return &db.AuditEvent{
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ClientIP:     ctx.ClientIP(),
+		UserAgent:    ctx.Request.UserAgent(),
+		RequestID:    middleware.GetRequestID(ctx),
+	}
+}
+
+type auditEventResponse struct {
+	ID           int64           `json:"id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resourceType"`
+	ResourceID   string          `json:"resourceID"`
+	ClientIP     string          `json:"clientIp"`
+	UserAgent    string          `json:"userAgent"`
+	RequestID    string          `json:"requestID"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+func newAuditEventResponse(event db.AuditEvents) auditEventResponse {
+	return auditEventResponse{
+		ID:           event.ID,
+		Actor:        event.Actor,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		ClientIP:     event.ClientIp,
+		UserAgent:    event.UserAgent,
+		RequestID:    event.RequestID,
+		Before:       snapshotJSON(event.Before),
+		After:        snapshotJSON(event.After),
+		CreatedAt:    event.CreatedAt,
+	}
+}
+
+// snapshotJSON 为空的快照输出为null
+func snapshotJSON(snapshot []byte) json.RawMessage {
+	if len(snapshot) == 0 {
+		return json.RawMessage("null")
+	}
+	return snapshot
+}
+
+// 按操作人, 操作, 资源与时间范围查询审计事件, 最新的在前
+func (s *Server) listAuditEvents(ctx *gin.Context) {
+	type listAuditEventsRequest struct {
+		Actor        string    `form:"actor"`
+		Action       string    `form:"action"`
+		ResourceType string    `form:"resource_type"`
+		ResourceID   string    `form:"resource_id"`
+		Since        time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until        time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		pageRequest
+	}
+
+	var req listAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	events, err := s.store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:        optionalString(req.Actor),
+		Action:       optionalString(req.Action),
+		ResourceType: optionalString(req.ResourceType),
+		ResourceID:   optionalString(req.ResourceID),
+		Since:        optionalTime(req.Since),
+		Until:        optionalTime(req.Until),
+		Limit:        req.limit(),
+		Offset:       req.offset(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]auditEventResponse, 0, len(events))
+	for _, event := range events {
+		rsp = append(rsp, newAuditEventResponse(event))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// optionalString 未提供的查询条件为nil, 不参与过滤
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func optionalTime(value time.Time) *time.Time {
+	if value.IsZero() {
+		return nil
+	}
+	return &value
+}
+
+// checkpointAuditChain 每隔interval记录一次审计散列链的链头, 直到ctx被取消
+// 检查点同时写入日志, 日志保存在数据库之外, 可以发现整条链被重新计算的情况
+func (s *Server) checkpointAuditChain(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkpoint, err := s.store.CreateAuditCheckpoint(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				// 上次检查点之后没有新的事件
+				continue
+			}
+			if err != nil {
+				log.Printf("checkpoint audit chain: %v", err)
+				continue
+			}
+			log.Printf("audit checkpoint: event %d hash %x", checkpoint.EventID, checkpoint.Hash)
+		}
+	}
+}
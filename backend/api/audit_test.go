@@ -0,0 +1,363 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+)
+
+// stubAuditTx 直接在mock上执行审计事务中的操作, 不检查审计事件
+func stubAuditTx(store *mockdb.MockStore) {
+	store.EXPECT().
+		AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(ctx context.Context, _ *db.AuditEvent, fn func(context.Context, db.Querier) error) error {
+			if fn == nil {
+				return nil
+			}
+			return fn(ctx, store)
+		})
+}
+
+func TestAuditEventRecorded(t *testing.T) {
+	admin, _ := randomUser(t)
+	account := randomAccount(t, admin.Username)
+	frozen := account
+	frozen.IsFrozen = true
+	requestID := pkg.RandomString(16)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubTokenNotRevoked(store)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	store.EXPECT().SetAccountFrozen(gomock.Any(), gomock.Any()).Times(1).Return(frozen, nil)
+
+	var event *db.AuditEvent
+	store.EXPECT().
+		AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, e *db.AuditEvent, fn func(context.Context, db.Querier) error) error {
+			event = e
+			return fn(ctx, store)
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/admin/accounts/%d/freeze", account.ID), nil)
+	require.NoError(t, err)
+	request.Header.Set(constants.RequestIDHeaderKey, requestID)
+	request.Header.Set("User-Agent", "audit-test")
+	addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, admin.Username, rbac.RoleAdmin, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, requestID, recorder.Header().Get(constants.RequestIDHeaderKey))
+
+	require.NotNil(t, event)
+	require.Equal(t, admin.Username, event.Actor)
+	require.Equal(t, auditAccountFreeze, event.Action)
+	require.Equal(t, auditResourceAccount, event.ResourceType)
+	require.Equal(t, strconv.FormatInt(account.ID, 10), event.ResourceID)
+	require.Equal(t, requestID, event.RequestID)
+	require.Equal(t, "audit-test", event.UserAgent)
+	require.Equal(t, account, event.Before)
+	require.Equal(t, frozen, event.After)
+}
+
+func TestUserCreateAuditSnapshot(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+
+	var event *db.AuditEvent
+	store.EXPECT().
+		AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, e *db.AuditEvent, fn func(context.Context, db.Querier) error) error {
+			event = e
+			return fn(ctx, store)
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{
+		"username": user.Username,
+		"fullName": user.FullName,
+		"password": password,
+		"email":    user.Email,
+	})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	require.NotNil(t, event)
+	require.Equal(t, auditUserCreate, event.Action)
+	snapshot, err := json.Marshal(event.After)
+	require.NoError(t, err)
+	require.Contains(t, string(snapshot), user.Username)
+	// 审计日志无法删除, 快照中不包含邮箱与姓名
+	require.NotContains(t, string(snapshot), user.Email)
+	require.NotContains(t, string(snapshot), user.FullName)
+	require.NotContains(t, string(snapshot), "email\"")
+	require.NotContains(t, string(snapshot), "fullName")
+}
+
+func TestSecurityEventsAudited(t *testing.T) {
+	user, _ := randomUser(t)
+	renamed := user
+	renamed.FullName = pkg.RandomString(8)
+	renamed.UpdatedAt = user.UpdatedAt.Add(time.Second)
+	apiKey := db.ApiKeys{ID: uuid.New(), Username: user.Username, Name: "ci", Prefix: "sb_test", HashedKey: pkg.RandomString(32)}
+	consent := db.OauthConsents{Username: user.Username, ClientID: pkg.RandomString(16), Scopes: []string{"accounts:read"}}
+
+	testCases := []struct {
+		name         string
+		method       string
+		url          string
+		body         gin.H
+		buildStubs   func(store *mockdb.MockStore)
+		action       string
+		resourceType string
+		resourceID   string
+		checkEvent   func(t *testing.T, event *db.AuditEvent)
+	}{
+		{
+			name:   "退出所有设备",
+			method: http.MethodPost,
+			url:    "/users/logout-all",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().BlockUserSessions(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(nil)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().CreateRevokedToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			action:       auditLogoutAll,
+			resourceType: auditResourceUser,
+			resourceID:   user.Username,
+			checkEvent:   func(t *testing.T, event *db.AuditEvent) {},
+		},
+		{
+			name:   "修改姓名",
+			method: http.MethodPatch,
+			url:    "/users/me",
+			body:   gin.H{"fullName": renamed.FullName},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(1).Return(renamed, nil)
+			},
+			action:       auditProfileUpdate,
+			resourceType: auditResourceUser,
+			resourceID:   user.Username,
+			checkEvent: func(t *testing.T, event *db.AuditEvent) {
+				require.Equal(t, newUserAuditSnapshot(user), event.Before)
+				require.Equal(t, newUserAuditSnapshot(renamed), event.After)
+
+				// 审计日志无法删除, 快照中不包含姓名
+				snapshot, err := json.Marshal(event.After)
+				require.NoError(t, err)
+				require.NotContains(t, string(snapshot), renamed.FullName)
+			},
+		},
+		{
+			name:   "删除API密钥",
+			method: http.MethodDelete,
+			url:    "/users/api-keys/" + apiKey.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().DeleteAPIKey(gomock.Any(), gomock.Any()).Times(1).Return(apiKey, nil)
+			},
+			action:       auditAPIKeyDelete,
+			resourceType: auditResourceAPIKey,
+			resourceID:   apiKey.ID.String(),
+			checkEvent: func(t *testing.T, event *db.AuditEvent) {
+				require.Equal(t, newAPIKeyResponse(apiKey), event.Before)
+
+				// 快照中不包含密钥的散列值
+				snapshot, err := json.Marshal(event.Before)
+				require.NoError(t, err)
+				require.NotContains(t, string(snapshot), apiKey.HashedKey)
+			},
+		},
+		{
+			name:   "撤销第三方应用的授权",
+			method: http.MethodDelete,
+			url:    "/oauth/consents/" + consent.ClientID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeOAuthConsentTx(gomock.Any(), gomock.Any()).Times(1).Return(consent, nil)
+			},
+			action:       auditOAuthConsentRevoke,
+			resourceType: auditResourceOAuthConsent,
+			resourceID:   consent.ClientID,
+			checkEvent: func(t *testing.T, event *db.AuditEvent) {
+				require.Equal(t, consent, event.Before)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			tc.buildStubs(store)
+
+			var event *db.AuditEvent
+			store.EXPECT().
+				AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(ctx context.Context, e *db.AuditEvent, fn func(context.Context, db.Querier) error) error {
+					event = e
+					return fn(ctx, store)
+				})
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body []byte
+			if tc.body != nil {
+				var err error
+				body, err = json.Marshal(tc.body)
+				require.NoError(t, err)
+			}
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Less(t, recorder.Code, http.StatusBadRequest)
+
+			require.NotNil(t, event)
+			require.Equal(t, user.Username, event.Actor)
+			require.Equal(t, tc.action, event.Action)
+			require.Equal(t, tc.resourceType, event.ResourceType)
+			require.Equal(t, tc.resourceID, event.ResourceID)
+			tc.checkEvent(t, event)
+		})
+	}
+}
+
+func TestListAuditEventsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	event := db.AuditEvents{
+		ID:           pkg.RandomInt(1, 1000),
+		Actor:        user.Username,
+		Action:       auditAccountCreate,
+		ResourceType: auditResourceAccount,
+		ResourceID:   "1",
+		ClientIp:     "10.0.0.1",
+		RequestID:    pkg.RandomString(16),
+		After:        []byte(`{"id":1}`),
+		CreatedAt:    time.Now(),
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "?page_id=1&page_size=10&actor=" + user.Username + "&since=2024-01-01T00:00:00Z",
+			role:  rbac.RoleAuditor,
+			buildStubs: func(store *mockdb.MockStore) {
+				since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ListAuditEventsParams) ([]db.AuditEvents, error) {
+						require.Equal(t, user.Username, *arg.Actor)
+						require.Nil(t, arg.Action)
+						require.Nil(t, arg.Until)
+						require.True(t, since.Equal(*arg.Since))
+						require.Equal(t, int64(10), arg.Limit)
+						require.Equal(t, int64(0), arg.Offset)
+						return []db.AuditEvents{event}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp []auditEventResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, 1)
+				require.Equal(t, event.RequestID, rsp[0].RequestID)
+				require.JSONEq(t, `{"id":1}`, string(rsp[0].After))
+				require.Equal(t, "null", string(rsp[0].Before))
+			},
+		},
+		{
+			name:  "时间格式错误",
+			query: "?page_id=1&page_size=10&since=yesterday",
+			role:  rbac.RoleAuditor,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:  "管理员不能查询审计日志",
+			query: "?page_id=1&page_size=10",
+			role:  rbac.RoleAdmin,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/audit/events"+tc.query, nil)
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
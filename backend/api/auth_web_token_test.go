@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
 	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
 	"simple_bank/middleware"
+	"simple_bank/pkg/rbac"
 	"simple_bank/pkg/token"
 	"testing"
 	"time"
@@ -19,36 +22,63 @@ func addMiddleware(
 	authWebTokenType string,
 	tokenMaker token.Maker,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
-	tokenString, err := tokenMaker.CreateToken(username, duration)
+	tokenString, payload, err := tokenMaker.CreateToken(username, role, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, tokenString)
+	require.NotEmpty(t, payload)
 
 	authorizationHeader := fmt.Sprintf("%s %s", authWebTokenType, tokenString)
 	request.Header.Set(constants.AuthorizationHeaderKey, authorizationHeader)
 }
 
+// stubTokenNotRevoked 所有令牌都未被撤销
+func stubTokenNotRevoked(store *mockdb.MockStore) {
+	store.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(false, nil)
+}
+
 func TestAuthMiddleware(t *testing.T) {
 	testCases := []struct {
 		name          string
 		setupAuth     func(t *testing.T, req *http.Request, token token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
 			name: "OK",
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "user", time.Minute)
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "user", rbac.RoleCustomer, time.Minute)
 			},
+			buildStubs: stubTokenNotRevoked,
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
 
 			},
 		},
+		{
+			name: "令牌已撤销",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "user", rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					IsTokenRevoked(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(true, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 		{
 			name: "空授权类型",
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, "", tokenMaker, "user", time.Minute)
+				addMiddleware(t, req, "", tokenMaker, "user", rbac.RoleCustomer, time.Minute)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -57,7 +87,20 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "其它授权类型",
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, "OAuth", tokenMaker, "user", time.Minute)
+				addMiddleware(t, req, "OAuth", tokenMaker, "user", rbac.RoleCustomer, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "刷新令牌",
+			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
+				refreshToken, _ := createRefreshToken(t, tokenMaker, "user")
+				req.Header.Set(constants.AuthorizationHeaderKey, fmt.Sprintf("%s %s", constants.AuthorizationHeaderType, refreshToken))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IsTokenRevoked(gomock.Any(), gomock.Any()).Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -66,7 +109,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "令牌过期",
 			setupAuth: func(t *testing.T, req *http.Request, tokenMaker token.Maker) {
-				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "user", -time.Minute)
+				addMiddleware(t, req, constants.AuthorizationHeaderType, tokenMaker, "user", rbac.RoleCustomer, -time.Minute)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -77,12 +120,19 @@ func TestAuthMiddleware(t *testing.T) {
 	for i := range testCases {
 		tc := testCases[i]
 		t.Run(tc.name, func(t *testing.T) {
-			server := newTestServer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			if tc.buildStubs != nil {
+				tc.buildStubs(store)
+			}
+			server := newTestServer(t, store)
 
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				middleware.AuthWebTokenMiddleware(server.tokenMake),
+				middleware.AuthWebTokenMiddleware(server.tokenMake, server.revocations, server.apiKeys, server.config.TokenAudience),
 				func(ctx *gin.Context,
 				) {
 					ctx.JSON(http.StatusOK, gin.H{})
@@ -100,3 +150,55 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	const owner = "owner"
+
+	testCases := []struct {
+		name     string
+		username string
+		role     string
+		code     int
+	}{
+		{name: "客户操作自己的资源", username: owner, role: rbac.RoleCustomer, code: http.StatusOK},
+		{name: "客户操作其他用户的资源", username: "other", role: rbac.RoleCustomer, code: http.StatusForbidden},
+		{name: "柜员操作其他用户的资源", username: "other", role: rbac.RoleTeller, code: http.StatusOK},
+		{name: "审计没有该权限", username: owner, role: rbac.RoleAuditor, code: http.StatusForbidden},
+		{name: "未知的角色", username: owner, role: "", code: http.StatusForbidden},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			server := newTestServer(t, store)
+
+			authPath := "/authorize"
+			server.router.GET(
+				authPath,
+				middleware.AuthWebTokenMiddleware(server.tokenMake, server.revocations, server.apiKeys, server.config.TokenAudience),
+				middleware.Authorize(rbac.AccountsCreate),
+				func(ctx *gin.Context) {
+					if !middleware.CanAccessOwner(ctx, owner) {
+						ctx.JSON(http.StatusForbidden, gin.H{})
+						return
+					}
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, tc.username, tc.role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+
+			require.Equal(t, tc.code, recorder.Code)
+		})
+	}
+}
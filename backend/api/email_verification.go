@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/mail"
+	"simple_bank/pkg/token"
+)
+
+// 邮箱验证令牌的字节数
+const emailVerificationTokenSize = 32
+
+// newEmailVerificationToken 生成邮箱验证令牌, 返回令牌与令牌的散列值
+func newEmailVerificationToken() (string, string, error) {
+	verifyToken, err := pkg.RandomSecret(emailVerificationTokenSize)
+	if err != nil {
+		return "", "", err
+	}
+	return verifyToken, pkg.HashSecret(verifyToken), nil
+}
+
+// sendEmailVerification 向用户的邮箱发送验证链接
+func (s *Server) sendEmailVerification(ctx context.Context, locale string, user db.Users, verifyToken string, expiresAt time.Time) error {
+	link, err := tokenLink(s.config.EmailVerifyURL, verifyToken)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      []string{user.Email},
+		Subject: i18n.T(locale, i18n.MsgEmailVerifySubject),
+		Body:    i18n.T(locale, i18n.MsgEmailVerifyBody, user.Username, expiresAt.Format("2006-01-02 15:04 MST"), link),
+	})
+}
+
+// requireVerifiedEmail 用户的邮箱未验证时返回403并终止请求
+func (s *Server) requireVerifiedEmail(ctx *gin.Context, username string) bool {
+	user, err := s.store.GetUser(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if !user.IsEmailVerified {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgEmailNotVerified)})
+		return false
+	}
+	return true
+}
+
+// 使用邮件中的令牌验证邮箱
+func (s *Server) verifyEmail(ctx *gin.Context) {
+	type verifyEmailRequest struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	var req verifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	// 验证之前不知道令牌属于哪个用户, 操作人与资源在验证之后补充
+	var user db.Users
+	event := newAuditEvent(ctx, "", auditEmailVerify, auditResourceUser, "")
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		user, err = s.store.VerifyEmailTx(ctx, pkg.HashSecret(req.Token))
+		if event.Actor == "" {
+			event.Actor = user.Username
+		}
+		event.ResourceID = user.Username
+		event.After = newUserAuditSnapshot(user)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrEmailVerificationInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgEmailVerifyInvalid)})
+			return
+		}
+		if errors.Is(err, db.ErrEmailTaken) {
+			ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgEmailTaken)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// 重新发送验证邮件, 注册时的邮件丢失或令牌过期时使用
+func (s *Server) resendEmailVerification(ctx *gin.Context) {
+	locale := middleware.GetLocale(ctx)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	user, err := s.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if user.IsEmailVerified {
+		ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(locale, i18n.MsgEmailAlreadyVerified)})
+		return
+	}
+
+	verifyToken, hashedToken, err := newEmailVerificationToken()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	verification, err := s.store.CreateEmailVerification(ctx, db.CreateEmailVerificationParams{
+		HashedToken: hashedToken,
+		Username:    user.Username,
+		Email:       user.Email,
+		ExpiresAt:   time.Now().Add(s.config.EmailVerifyDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err = s.sendEmailVerification(ctx, locale, user, verifyToken, verification.ExpiresAt); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": i18n.T(locale, i18n.MsgEmailVerifySent)})
+}
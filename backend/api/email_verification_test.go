@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+)
+
+func TestCreateUserSendsVerificationEmail(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	stubAuditTx(store)
+
+	var hashedToken string
+	store.EXPECT().
+		CreateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateUserTxParams) (db.Users, error) {
+			hashedToken = arg.HashedVerificationToken
+			require.True(t, arg.VerificationExpiresAt.After(time.Now()))
+			return user, nil
+		})
+
+	server := newTestServer(t, store)
+	server.config.EmailVerifyURL = "https://bank.example.com/verify-email"
+	server.config.EmailVerifyDuration = time.Hour
+	mailer := &recordingMailer{}
+	server.mailer = mailer
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{
+		"username": user.Username,
+		"fullName": user.FullName,
+		"password": password,
+		"email":    user.Email,
+	})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPut, "/users", bytes.NewReader(body))
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Len(t, mailer.messages, 1)
+	require.Equal(t, []string{user.Email}, mailer.messages[0].To)
+
+	// 邮件中的令牌与保存的散列值对应
+	link := mailer.messages[0].Body
+	start := bytes.Index([]byte(link), []byte("token=")) + len("token=")
+	require.Greater(t, start, len("token=")-1)
+	verifyToken := link[start:]
+	if end := bytes.IndexByte([]byte(verifyToken), '\n'); end >= 0 {
+		verifyToken = verifyToken[:end]
+	}
+	require.Equal(t, hashedToken, pkg.HashSecret(verifyToken))
+}
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	verifyToken := pkg.RandomString(32)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				verified := user
+				verified.IsEmailVerified = true
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(pkg.HashSecret(verifyToken))).
+					Times(1).
+					Return(verified, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.IsEmailVerified)
+			},
+		},
+		{
+			name: "令牌无效",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Users{}, db.ErrEmailVerificationInvalid)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "新邮箱已被使用",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Users{}, db.ErrEmailTaken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"token": verifyToken})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/email/verify", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestUnverifiedEmailRestrictions(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = false
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		body   gin.H
+	}{
+		{
+			name:   "创建账户",
+			method: http.MethodPut,
+			url:    "/accounts",
+			body:   gin.H{"owner": user.Username, "currency": constants.USD},
+		},
+		{
+			name:   "转账",
+			method: http.MethodPut,
+			url:    "/transfers",
+			body:   gin.H{"fromAccountID": 1, "toAccountID": 2, "amount": 10, "currency": constants.USD},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		})
+	}
+}
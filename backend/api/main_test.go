@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"simple_bank/pkg"
+	"simple_bank/pkg/password"
 
 	"simple_bank/config"
 
@@ -22,10 +23,32 @@ var (
 	testDB      *pgxpool.Pool
 )
 
+// 测试使用较小的argon2id参数, 与 newTestServer 的配置一致, 登录时不会重新计算散列值
+var (
+	testArgon2idParams = password.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1}
+	testPasswords      = password.NewManager(password.NewArgon2idHasher(testArgon2idParams))
+)
+
+// hashTestPassword 使用与测试服务器相同的算法与参数生成散列值
+func hashTestPassword(t *testing.T, plain string) string {
+	hashedPassword, err := testPasswords.Hash(plain)
+	require.NoError(t, err)
+	return hashedPassword
+}
+
 func newTestServer(t *testing.T, store db.Store) *Server {
 	cfg := &config.Config{
-		TokenSymmetricKey:   pkg.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    pkg.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
+		MFAChallengeDuration: time.Minute,
+		TokenAudience:        "simple_bank",
+		ScopedMaxDuration:    time.Hour,
+		StepUpThresholds:     "USD:1000",
+		StepUpDuration:       5 * time.Minute,
+		Argon2Memory:         testArgon2idParams.Memory,
+		Argon2Iterations:     testArgon2idParams.Iterations,
+		Argon2Parallelism:    testArgon2idParams.Parallelism,
 	}
 	server, err := NewServer(cfg, store)
 	require.NoError(t, err)
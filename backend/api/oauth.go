@@ -0,0 +1,522 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/oauth"
+	"simple_bank/pkg/token"
+)
+
+const (
+	// 第三方应用的id, client_secret, 授权码与刷新令牌的字节数
+	oauthClientIDSize     = 16
+	oauthClientSecretSize = 32
+	oauthCodeSize         = 32
+	oauthRefreshTokenSize = 32
+)
+
+// OAuth2 规定的错误码
+const (
+	oauthErrInvalidRequest = "invalid_request"
+	oauthErrInvalidClient  = "invalid_client"
+	oauthErrInvalidGrant   = "invalid_grant"
+	oauthErrAccessDenied   = "access_denied"
+)
+
+type oauthClientResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// newOAuthClientResponse 不返回client_secret的散列值
+func newOAuthClientResponse(client db.OauthClients) oauthClientResponse {
+	return oauthClientResponse{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.HashedSecret != nil,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// 注册第三方应用, 机密应用的client_secret只在注册时返回一次
+func (s *Server) createOAuthClient(ctx *gin.Context) {
+	type createOAuthClientRequest struct {
+		Name         string   `json:"name" binding:"required"`
+		RedirectURIs []string `json:"redirectUris" binding:"required,min=1,dive,url"`
+		Scopes       []string `json:"scopes" binding:"required,min=1,dive,oauthscope"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	var req createOAuthClientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	clientID, err := pkg.RandomSecret(oauthClientIDSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var clientSecret string
+	var hashedSecret *string
+	if req.Confidential {
+		clientSecret, err = pkg.RandomSecret(oauthClientSecretSize)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		hashed := pkg.HashSecret(clientSecret)
+		hashedSecret = &hashed
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	slices.Sort(req.Scopes)
+	var client db.OauthClients
+	event := newAuditEvent(ctx, payload.Username, auditOAuthClientCreate, auditResourceOAuthClient, clientID)
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		var err error
+		client, err = q.CreateOAuthClient(ctx, db.CreateOAuthClientParams{
+			ID:           clientID,
+			Name:         req.Name,
+			HashedSecret: hashedSecret,
+			RedirectUris: req.RedirectURIs,
+			Scopes:       slices.Compact(req.Scopes),
+			Owner:        payload.Username,
+		})
+		event.After = newOAuthClientResponse(client)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"client":       newOAuthClientResponse(client),
+		"clientSecret": clientSecret,
+	})
+}
+
+// 列出当前用户注册的第三方应用
+func (s *Server) listOAuthClients(ctx *gin.Context) {
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	clients, err := s.store.ListOAuthClients(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]oauthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		rsp = append(rsp, newOAuthClientResponse(client))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// 删除第三方应用, 该应用的授权与刷新令牌随之删除
+func (s *Server) deleteOAuthClient(ctx *gin.Context) {
+	type deleteOAuthClientRequest struct {
+		ID string `uri:"id" binding:"required"`
+	}
+
+	var req deleteOAuthClientRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	event := newAuditEvent(ctx, payload.Username, auditOAuthClientDelete, auditResourceOAuthClient, req.ID)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		client, err := q.DeleteOAuthClient(ctx, db.DeleteOAuthClientParams{
+			ID:    req.ID,
+			Owner: payload.Username,
+		})
+		event.Before = newOAuthClientResponse(client)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// 授权请求的参数, 与第三方应用重定向到授权页面时携带的查询参数相同
+type authorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required,eq=code"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope" binding:"required"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required,min=43,max=128"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required,eq=S256"`
+}
+
+// validateAuthorizationRequest 校验应用, 回调地址与授权范围, 失败时返回400并终止请求
+// 回调地址未注册时不能重定向回应用, 所有错误都直接返回给授权页面
+func (s *Server) validateAuthorizationRequest(ctx *gin.Context, req authorizationRequest) (db.OauthClients, []string, bool) {
+	locale := middleware.GetLocale(ctx)
+
+	client, err := s.store.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgOAuthClientInvalid)})
+			return db.OauthClients{}, nil, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.OauthClients{}, nil, false
+	}
+
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgOAuthRedirectInvalid)})
+		return db.OauthClients{}, nil, false
+	}
+
+	scopes := oauth.ParseScope(req.Scope)
+	if len(scopes) == 0 || !oauth.Subset(scopes, client.Scopes) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgOAuthScopeInvalid)})
+		return db.OauthClients{}, nil, false
+	}
+
+	return client, scopes, true
+}
+
+// 授权页面加载时查询应用的信息与申请的授权范围, consented 表示用户此前已同意过这些授权范围
+func (s *Server) getAuthorizationRequest(ctx *gin.Context) {
+	var req authorizationRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	client, scopes, ok := s.validateAuthorizationRequest(ctx, req)
+	if !ok {
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	consented := false
+	consent, err := s.store.GetOAuthConsent(ctx, db.GetOAuthConsentParams{
+		Username: payload.Username,
+		ClientID: client.ID,
+	})
+	if err == nil {
+		consented = oauth.Subset(scopes, consent.Scopes)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"client": gin.H{
+			"id":   client.ID,
+			"name": client.Name,
+		},
+		"scopes":    scopes,
+		"consented": consented,
+	})
+}
+
+// 用户在授权页面同意或拒绝授权, 返回应重定向回应用的地址
+// 同意时地址中携带授权码, 拒绝时携带 access_denied 错误
+func (s *Server) approveAuthorization(ctx *gin.Context) {
+	type approveAuthorizationRequest struct {
+		authorizationRequest
+		Approve bool `json:"approve"`
+	}
+
+	var req approveAuthorizationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	client, scopes, ok := s.validateAuthorizationRequest(ctx, req.authorizationRequest)
+	if !ok {
+		return
+	}
+
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	if !req.Approve {
+		params.Set("error", oauthErrAccessDenied)
+		ctx.JSON(http.StatusOK, gin.H{"redirectUri": redirectWithParams(req.RedirectURI, params)})
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	event := newAuditEvent(ctx, payload.Username, auditOAuthConsentGrant, auditResourceOAuthConsent, client.ID)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		consent, err := q.UpsertOAuthConsent(ctx, db.UpsertOAuthConsentParams{
+			Username: payload.Username,
+			ClientID: client.ID,
+			Scopes:   scopes,
+		})
+		event.After = consent
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	code, err := pkg.RandomSecret(oauthCodeSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	err = s.store.CreateOAuthAuthorizationCode(ctx, db.CreateOAuthAuthorizationCodeParams{
+		HashedCode:    pkg.HashSecret(code),
+		ClientID:      client.ID,
+		Username:      payload.Username,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.config.OAuthCodeDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	params.Set("code", code)
+	ctx.JSON(http.StatusOK, gin.H{"redirectUri": redirectWithParams(req.RedirectURI, params)})
+}
+
+// redirectWithParams 将参数附加到回调地址上, 保留回调地址原有的查询参数
+func redirectWithParams(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// OAuth2 令牌接口的响应, 字段名称遵循 RFC 6749
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// oauthError 按 RFC 6749 的格式返回错误
+func oauthError(ctx *gin.Context, status int, code string, description string) {
+	ctx.JSON(status, gin.H{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// 第三方应用使用授权码或刷新令牌换取访问令牌
+// 请求体为 application/x-www-form-urlencoded, 机密应用使用HTTP Basic或表单参数提交client_secret
+func (s *Server) oauthToken(ctx *gin.Context) {
+	type oauthTokenRequest struct {
+		GrantType    string `form:"grant_type" binding:"required,oneof=authorization_code refresh_token"`
+		Code         string `form:"code"`
+		RedirectURI  string `form:"redirect_uri"`
+		CodeVerifier string `form:"code_verifier"`
+		RefreshToken string `form:"refresh_token"`
+		ClientID     string `form:"client_id"`
+		ClientSecret string `form:"client_secret"`
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+
+	var req oauthTokenRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err.Error())
+		return
+	}
+	if clientID, clientSecret, ok := ctx.Request.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = clientID, clientSecret
+	}
+
+	client, ok := s.authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret)
+	if !ok {
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	refreshToken, err := pkg.RandomSecret(oauthRefreshTokenSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	refreshExpiresAt := time.Now().Add(s.config.OAuthRefreshDuration)
+
+	var grant db.OauthRefreshTokens
+	switch req.GrantType {
+	case "authorization_code":
+		code, err := s.store.ConsumeOAuthAuthorizationCode(ctx, pkg.HashSecret(req.Code))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, i18n.T(locale, i18n.MsgOAuthGrantInvalid))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		// 授权码已被标记为已使用, 校验失败时同样作废
+		if code.ClientID != client.ID || code.RedirectUri != req.RedirectURI || !oauth.VerifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+			oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, i18n.T(locale, i18n.MsgOAuthGrantInvalid))
+			return
+		}
+
+		grant, err = s.store.CreateOAuthRefreshToken(ctx, db.CreateOAuthRefreshTokenParams{
+			HashedToken: pkg.HashSecret(refreshToken),
+			ClientID:    client.ID,
+			Username:    code.Username,
+			Scopes:      code.Scopes,
+			ExpiresAt:   refreshExpiresAt,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	case "refresh_token":
+		grant, err = s.store.RotateOAuthRefreshTokenTx(ctx, db.RotateOAuthRefreshTokenTxParams{
+			HashedToken:    pkg.HashSecret(req.RefreshToken),
+			ClientID:       client.ID,
+			NewHashedToken: pkg.HashSecret(refreshToken),
+			ExpiresAt:      refreshExpiresAt,
+		})
+		if err != nil {
+			if errors.Is(err, db.ErrOAuthRefreshTokenInvalid) {
+				oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, i18n.T(locale, i18n.MsgOAuthGrantInvalid))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	// 角色以用户当前的角色为准
+	user, err := s.store.GetUser(ctx, grant.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	restriction := token.Restriction{ClientID: client.ID, Scopes: grant.Scopes, Audience: s.tokenAudience()}
+	accessToken, _, err := s.tokenMake.CreateScopedToken(user.Username, user.Role, restriction, s.config.OAuthAccessDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.config.OAuthAccessDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        oauth.FormatScope(grant.Scopes),
+	})
+}
+
+// authenticateOAuthClient 认证第三方应用, 机密应用必须提交正确的client_secret, 失败时返回401并终止请求
+func (s *Server) authenticateOAuthClient(ctx *gin.Context, clientID string, clientSecret string) (db.OauthClients, bool) {
+	description := i18n.T(middleware.GetLocale(ctx), i18n.MsgOAuthClientInvalid)
+	if clientID == "" {
+		oauthError(ctx, http.StatusUnauthorized, oauthErrInvalidClient, description)
+		return db.OauthClients{}, false
+	}
+
+	client, err := s.store.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			oauthError(ctx, http.StatusUnauthorized, oauthErrInvalidClient, description)
+			return db.OauthClients{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.OauthClients{}, false
+	}
+
+	if client.HashedSecret != nil &&
+		subtle.ConstantTimeCompare([]byte(pkg.HashSecret(clientSecret)), []byte(*client.HashedSecret)) != 1 {
+		oauthError(ctx, http.StatusUnauthorized, oauthErrInvalidClient, description)
+		return db.OauthClients{}, false
+	}
+	return client, true
+}
+
+// 列出当前用户对第三方应用的授权
+func (s *Server) listOAuthConsents(ctx *gin.Context) {
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	consents, err := s.store.ListOAuthConsents(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, consents)
+}
+
+// 撤销对第三方应用的授权
+func (s *Server) revokeOAuthConsent(ctx *gin.Context) {
+	type revokeOAuthConsentRequest struct {
+		ClientID string `uri:"client_id" binding:"required"`
+	}
+
+	var req revokeOAuthConsentRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	event := newAuditEvent(ctx, payload.Username, auditOAuthConsentRevoke, auditResourceOAuthConsent, req.ClientID)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		consent, err := s.store.RevokeOAuthConsentTx(ctx, db.DeleteOAuthConsentParams{
+			Username: payload.Username,
+			ClientID: req.ClientID,
+		})
+		event.Before = consent
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
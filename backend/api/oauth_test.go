@@ -0,0 +1,619 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/oauth"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+const testRedirectURI = "https://partner.example.com/callback"
+
+func randomOAuthClient(t *testing.T, owner string, clientSecret string) db.OauthClients {
+	client := db.OauthClients{
+		ID:           pkg.RandomString(16),
+		Name:         "partner",
+		RedirectUris: []string{testRedirectURI},
+		Scopes:       []string{token.ScopeAccountsRead, token.ScopeTransfersWrite},
+		Owner:        owner,
+		CreatedAt:    time.Now(),
+	}
+	if clientSecret != "" {
+		hashed := pkg.HashSecret(clientSecret)
+		client.HashedSecret = &hashed
+	}
+	return client
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestCreateOAuthClientAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "机密应用",
+			body: gin.H{
+				"name":         "partner",
+				"redirectUris": []string{testRedirectURI},
+				"scopes":       []string{token.ScopeAccountsRead},
+				"confidential": true,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthClientParams) (db.OauthClients, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.NotNil(t, arg.HashedSecret)
+						return db.OauthClients{
+							ID:           arg.ID,
+							Name:         arg.Name,
+							HashedSecret: arg.HashedSecret,
+							RedirectUris: arg.RedirectUris,
+							Scopes:       arg.Scopes,
+							Owner:        arg.Owner,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var rsp struct {
+					Client       oauthClientResponse `json:"client"`
+					ClientSecret string              `json:"clientSecret"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.Client.Confidential)
+				require.NotEmpty(t, rsp.ClientSecret)
+			},
+		},
+		{
+			name: "不支持的授权范围",
+			body: gin.H{
+				"name":         "partner",
+				"redirectUris": []string{testRedirectURI},
+				"scopes":       []string{"users:roles"},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateOAuthClient(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewReader(data))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestApproveAuthorizationAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	client := randomOAuthClient(t, "partner", "")
+	challenge := codeChallenge(pkg.RandomString(43))
+
+	authorizationBody := func(redirectURI string, scope string, approve bool) gin.H {
+		return gin.H{
+			"response_type":         "code",
+			"client_id":             client.ID,
+			"redirect_uri":          redirectURI,
+			"scope":                 scope,
+			"state":                 "xyz",
+			"code_challenge":        challenge,
+			"code_challenge_method": oauth.CodeChallengeMethodS256,
+			"approve":               approve,
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "同意授权",
+			body: authorizationBody(testRedirectURI, token.ScopeAccountsRead, true),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+				store.EXPECT().
+					UpsertOAuthConsent(gomock.Any(), gomock.Eq(db.UpsertOAuthConsentParams{
+						Username: user.Username,
+						ClientID: client.ID,
+						Scopes:   []string{token.ScopeAccountsRead},
+					})).
+					Times(1).
+					Return(db.OauthConsents{}, nil)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthAuthorizationCodeParams) error {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, challenge, arg.CodeChallenge)
+						require.Equal(t, testRedirectURI, arg.RedirectUri)
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				redirect := requireRedirectURI(t, recorder)
+				require.NotEmpty(t, redirect.Query().Get("code"))
+				require.Equal(t, "xyz", redirect.Query().Get("state"))
+			},
+		},
+		{
+			name: "拒绝授权",
+			body: authorizationBody(testRedirectURI, token.ScopeAccountsRead, false),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				redirect := requireRedirectURI(t, recorder)
+				require.Equal(t, "access_denied", redirect.Query().Get("error"))
+				require.Empty(t, redirect.Query().Get("code"))
+			},
+		},
+		{
+			name: "回调地址未注册",
+			body: authorizationBody("https://evil.example.com/callback", token.ScopeAccountsRead, true),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "超出应用的授权范围",
+			body: authorizationBody(testRedirectURI, token.ScopeAccountsWrite, true),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "第三方应用的令牌不能授权",
+			body: authorizationBody(testRedirectURI, token.ScopeAccountsRead, true),
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				accessToken, _, err := tokenMaker.CreateScopedToken(user.Username, rbac.RoleCustomer, token.Restriction{ClientID: client.ID, Scopes: []string{token.ScopeAccountsRead}}, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(constants.AuthorizationHeaderKey, "Bearer "+accessToken)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/authorize", bytes.NewReader(data))
+			require.NoError(t, err)
+			tc.setupAuth(t, request, server.tokenMake)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func requireRedirectURI(t *testing.T, recorder *httptest.ResponseRecorder) *url.URL {
+	var rsp struct {
+		RedirectURI string `json:"redirectUri"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	redirect, err := url.Parse(rsp.RedirectURI)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(rsp.RedirectURI, testRedirectURI+"?"))
+	return redirect
+}
+
+func TestOAuthTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	publicClient := randomOAuthClient(t, "partner", "")
+	clientSecret := pkg.RandomString(32)
+	confidentialClient := randomOAuthClient(t, "partner", clientSecret)
+
+	verifier := pkg.RandomString(43)
+	code := pkg.RandomString(32)
+	refreshToken := pkg.RandomString(32)
+	scopes := []string{token.ScopeAccountsRead}
+
+	authorizationCode := db.OauthAuthorizationCodes{
+		HashedCode:    pkg.HashSecret(code),
+		ClientID:      publicClient.ID,
+		Username:      user.Username,
+		RedirectUri:   testRedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: codeChallenge(verifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name          string
+		form          url.Values
+		setupAuth     func(request *http.Request)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			name: "授权码换取令牌",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+				"client_id":     {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).Times(1).Return(publicClient, nil)
+				store.EXPECT().
+					ConsumeOAuthAuthorizationCode(gomock.Any(), gomock.Eq(pkg.HashSecret(code))).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					CreateOAuthRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthRefreshTokenParams) (db.OauthRefreshTokens, error) {
+						require.Equal(t, publicClient.ID, arg.ClientID)
+						require.Equal(t, scopes, arg.Scopes)
+						return db.OauthRefreshTokens{ClientID: arg.ClientID, Username: arg.Username, Scopes: arg.Scopes}, nil
+					})
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var rsp oauthTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, "Bearer", rsp.TokenType)
+				require.Equal(t, token.ScopeAccountsRead, rsp.Scope)
+				require.NotEmpty(t, rsp.RefreshToken)
+
+				payload, err := tokenMaker.VerifyToken(rsp.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, publicClient.ID, payload.ClientID)
+				require.Equal(t, scopes, payload.Scopes)
+				require.Equal(t, user.Role, payload.Role)
+			},
+		},
+		{
+			name: "code_verifier错误",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {pkg.RandomString(43)},
+				"client_id":     {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).Times(1).Return(publicClient, nil)
+				store.EXPECT().
+					ConsumeOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().CreateOAuthRefreshToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid_grant")
+			},
+		},
+		{
+			name: "授权码已使用",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+				"client_id":     {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).Times(1).Return(publicClient, nil)
+				store.EXPECT().
+					ConsumeOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthAuthorizationCodes{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid_grant")
+			},
+		},
+		{
+			name: "机密应用的client_secret错误",
+			form: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {refreshToken},
+			},
+			setupAuth: func(request *http.Request) {
+				request.SetBasicAuth(confidentialClient.ID, "wrong")
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(confidentialClient.ID)).Times(1).Return(confidentialClient, nil)
+				store.EXPECT().RotateOAuthRefreshTokenTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid_client")
+			},
+		},
+		{
+			name: "刷新令牌",
+			form: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {refreshToken},
+			},
+			setupAuth: func(request *http.Request) {
+				request.SetBasicAuth(confidentialClient.ID, clientSecret)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(confidentialClient.ID)).Times(1).Return(confidentialClient, nil)
+				store.EXPECT().
+					RotateOAuthRefreshTokenTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RotateOAuthRefreshTokenTxParams) (db.OauthRefreshTokens, error) {
+						require.Equal(t, pkg.HashSecret(refreshToken), arg.HashedToken)
+						require.Equal(t, confidentialClient.ID, arg.ClientID)
+						require.NotEqual(t, arg.HashedToken, arg.NewHashedToken)
+						return db.OauthRefreshTokens{ClientID: arg.ClientID, Username: user.Username, Scopes: scopes}, nil
+					})
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp oauthTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEqual(t, refreshToken, rsp.RefreshToken)
+			},
+		},
+		{
+			name: "刷新令牌无效",
+			form: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {refreshToken},
+				"client_id":     {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).Times(1).Return(publicClient, nil)
+				store.EXPECT().
+					RotateOAuthRefreshTokenTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthRefreshTokens{}, db.ErrOAuthRefreshTokenInvalid)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid_grant")
+			},
+		},
+		{
+			name: "不支持的grant_type",
+			form: url.Values{
+				"grant_type": {"password"},
+				"client_id":  {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid_request")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.OAuthAccessDuration = time.Minute
+			server.config.OAuthRefreshDuration = time.Hour
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(tc.form.Encode()))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.setupAuth != nil {
+				tc.setupAuth(request)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server.tokenMake)
+		})
+	}
+}
+
+func TestOAuthScopeEnforcement(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(t, user.Username)
+	otherAccount := randomAccount(t, "other")
+
+	testCases := []struct {
+		name       string
+		role       string
+		scopes     []string
+		method     string
+		url        string
+		buildStubs func(store *mockdb.MockStore)
+		code       int
+	}{
+		{
+			name:   "授权范围包含该权限",
+			role:   rbac.RoleCustomer,
+			scopes: []string{token.ScopeAccountsRead},
+			method: http.MethodGet,
+			url:    fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name:   "授权范围不包含该权限",
+			role:   rbac.RoleCustomer,
+			scopes: []string{token.ScopeAccountsRead},
+			method: http.MethodPut,
+			url:    "/transfers",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:   "第三方应用只能操作用户自己的资源",
+			role:   rbac.RoleTeller,
+			scopes: []string{token.ScopeAccountsRead},
+			method: http.MethodGet,
+			url:    fmt.Sprintf("/accounts/%d", otherAccount.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(otherAccount.ID)).Times(1).Return(otherAccount, nil)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:   "后台管理接口",
+			role:   rbac.RoleAdmin,
+			scopes: []string{token.ScopeAccountsRead},
+			method: http.MethodGet,
+			url:    "/admin/transfers?page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListRecentTransfers(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:   "凭证管理接口",
+			role:   rbac.RoleCustomer,
+			scopes: []string{token.ScopeAccountsRead},
+			method: http.MethodGet,
+			url:    "/users/api-keys",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListAPIKeys(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			accessToken, _, err := server.tokenMake.CreateScopedToken(user.Username, tc.role, token.Restriction{ClientID: "client", Scopes: tc.scopes}, time.Minute)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			request.Header.Set(constants.AuthorizationHeaderKey, "Bearer "+accessToken)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.code, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/config"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/password"
+)
+
+// newPasswordPolicy 根据配置创建密码强度要求, 配置了已泄露密码文件时在启动时加载
+func newPasswordPolicy(config *config.Config) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength:  config.PasswordMinLength,
+		MinClasses: config.PasswordMinClasses,
+		MinScore:   config.PasswordMinScore,
+	}
+	if config.BreachedPasswordsFile != "" {
+		breaches, err := password.LoadBreachedList(config.BreachedPasswordsFile)
+		if err != nil {
+			return policy, fmt.Errorf("error loading breached passwords: %w", err)
+		}
+		policy.Breaches = breaches
+	}
+	return policy, nil
+}
+
+// checkPasswordPolicy 注册, 重置密码与修改密码时检查新密码, userInputs 为用户名, 邮箱与姓名
+// 不满足要求时返回400, 返回值为false时已经写入响应
+func (s *Server) checkPasswordPolicy(ctx *gin.Context, plain string, userInputs ...string) bool {
+	err := s.passwordPolicy.Check(plain, userInputs...)
+	if err == nil {
+		return true
+	}
+
+	locale := middleware.GetLocale(ctx)
+	minLength, maxLength := s.passwordPolicy.Limits()
+	var message string
+	switch {
+	case errors.Is(err, password.ErrPasswordTooShort):
+		message = i18n.T(locale, i18n.MsgPasswordTooShort, minLength)
+	case errors.Is(err, password.ErrPasswordTooLong):
+		message = i18n.T(locale, i18n.MsgPasswordTooLong, maxLength)
+	case errors.Is(err, password.ErrPasswordClasses):
+		message = i18n.T(locale, i18n.MsgPasswordClasses, s.passwordPolicy.MinClasses)
+	case errors.Is(err, password.ErrPasswordSimilar):
+		message = i18n.T(locale, i18n.MsgPasswordSimilar)
+	case errors.Is(err, password.ErrPasswordWeak):
+		message = i18n.T(locale, i18n.MsgPasswordWeak)
+	case errors.Is(err, password.ErrPasswordBreached):
+		message = i18n.T(locale, i18n.MsgPasswordBreached)
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"message": message})
+	return false
+}
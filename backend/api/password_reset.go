@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/mail"
+)
+
+// 重置密码令牌的字节数
+const passwordResetTokenSize = 32
+
+// 忘记密码, 向注册邮箱发送重置密码的链接
+// 无论邮箱是否注册都返回相同的响应, 防止通过该接口探测已注册的邮箱
+func (s *Server) requestPasswordReset(ctx *gin.Context) {
+	type requestPasswordResetRequest struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	var req requestPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	rsp := gin.H{"message": i18n.T(locale, i18n.MsgPasswordResetSent)}
+
+	user, err := s.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusAccepted, rsp)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resetToken, err := pkg.RandomSecret(passwordResetTokenSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = s.store.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+		HashedToken: pkg.HashSecret(resetToken),
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(s.config.PasswordResetDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	link, err := tokenLink(s.config.PasswordResetURL, resetToken)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      []string{user.Email},
+		Subject: i18n.T(locale, i18n.MsgPasswordResetSubject),
+		Body:    i18n.T(locale, i18n.MsgPasswordResetBody, user.Username, int(s.config.PasswordResetDuration.Minutes()), link),
+	})
+	// 发送失败时同样返回相同的响应, 避免泄露邮箱是否已注册
+	if err != nil {
+		log.Printf("send password reset email to %s: %v", user.Username, err)
+	}
+
+	ctx.JSON(http.StatusAccepted, rsp)
+}
+
+// tokenLink 将令牌作为token查询参数附加到页面的地址上
+func tokenLink(baseURL string, secret string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("token", secret)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// 使用重置密码的令牌设置新密码, 成功之后该用户此前的所有会话与令牌全部失效
+func (s *Server) confirmPasswordReset(ctx *gin.Context) {
+	type confirmPasswordResetRequest struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+
+	var req confirmPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	// 检查新密码需要知道令牌属于哪个用户, 令牌在 ResetPasswordTx 中才会被标记为已使用
+	locale := middleware.GetLocale(ctx)
+	hashedToken := pkg.HashSecret(req.Token)
+	resetToken, err := s.store.GetPasswordResetToken(ctx, hashedToken)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgPasswordResetInvalid)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	user, err := s.store.GetUser(ctx, resetToken.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !s.checkPasswordPolicy(ctx, req.NewPassword, user.Username, user.Email, user.FullName) {
+		return
+	}
+
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	event := newAuditEvent(ctx, user.Username, auditPasswordReset, auditResourceUser, user.Username)
+	event.Before = newUserAuditSnapshot(user)
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		user, err = s.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+			HashedToken:    hashedToken,
+			HashedPassword: hashedPassword,
+		})
+		event.After = newUserAuditSnapshot(user)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrPasswordResetTokenInvalid) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgPasswordResetInvalid)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err = s.revocations.RevokeAll(ctx, user.Username, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/mail"
+)
+
+// recordingMailer 记录发送的邮件, 用于测试
+type recordingMailer struct {
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mail.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func TestRequestPasswordResetAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreatePasswordResetToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreatePasswordResetTokenParams) (db.PasswordResetTokens, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.PasswordResetTokens{HashedToken: arg.HashedToken, Username: arg.Username}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, mailer.messages, 1)
+				require.Equal(t, []string{user.Email}, mailer.messages[0].To)
+				require.Contains(t, mailer.messages[0].Body, "https://bank.example.com/reset-password?token=")
+			},
+		},
+		{
+			name: "邮箱未注册",
+			body: gin.H{"email": pkg.RandomEmail(6)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.Users{}, sql.ErrNoRows)
+				store.EXPECT().CreatePasswordResetToken(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				// 与邮箱已注册时的响应相同
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name: "邮箱格式错误",
+			body: gin.H{"email": "not-an-email"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.PasswordResetURL = "https://bank.example.com/reset-password"
+			mailer := &recordingMailer{}
+			server.mailer = mailer
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
+
+func TestConfirmPasswordResetAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	resetToken := pkg.RandomString(32)
+	newPassword := pkg.RandomString(8)
+	stubResetToken := func(store *mockdb.MockStore) {
+		store.EXPECT().
+			GetPasswordResetToken(gomock.Any(), gomock.Eq(pkg.HashSecret(resetToken))).
+			Times(1).
+			Return(db.PasswordResetTokens{Username: user.Username}, nil)
+		store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"token": resetToken, "newPassword": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubResetToken(store)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ResetPasswordTxParams) (db.Users, error) {
+						require.Equal(t, pkg.HashSecret(resetToken), arg.HashedToken)
+						_, err := testPasswords.Verify(newPassword, arg.HashedPassword)
+						require.NoError(t, err)
+						return user, nil
+					})
+				// 修改密码之后撤销该用户此前颁发的所有令牌
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RevokeUserTokensParams) error {
+						require.Equal(t, user.Username, arg.Username)
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "令牌无效",
+			body: gin.H{"token": resetToken, "newPassword": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetPasswordResetToken(gomock.Any(), gomock.Eq(pkg.HashSecret(resetToken))).
+					Times(1).
+					Return(db.PasswordResetTokens{}, sql.ErrNoRows)
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "令牌同时被使用",
+			body: gin.H{"token": resetToken, "newPassword": newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubResetToken(store)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Users{}, db.ErrPasswordResetTokenInvalid)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "密码过短",
+			body: gin.H{"token": resetToken, "newPassword": "123"},
+			buildStubs: func(store *mockdb.MockStore) {
+				stubResetToken(store)
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/password/reset/confirm", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestTokenLink(t *testing.T) {
+	link, err := tokenLink("https://bank.example.com/reset?lang=en", "a+b/c")
+	require.NoError(t, err)
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	require.Equal(t, "a+b/c", u.Query().Get("token"))
+	require.Equal(t, "en", u.Query().Get("lang"))
+	require.True(t, strings.HasPrefix(link, "https://bank.example.com/reset?"))
+}
@@ -1,9 +1,23 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/apikey"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/mail"
+	"simple_bank/pkg/password"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/revocation"
+	"strings"
+	"sync"
+	"time"
 
 	"simple_bank/config"
 
@@ -17,22 +31,85 @@ import (
 )
 
 type Server struct {
-	config    *config.Config
-	store     db.Store
-	tokenMake token.Maker
-	router    *gin.Engine
+	config      *config.Config
+	store       db.Store
+	tokenMake   token.Maker
+	revocations *revocation.PostgresStore
+	apiKeys     *apikey.PostgresVerifier
+	lockout     *lockout.Guard
+	passwords   *password.Manager
+	mailer      mail.Mailer
+	router      *gin.Engine
+
+	// 按货币配置的需要加强验证的转账金额
+	stepUpThresholds map[string]int64
+	// 设置新密码时的强度要求
+	passwordPolicy password.Policy
+	// 用户不存在时用于校验的密码散列值, 第一次使用时生成
+	dummyPasswordHash func() string
 }
 
 func NewServer(config *config.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("error creating token maker: %w", err)
 	}
 
+	mailer, err := mail.NewMailer(mail.Config{
+		Type:     config.MailerType,
+		From:     config.MailSender,
+		Dir:      config.MailDir,
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating mailer: %w", err)
+	}
+
+	passwords, err := password.New(password.Config{
+		Algorithm:  config.PasswordHashAlgorithm,
+		BcryptCost: config.BcryptCost,
+		Argon2id: password.Argon2idParams{
+			Memory:      config.Argon2Memory,
+			Iterations:  config.Argon2Iterations,
+			Parallelism: config.Argon2Parallelism,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating password hasher: %w", err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	stepUpThresholds, err := parseStepUpThresholds(config.StepUpThresholds)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
-		config:    config,
-		store:     store,
-		tokenMake: tokenMaker,
+		config:      config,
+		store:       store,
+		tokenMake:   tokenMaker,
+		revocations: revocation.NewPostgresStore(store, config.RevocationCacheTTL),
+		apiKeys:     apikey.NewPostgresVerifier(store, config.APIKeyTouchInterval),
+		lockout:     newLoginGuard(config, store),
+		passwords:   passwords,
+		mailer:      mailer,
+
+		stepUpThresholds: stepUpThresholds,
+		passwordPolicy:   passwordPolicy,
+		dummyPasswordHash: sync.OnceValue(func() string {
+			hashedPassword, err := passwords.Hash(pkg.RandomString(16))
+			if err != nil {
+				panic(err)
+			}
+			return hashedPassword
+		}),
 	}
 
 	server.setupRouter()
@@ -42,45 +119,322 @@ func NewServer(config *config.Config, store db.Store) (*Server, error) {
 		if err != nil {
 			log.Fatalf("error registering validation: %v", err)
 		}
+		err = validate.RegisterValidation("role", validRole)
+		if err != nil {
+			log.Fatalf("error registering validation: %v", err)
+		}
+		err = validate.RegisterValidation("permission", validPermission)
+		if err != nil {
+			log.Fatalf("error registering validation: %v", err)
+		}
+		err = validate.RegisterValidation("oauthscope", validOAuthScope)
+		if err != nil {
+			log.Fatalf("error registering validation: %v", err)
+		}
+		err = registerTranslations(validate)
+		if err != nil {
+			log.Fatalf("error registering validation translations: %v", err)
+		}
 	}
 
 	return server, nil
 }
 
+// newTokenMaker 配置了密钥目录时使用支持密钥轮换的密钥环, 否则使用单个密钥
+func newTokenMaker(config *config.Config) (token.Maker, error) {
+	if config.TokenKeyDir == "" {
+		return token.NewMaker(config.TokenType, config.TokenSymmetricKey, config.TokenPrivateKeyFile)
+	}
+
+	keys, err := token.LoadKeyDir(config.TokenKeyDir)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewKeyringMaker(keys)
+}
+
+// newLoginGuard 按用户名与客户端IP分别限制连续登录失败的次数
+func newLoginGuard(config *config.Config, store db.Store) *lockout.Guard {
+	user := lockout.Policy{
+		FreeFailures:    config.LoginFreeFailures,
+		MaxFailures:     config.LoginMaxFailures,
+		BaseDelay:       config.LoginDelayBase,
+		MaxDelay:        config.LoginDelayMax,
+		LockoutDuration: config.LoginLockoutDuration,
+		Window:          config.LoginFailureWindow,
+	}
+	ip := user
+	ip.FreeFailures = config.LoginIPFreeFailures
+	ip.MaxFailures = config.LoginIPMaxFailures
+	return lockout.NewGuard(store, user, ip)
+}
+
 func (s *Server) setupRouter() {
 	// 	gin.SetMode(gin.ReleaseMode)
 	routes := gin.Default()
 	routes.Use(middleware.Cors())
+	routes.Use(middleware.Locale())
+	routes.Use(middleware.RequestID())
 
 	// 创建单个用户
 	routes.PUT("/users", s.CreateUser)
 
 	// 用户登录
 	routes.POST("/users/login", s.loginUser)
+	// 开启了两步验证的用户使用登录挑战与一次性密码完成登录
+	routes.POST("/users/login/mfa", s.loginUserMfa)
 
-	// 查询单个用户
-	authGroup := routes.Group("/").Use(middleware.AuthWebTokenMiddleware(s.tokenMake))
+	// 忘记密码: 发送重置密码的邮件, 使用邮件中的令牌设置新密码
+	routes.POST("/users/password/reset", s.requestPasswordReset)
+	routes.POST("/users/password/reset/confirm", s.confirmPasswordReset)
+
+	// 使用注册时邮件中的令牌验证邮箱
+	routes.POST("/users/email/verify", s.verifyEmail)
+
+	// 使用刷新令牌换取新的访问令牌
+	routes.POST("/tokens/renew", s.renewAccessToken)
 
+	// 发布校验令牌的公钥
+	routes.GET("/.well-known/jwks.json", s.getJWKS)
+
+	// 第三方应用使用授权码或刷新令牌换取访问令牌
+	routes.POST("/oauth/token", s.oauthToken)
+
+	// 用户自身与凭证相关的接口只接受用户本人不受限的访问令牌, API密钥与受限令牌不能用于管理会话与凭证
+	authGroup := routes.Group("/").Use(
+		middleware.AuthWebTokenMiddleware(s.tokenMake, s.revocations, nil, s.config.TokenAudience),
+		middleware.FullAccessOnly(),
+	)
+
+	// 查询单个用户
 	authGroup.GET("/users", s.GetUser)
 
-	// 创建单个账户
-	authGroup.PUT("/accounts", s.createAccount)
+	// 退出登录, 撤销当前的访问令牌
+	authGroup.POST("/users/logout", s.logoutUser)
+	// 退出所有设备, 撤销该用户的所有令牌与会话
+	authGroup.POST("/users/logout-all", s.logoutAllDevices)
+	// 修改密码, 此前颁发的令牌全部失效
+	authGroup.PUT("/users/password", s.changePassword)
+	// 修改姓名与邮箱, 新邮箱验证之后才生效
+	authGroup.PATCH("/users/me", s.updateProfile)
+	// 导出个人数据, 以及删除用户: 关闭余额为0的账户并清除个人信息, 保留账目
+	authGroup.GET("/users/me/export", s.exportUserData)
+	authGroup.DELETE("/users/me", s.deleteUser)
+	// 重新发送验证邮箱的邮件
+	authGroup.POST("/users/email/verify/resend", s.resendEmailVerification)
+
+	// 绑定TOTP两步验证, 确认之后开启, 以及关闭两步验证
+	authGroup.POST("/users/totp", s.enrollTotp)
+	authGroup.POST("/users/totp/confirm", s.confirmTotp)
+	authGroup.DELETE("/users/totp", s.disableTotp)
+
+	// 创建, 列出与删除API密钥
+	authGroup.POST("/users/api-keys", s.createAPIKey)
+	authGroup.GET("/users/api-keys", s.listAPIKeys)
+	authGroup.DELETE("/users/api-keys/:id", s.deleteAPIKey)
+
+	// 注册, 列出与删除第三方应用
+	authGroup.POST("/oauth/clients", s.createOAuthClient)
+	authGroup.GET("/oauth/clients", s.listOAuthClients)
+	authGroup.DELETE("/oauth/clients/:id", s.deleteOAuthClient)
+	// 授权页面: 查询授权请求, 同意或拒绝授权
+	authGroup.GET("/oauth/authorize", s.getAuthorizationRequest)
+	authGroup.POST("/oauth/authorize", s.approveAuthorization)
+	// 列出与撤销对第三方应用的授权
+	authGroup.GET("/oauth/consents", s.listOAuthConsents)
+	authGroup.DELETE("/oauth/consents/:client_id", s.revokeOAuthConsent)
+
+	// 重新验证身份, 换取大额转账所需的加强验证令牌
+	authGroup.POST("/users/step-up", s.stepUp)
+
+	// 申请缩小授权范围的受限令牌, 例如交给记账应用的只读令牌
+	authGroup.POST("/tokens/scoped", s.createScopedToken)
+
+	// 业务接口同时接受访问令牌与API密钥, 各个路由声明所需的权限, 受限令牌还要求各路由组的授权范围
+	apiGroup := routes.Group("/")
+	apiGroup.Use(middleware.AuthWebTokenMiddleware(s.tokenMake, s.revocations, s.apiKeys, s.config.TokenAudience))
+
+	// 查询接口接受只读令牌
+	readGroup := apiGroup.Group("/", middleware.RequireScope(token.ScopeAccountsRead))
 	// 获取单个账户信息
-	authGroup.GET("/accounts/:id", s.getAccount)
+	readGroup.GET("/accounts/:id", middleware.Authorize(rbac.AccountsRead), s.getAccount)
 	// 获取账户列表信息
-	authGroup.GET("/accounts", s.listAccount)
+	readGroup.GET("/accounts", middleware.Authorize(rbac.AccountsRead), s.listAccount)
+
+	// 创建单个账户
+	apiGroup.Group("/", middleware.RequireScope(token.ScopeAccountsWrite)).
+		PUT("/accounts", middleware.Authorize(rbac.AccountsCreate), s.createAccount)
 
 	// 创建转账记录
-	authGroup.PUT("/transfers", s.createTransfer)
+	apiGroup.Group("/", middleware.RequireScope(token.ScopeTransfersWrite)).
+		PUT("/transfers", middleware.Authorize(rbac.TransfersCreate), s.createTransfer)
+
+	// 后台管理接口, 各个路由声明所需的权限
+	adminGroup := routes.Group("/admin").Use(
+		middleware.AuthWebTokenMiddleware(s.tokenMake, s.revocations, s.apiKeys, s.config.TokenAudience),
+		middleware.FullAccessOnly(),
+	)
+	// 解除用户名或客户端IP的登录锁定
+	adminGroup.POST("/users/:username/unlock", middleware.Authorize(rbac.LoginUnlock), s.unlockUserLogin)
+	adminGroup.POST("/ips/:ip/unlock", middleware.Authorize(rbac.LoginUnlock), s.unlockIPLogin)
+	// 修改用户的角色
+	adminGroup.PUT("/users/:username/role", middleware.Authorize(rbac.UsersRoles), s.updateUserRole)
+	// 搜索用户
+	adminGroup.GET("/users", middleware.Authorize(rbac.UsersSearch), s.searchUsers)
+	// 查询任意用户的账户与条目, 以及余额调整记录
+	adminGroup.GET("/accounts/:id", middleware.Authorize(rbac.AccountsRead), s.getAccountWithEntries)
+	adminGroup.GET("/accounts/:id/adjustments", middleware.Authorize(rbac.AccountsRead), s.listAccountAdjustments)
+	// 冻结与解冻账户
+	adminGroup.POST("/accounts/:id/freeze", middleware.Authorize(rbac.AccountsFreeze), s.freezeAccount)
+	adminGroup.POST("/accounts/:id/unfreeze", middleware.Authorize(rbac.AccountsFreeze), s.unfreezeAccount)
+	// 修正账户余额
+	adminGroup.POST("/accounts/:id/adjustments", middleware.Authorize(rbac.AccountsAdjust), s.adjustAccountBalance)
+	// 全行最近的转账记录
+	adminGroup.GET("/transfers", middleware.Authorize(rbac.TransfersRead), s.listRecentTransfers)
+
+	// 审计日志只有审计员可以查询
+	auditGroup := routes.Group("/audit").Use(
+		middleware.AuthWebTokenMiddleware(s.tokenMake, s.revocations, s.apiKeys, s.config.TokenAudience),
+		middleware.FullAccessOnly(),
+	)
+	auditGroup.GET("/events", middleware.Authorize(rbac.AuditRead), s.listAuditEvents)
 
 	s.router = routes
 }
 
-// Start 启动
-func (s *Server) Start(address string) error {
-	return s.router.Run(address)
+// Start 在address上监听并启动服务, 直到ctx被取消, 见 Serve
+func (s *Server) Start(ctx context.Context, address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve 在listener上处理请求并运行后台任务, ctx被取消之后优雅退出:
+// 停止接受新的连接, 等待处理中的请求完成, 再停止后台任务.
+// 超过 ShutdownTimeout 时强制关闭剩余的连接, 返回超时错误
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	s.startWorkers(workerCtx, &workers)
+
+	httpServer := &http.Server{
+		Handler:           s.router,
+		ReadHeaderTimeout: s.config.HTTPReadTimeout,
+		ReadTimeout:       s.config.HTTPReadTimeout,
+		WriteTimeout:      s.config.HTTPWriteTimeout,
+		IdleTimeout:       s.config.HTTPIdleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stopWorkers()
+		workers.Wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", s.config.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		// 超时之后仍未完成的请求被中断
+		err = errors.Join(fmt.Errorf("drain requests: %w", err), httpServer.Close())
+	}
+
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		err = errors.Join(err, fmt.Errorf("stop background workers: %w", shutdownCtx.Err()))
+	}
+	return err
+}
+
+// startWorkers 启动后台任务, ctx被取消之后各个任务退出
+func (s *Server) startWorkers(ctx context.Context, workers *sync.WaitGroup) {
+	run := func(worker func()) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker()
+		}()
+	}
+
+	// 定期清理已过期的令牌撤销记录
+	run(func() { s.revocations.Run(ctx, time.Hour) })
+	// 定期清理已过期的两步验证登录挑战, 重置密码与邮箱验证的令牌, 以及过期的登录失败记录
+	run(func() { s.purgeExpiredTokens(ctx, time.Hour) })
+	// 定期重新加载密钥目录, 使新加入的密钥按计划生效
+	if keyring, ok := s.tokenMake.(*token.KeyringMaker); ok && s.config.TokenKeyReload > 0 {
+		run(func() { keyring.Run(ctx, s.config.TokenKeyDir, s.config.TokenKeyReload) })
+	}
+	// 定期记录审计散列链的检查点
+	if s.config.AuditCheckpointPeriod > 0 {
+		run(func() { s.checkpointAuditChain(ctx, s.config.AuditCheckpointPeriod) })
+	}
+}
+
+// purgeExpiredTokens 每隔interval清理一次已过期的一次性令牌, 直到ctx被取消
+func (s *Server) purgeExpiredTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.store.DeleteExpiredMfaChallenges(ctx); err != nil {
+				log.Printf("purge expired mfa challenges: %v", err)
+			}
+			if err := s.store.DeleteExpiredPasswordResetTokens(ctx); err != nil {
+				log.Printf("purge expired password reset tokens: %v", err)
+			}
+			if err := s.store.DeleteExpiredEmailVerifications(ctx); err != nil {
+				log.Printf("purge expired email verifications: %v", err)
+			}
+			if err := s.lockout.Purge(ctx); err != nil {
+				log.Printf("purge stale login failures: %v", err)
+			}
+			if err := s.store.DeleteExpiredOAuthAuthorizationCodes(ctx); err != nil {
+				log.Printf("purge expired oauth authorization codes: %v", err)
+			}
+			if err := s.store.DeleteExpiredOAuthRefreshTokens(ctx); err != nil {
+				log.Printf("purge expired oauth refresh tokens: %v", err)
+			}
+		}
+	}
 }
 
 func errorResponse(err error) gin.H {
 	return gin.H{"error": err.Error()}
 }
+
+// bindingErrorResponse 参数绑定错误的响应, 校验错误会按请求协商出的语言翻译
+func bindingErrorResponse(ctx *gin.Context, err error) gin.H {
+	fields, messages, ok := translateValidationErrors(middleware.GetLocale(ctx), err)
+	if !ok {
+		return errorResponse(err)
+	}
+	fieldErrors := make(map[string]string, len(fields))
+	for i, field := range fields {
+		fieldErrors[field] = messages[i]
+	}
+	return gin.H{
+		"error":  strings.Join(messages, "; "),
+		"fields": fieldErrors,
+	}
+}
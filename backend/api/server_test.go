@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdb "simple_bank/db/mock"
+)
+
+// startTestServe 在随机端口上运行Serve, 注册一个等待release之后才返回的接口
+func startTestServe(t *testing.T, shutdownTimeout time.Duration) (url string, started chan struct{}, release chan struct{}, cancel context.CancelFunc, done chan error) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+	server.config.ShutdownTimeout = shutdownTimeout
+	started = make(chan struct{})
+	release = make(chan struct{})
+	server.router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		<-release
+		ctx.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done = make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, listener)
+	}()
+	return "http://" + listener.Addr().String(), started, release, cancel, done
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	url, started, release, cancel, done := startTestServe(t, 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		rsp, err := http.Get(url + "/slow")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer rsp.Body.Close()
+		body, err := io.ReadAll(rsp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// 退出之后不再接受新的连接
+	require.Eventually(t, func() bool {
+		_, err := net.DialTimeout("tcp", url[len("http://"):], 100*time.Millisecond)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	// 处理中的请求正常完成之后才退出
+	select {
+	case err := <-done:
+		t.Fatalf("server stopped before the in-flight request finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	rsp := <-responses
+	require.NoError(t, rsp.err)
+	require.Equal(t, "done", rsp.body)
+	require.NoError(t, <-done)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	url, started, release, cancel, done := startTestServe(t, 50*time.Millisecond)
+	defer close(release)
+
+	go func() {
+		rsp, err := http.Get(url + "/slow")
+		if err == nil {
+			rsp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	// 超过期限之后强制关闭连接并返回超时错误
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop after the shutdown timeout")
+	}
+}
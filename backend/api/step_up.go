@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/token"
+)
+
+// 重新验证身份的方式
+const (
+	stepUpMethodPassword = "password"
+	stepUpMethodTotp     = "totp"
+)
+
+// 大额转账缺少有效的加强验证令牌时的响应, 客户端据此引导用户重新验证身份
+type stepUpChallengeResponse struct {
+	Error          string   `json:"error"`
+	StepUpRequired bool     `json:"stepUpRequired"`
+	Currency       string   `json:"currency"`
+	Threshold      int64    `json:"threshold"`
+	Methods        []string `json:"methods"`
+	StepUpURL      string   `json:"stepUpUrl"`
+	MaxAge         int64    `json:"maxAge"`
+}
+
+// parseStepUpThresholds 解析按货币配置的加强验证金额, 格式为 USD:100000,CNY:700000
+func parseStepUpThresholds(spec string) (map[string]int64, error) {
+	thresholds := make(map[string]int64)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		currency, amount, ok := strings.Cut(item, ":")
+		if !ok || !pkg.IsSupportedCurrency(currency) {
+			return nil, fmt.Errorf("invalid step-up threshold %q", item)
+		}
+		threshold, err := strconv.ParseInt(amount, 10, 64)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid step-up threshold %q", item)
+		}
+		thresholds[currency] = threshold
+	}
+	return thresholds, nil
+}
+
+// 重新输入密码或一次性密码, 换取短期的加强验证令牌, 用于大额转账等敏感操作
+func (s *Server) stepUp(ctx *gin.Context) {
+	type stepUpRequest struct {
+		Password     string `json:"password" binding:"required_without_all=Code RecoveryCode"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	type stepUpResponse struct {
+		StepUpToken          string    `json:"stepUpToken"`
+		StepUpTokenExpiresAt time.Time `json:"stepUpTokenExpiresAt"`
+	}
+
+	var req stepUpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	if req.Password != "" {
+		user, err := s.store.GetUser(ctx, payload.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if !s.checkPassword(ctx, user, req.Password, auditStepUpFailed) {
+			return
+		}
+	} else if !s.checkSecondFactor(ctx, payload.Username, req.Code, req.RecoveryCode, auditStepUpFailed) {
+		return
+	}
+
+	// 加强验证令牌只带有 step_up 授权范围, 不能用来访问任何业务接口
+	restriction := token.Restriction{
+		Scopes:   []string{token.ScopeStepUp},
+		Audience: s.tokenAudience(),
+		AuthTime: time.Now(),
+	}
+	stepUpToken, stepUpPayload, err := s.tokenMake.CreateScopedToken(payload.Username, payload.Role, restriction, s.config.StepUpDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, stepUpResponse{
+		StepUpToken:          stepUpToken,
+		StepUpTokenExpiresAt: stepUpPayload.ExpiresAt.Time,
+	})
+}
+
+// requireStepUp 转账金额超过该货币的阈值时, 要求请求头中带有该用户刚刚签发的加强验证令牌
+// 校验未通过时返回加强验证的挑战, 返回值为false时已经写入响应
+func (s *Server) requireStepUp(ctx *gin.Context, username string, currency string, amount int64) bool {
+	threshold, ok := s.stepUpThresholds[currency]
+	if !ok || amount <= threshold {
+		return true
+	}
+
+	locale := middleware.GetLocale(ctx)
+	message := i18n.T(locale, i18n.MsgStepUpRequired, threshold, currency)
+	if stepUpToken := ctx.GetHeader(constants.StepUpHeaderKey); stepUpToken != "" {
+		payload, err := s.tokenMake.VerifyToken(stepUpToken)
+		if err == nil && s.validStepUp(payload, username) {
+			// 与认证中间件一致, 退出登录或修改密码后此前签发的加强验证令牌也随之失效
+			revoked, err := s.revocations.IsRevoked(ctx, payload)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return false
+			}
+			if !revoked {
+				return true
+			}
+		}
+		message = i18n.T(locale, i18n.MsgStepUpInvalid)
+	}
+
+	enabled, err := s.totpEnabled(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	methods := []string{stepUpMethodPassword}
+	if enabled {
+		methods = append(methods, stepUpMethodTotp)
+	}
+
+	maxAge := int64(s.config.StepUpDuration.Seconds())
+	ctx.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_user_authentication", max_age=%d`, maxAge))
+	ctx.JSON(http.StatusUnauthorized, stepUpChallengeResponse{
+		Error:          message,
+		StepUpRequired: true,
+		Currency:       currency,
+		Threshold:      threshold,
+		Methods:        methods,
+		StepUpURL:      "/users/step-up",
+		MaxAge:         maxAge,
+	})
+	return false
+}
+
+// validStepUp 加强验证令牌必须属于当前用户, 颁发给本服务, 并且重新验证身份的时间没有超过有效期
+func (s *Server) validStepUp(payload *token.Payload, username string) bool {
+	return payload.Username == username &&
+		payload.ClientID == "" &&
+		slices.Contains(payload.Scopes, token.ScopeStepUp) &&
+		(s.config.TokenAudience == "" || payload.ForAudience(s.config.TokenAudience)) &&
+		payload.AuthenticatedWithin(s.config.StepUpDuration)
+}
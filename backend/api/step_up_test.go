@@ -0,0 +1,477 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+func TestParseStepUpThresholds(t *testing.T) {
+	thresholds, err := parseStepUpThresholds("USD:100000, CNY:700000")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{constants.USD: 100000, constants.CNY: 700000}, thresholds)
+
+	thresholds, err = parseStepUpThresholds("")
+	require.NoError(t, err)
+	require.Empty(t, thresholds)
+
+	for _, spec := range []string{"USD", "EUR:100", "USD:abc", "USD:-1"} {
+		_, err = parseStepUpThresholds(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestStepUpAPI(t *testing.T) {
+	user, password := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	userTotp := confirmedUserTotp(t, user.Username)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			name: "密码验证",
+			body: gin.H{"password": password},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var rsp struct {
+					StepUpToken string `json:"stepUpToken"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+
+				payload, err := tokenMaker.VerifyToken(rsp.StepUpToken)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Username)
+				require.Equal(t, []string{token.ScopeStepUp}, payload.Scopes)
+				require.True(t, payload.AuthenticatedWithin(time.Minute))
+				// 加强验证令牌不能访问业务接口
+				require.False(t, payload.HasScope(token.ScopeAccountsRead))
+			},
+		},
+		{
+			name: "密码错误",
+			body: gin.H{"password": "wrong-password"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				// 与登录共用失败次数, 失败的尝试不撤销
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditStepUpFailed, event.Action)
+						require.Equal(t, user.Username, event.Actor)
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "失败次数过多",
+			body: gin.H{"password": password},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(db.LoginFailures{FailedCount: 5, LastFailedAt: time.Now()}, nil)
+				// 等待期间即使密码正确也不签发令牌
+				store.EXPECT().ReserveLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "未开启两步验证时使用一次性密码",
+			body: gin.H{"code": "123456"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "一次性密码验证",
+			body: gin.H{"code": currentTotpCode(t, userTotp.Secret)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+				store.EXPECT().UseTotpStep(gomock.Any(), gomock.Any()).Times(1).Return(userTotp, nil)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "一次性密码错误",
+			body: gin.H{"code": wrongTotpCode(t, userTotp.Secret)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// 与登录共用失败次数, 失败的尝试不撤销
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditStepUpFailed, event.Action)
+						return nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "猜测一次性密码的次数过多",
+			body: gin.H{"code": currentTotpCode(t, userTotp.Secret)},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(db.LoginFailures{FailedCount: 5, LastFailedAt: time.Now()}, nil)
+				// 等待期间即使一次性密码正确也不校验
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			name: "缺少凭证",
+			body: gin.H{},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "受限令牌",
+			body: gin.H{"password": password},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				restriction := token.Restriction{Scopes: []string{token.ScopeTransfersWrite}}
+				accessToken, _, err := tokenMaker.CreateScopedToken(user.Username, user.Role, restriction, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(constants.AuthorizationHeaderKey, "Bearer "+accessToken)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			server.lockout = lockout.NewGuard(store, testLoginPolicy, lockout.Policy{})
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/step-up", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMake)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server.tokenMake)
+		})
+	}
+}
+
+func TestTransferStepUp(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	user.IsEmailVerified = true
+	fromAccount := db.Accounts{ID: 1, Owner: user.Username, Balance: 100000, Currency: constants.USD}
+	toAccount := db.Accounts{ID: 2, Owner: "other", Currency: constants.USD}
+	toAccountID := toAccount.ID
+
+	// 测试服务器配置的USD阈值为1000
+	testCases := []struct {
+		name          string
+		amount        int64
+		stepUp        func(t *testing.T, tokenMaker token.Maker) string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "未超过阈值",
+			amount: 1000,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				return ""
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(1).Return(toAccount, nil)
+				store.EXPECT().CreateTransfer(gomock.Any(), gomock.Any()).Times(1).Return(db.Transfers{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name:   "超过阈值时返回挑战",
+			amount: 1001,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				return ""
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{ConfirmedAt: &time.Time{}}, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Header().Get("WWW-Authenticate"), "insufficient_user_authentication")
+
+				var rsp stepUpChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.StepUpRequired)
+				require.Equal(t, constants.USD, rsp.Currency)
+				require.Equal(t, int64(1000), rsp.Threshold)
+				require.Equal(t, []string{stepUpMethodPassword, stepUpMethodTotp}, rsp.Methods)
+				require.Equal(t, int64(300), rsp.MaxAge)
+			},
+		},
+		{
+			name:   "加强验证通过",
+			amount: 1001,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				return createStepUpToken(t, tokenMaker, user.Username, time.Now())
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(1).Return(toAccount, nil)
+				store.EXPECT().CreateTransfer(gomock.Any(), gomock.Any()).Times(1).Return(db.Transfers{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name:   "重新验证身份的时间过早",
+			amount: 1001,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				return createStepUpToken(t, tokenMaker, user.Username, time.Now().Add(-10*time.Minute))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+				var rsp stepUpChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, []string{stepUpMethodPassword}, rsp.Methods)
+			},
+		},
+		{
+			name:   "其他用户的加强验证令牌",
+			amount: 1001,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				return createStepUpToken(t, tokenMaker, "other", time.Now())
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "普通访问令牌不能代替加强验证令牌",
+			amount: 1001,
+			stepUp: func(t *testing.T, tokenMaker token.Maker) string {
+				accessToken, _, err := tokenMaker.CreateToken(user.Username, user.Role, time.Minute)
+				require.NoError(t, err)
+				return accessToken
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccountID)).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"fromAccountID": fromAccount.ID,
+				"toAccountID":   toAccountID,
+				"amount":        tc.amount,
+				"currency":      constants.USD,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPut, "/transfers", bytes.NewReader(data))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, user.Role, time.Minute)
+			if stepUpToken := tc.stepUp(t, server.tokenMake); stepUpToken != "" {
+				request.Header.Set(constants.StepUpHeaderKey, stepUpToken)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestTransferStepUpRevoked(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	user.IsEmailVerified = true
+	fromAccount := db.Accounts{ID: 1, Owner: user.Username, Balance: 100000, Currency: constants.USD}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuditTx(store)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+	store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+	store.EXPECT().CreateTransfer(gomock.Any(), gomock.Any()).Times(0)
+
+	// 模拟撤销记录表: 撤销时间之前颁发的令牌均视为已撤销
+	var revokedBefore time.Time
+	store.EXPECT().
+		RevokeUserTokens(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.RevokeUserTokensParams) error {
+			revokedBefore = arg.RevokedBefore
+			return nil
+		})
+	store.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, arg db.IsTokenRevokedParams) (bool, error) {
+			return arg.IssuedAt.Before(revokedBefore), nil
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	// 加强验证令牌在撤销全部令牌之前签发, 之后重新登录得到的访问令牌仍然有效
+	stepUpToken := createStepUpToken(t, server.tokenMake, user.Username, time.Now())
+	time.Sleep(time.Millisecond)
+	require.NoError(t, server.revocations.RevokeAll(context.Background(), user.Username, time.Now()))
+	time.Sleep(time.Millisecond)
+
+	data, err := json.Marshal(gin.H{
+		"fromAccountID": fromAccount.ID,
+		"toAccountID":   2,
+		"amount":        1001,
+		"currency":      constants.USD,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPut, "/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+	addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, user.Role, time.Minute)
+	request.Header.Set(constants.StepUpHeaderKey, stepUpToken)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	var rsp stepUpChallengeResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.True(t, rsp.StepUpRequired)
+}
+
+func createStepUpToken(t *testing.T, tokenMaker token.Maker, username string, authTime time.Time) string {
+	restriction := token.Restriction{Scopes: []string{token.ScopeStepUp}, Audience: []string{"simple_bank"}, AuthTime: authTime}
+	stepUpToken, _, err := tokenMaker.CreateScopedToken(username, rbac.RoleCustomer, restriction, time.Minute)
+	require.NoError(t, err)
+	return stepUpToken
+}
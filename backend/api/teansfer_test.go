@@ -8,8 +8,10 @@ import (
 	"net/http/httptest"
 	"simple_bank/constants"
 	"testing"
+	"time"
 
 	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
 
 	"github.com/stretchr/testify/require"
 
@@ -25,9 +27,7 @@ import (
 const transRoute = "/transfers"
 
 func randomTransferUser(t *testing.T) db.Users {
-	hashedPassword, err := pkg.HashPassword(pkg.RandomString(6))
-	require.NoError(t, err)
-	require.NotNil(t, hashedPassword)
+	hashedPassword := hashTestPassword(t, pkg.RandomString(6))
 
 	user, err := testQueries.CreateUser(context.Background(), db.CreateUserParams{
 		Username:       pkg.RandomString(5),
@@ -147,6 +147,7 @@ func TestTransferAPI(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
 			tc.buildStubs(store)
 
 			server := newTestServer(t, store)
@@ -162,3 +163,96 @@ func TestTransferAPI(t *testing.T) {
 		})
 	}
 }
+
+// TestCreateTransferFromAccount 转出账户校验通过之后继续创建转账, 校验失败时不再查询转入账户
+func TestCreateTransferFromAccount(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+	user.IsEmailVerified = true
+	fromAccount := db.Accounts{ID: 1, Owner: user.Username, Balance: 100, Currency: constants.CNY}
+	toAccount := db.Accounts{ID: 2, Owner: "other", Currency: constants.CNY}
+
+	testCases := []struct {
+		name          string
+		currency      string
+		fromAccount   db.Accounts
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "OK",
+			currency:    constants.CNY,
+			fromAccount: fromAccount,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+				store.EXPECT().
+					CreateTransfer(gomock.Any(), gomock.Eq(db.CreateTransferParams{
+						FromAccountID: fromAccount.ID,
+						ToAccountID:   toAccount.ID,
+						Amount:        10,
+					})).
+					Times(1).
+					Return(db.Transfers{ID: 1, FromAccountID: fromAccount.ID, ToAccountID: toAccount.ID, Amount: 10}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name:        "货币类型不一致",
+			currency:    constants.USD,
+			fromAccount: fromAccount,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(0)
+				store.EXPECT().CreateTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:        "不是自己的账户",
+			currency:    constants.CNY,
+			fromAccount: db.Accounts{ID: fromAccount.ID, Owner: "other", Balance: 100, Currency: constants.CNY},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(0)
+				store.EXPECT().CreateTransfer(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(tc.fromAccount, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{
+				"fromAccountID": fromAccount.ID,
+				"toAccountID":   toAccount.ID,
+				"amount":        10,
+				"currency":      tc.currency,
+			})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, transRoute, bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, user.Role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"simple_bank/constants"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/token"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	db "simple_bank/db/sqlc"
+)
+
+// 使用刷新令牌换取新的访问令牌, 同时轮换刷新令牌
+func (s *Server) renewAccessToken(ctx *gin.Context) {
+	type renewAccessTokenRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+
+	type renewAccessTokenResponse struct {
+		SessionID             uuid.UUID `json:"sessionID"`
+		AccessToken           string    `json:"accessToken"`
+		AccessTokenExpiresAt  time.Time `json:"accessTokenExpiresAt"`
+		RefreshToken          string    `json:"refreshToken"`
+		RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+	}
+
+	var req renewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+	locale := middleware.GetLocale(ctx)
+
+	// 校验刷新令牌的签名与有效期
+	refreshPayload, err := s.tokenMake.VerifyToken(req.RefreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	// 访问令牌与其他用途的令牌不能换取新的令牌
+	if !refreshPayload.IsRefresh() {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgTokenInvalid)})
+		return
+	}
+
+	session, err := s.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgSessionNotFound)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if session.IsBlocked {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgSessionBlocked)})
+		return
+	}
+
+	// 会话中只保存刷新令牌的散列值, 以固定时间比较
+	if session.Username != refreshPayload.Username ||
+		subtle.ConstantTimeCompare([]byte(pkg.HashSecret(req.RefreshToken)), []byte(session.HashedRefreshToken)) != 1 {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgSessionMismatched)})
+		return
+	}
+
+	if s.config.SessionCheckUserAgent && session.UserAgent != ctx.Request.UserAgent() ||
+		s.config.SessionCheckClientIP && session.ClientIp != ctx.ClientIP() {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgSessionMismatched)})
+		return
+	}
+
+	// 已被轮换过的刷新令牌再次被使用, 禁用这次登录轮换出的所有会话
+	if session.RotatedAt != nil {
+		s.blockSessionFamily(ctx, session.FamilyID)
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(locale, i18n.MsgSessionExpired)})
+		return
+	}
+
+	// 修改角色时会禁用该用户的所有会话, 因此刷新令牌中的角色就是当前的角色
+	accessToken, accessPayload, err := s.tokenMake.CreateToken(session.Username, refreshPayload.Role, s.config.AccessTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	refreshToken, newRefreshPayload, err := s.createRefreshToken(session.Username, refreshPayload.Role)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	newSession, err := s.store.RenewSessionTx(ctx, db.RenewSessionTxParams{
+		SessionID: session.ID,
+		NewSession: db.CreateSessionParams{
+			ID:                 newRefreshPayload.ID,
+			Username:           session.Username,
+			HashedRefreshToken: pkg.HashSecret(refreshToken),
+			UserAgent:          ctx.Request.UserAgent(),
+			ClientIp:           ctx.ClientIP(),
+			IsBlocked:          false,
+			ExpiresAt:          newRefreshPayload.ExpiresAt.Time,
+		},
+	})
+	if err != nil {
+		// 并发请求已经轮换了该会话
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			s.blockSessionFamily(ctx, session.FamilyID)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, renewAccessTokenResponse{
+		SessionID:             newSession.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiresAt.Time,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: newSession.ExpiresAt,
+	})
+}
+
+// createRefreshToken 颁发刷新令牌, 令牌带有刷新用途, 认证中间件不接受它访问接口
+func (s *Server) createRefreshToken(username string, role string) (string, *token.Payload, error) {
+	restriction := token.Restriction{Purpose: token.PurposeRefresh}
+	return s.tokenMake.CreateScopedToken(username, role, restriction, s.config.RefreshTokenDuration)
+}
+
+// blockSessionFamily 检测到刷新令牌被重复使用时, 禁用该次登录的所有会话
+func (s *Server) blockSessionFamily(ctx *gin.Context, familyID uuid.UUID) {
+	if err := s.store.BlockSessionFamily(ctx, familyID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgSessionReused)})
+}
+
+// 为当前用户申请缩小授权范围的受限令牌, 例如交给记账应用的只读令牌
+// 受限令牌不能再申请受限令牌, 也不能访问会话与凭证相关的接口
+func (s *Server) createScopedToken(ctx *gin.Context) {
+	type createScopedTokenRequest struct {
+		Scopes    []string `json:"scopes" binding:"required,min=1,dive,oauthscope"`
+		ExpiresIn int64    `json:"expiresIn" binding:"omitempty,gte=60"` // 有效期的秒数, 默认与访问令牌相同
+	}
+
+	type createScopedTokenResponse struct {
+		AccessToken          string    `json:"accessToken"`
+		AccessTokenExpiresAt time.Time `json:"accessTokenExpiresAt"`
+		Scopes               []string  `json:"scopes"`
+	}
+
+	var req createScopedTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	duration := s.config.AccessTokenDuration
+	if req.ExpiresIn > 0 {
+		duration = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if s.config.ScopedMaxDuration > 0 {
+		duration = min(duration, s.config.ScopedMaxDuration)
+	}
+
+	slices.Sort(req.Scopes)
+	scopes := slices.Compact(req.Scopes)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	restriction := token.Restriction{Scopes: scopes, Audience: s.tokenAudience()}
+	accessToken, accessPayload, err := s.tokenMake.CreateScopedToken(payload.Username, payload.Role, restriction, duration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, createScopedTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiresAt.Time,
+		Scopes:               scopes,
+	})
+}
+
+// tokenAudience 受限令牌的受众, 未配置时不限制受众
+func (s *Server) tokenAudience() []string {
+	if s.config.TokenAudience == "" {
+		return nil
+	}
+	return []string{s.config.TokenAudience}
+}
+
+// 发布校验令牌的公钥, 其它服务只需公钥即可校验令牌
+// 使用对称密钥签名令牌时不发布任何公钥
+func (s *Server) getJWKS(ctx *gin.Context) {
+	keySet := token.JWKSet{Keys: []token.JWK{}}
+	if provider, ok := s.tokenMake.(token.KeySetProvider); ok {
+		keySet = provider.JWKS()
+	}
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, keySet)
+}
@@ -0,0 +1,470 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+func TestRenewAccessTokenAPI(t *testing.T) {
+	username := pkg.RandomString(6)
+	familyID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore, session db.Sessions)
+		editSession   func(session *db.Sessions)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					RenewSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RenewSessionTxParams) (db.Sessions, error) {
+						require.Equal(t, session.ID, arg.SessionID)
+						require.Equal(t, username, arg.NewSession.Username)
+						// 会话中保存的是散列值而不是新的刷新令牌
+						require.Len(t, arg.NewSession.HashedRefreshToken, 64)
+						return db.Sessions{
+							ID:                 arg.NewSession.ID,
+							FamilyID:           session.FamilyID,
+							Username:           arg.NewSession.Username,
+							HashedRefreshToken: arg.NewSession.HashedRefreshToken,
+							ExpiresAt:          arg.NewSession.ExpiresAt,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp map[string]any
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp["accessToken"])
+				require.NotEmpty(t, rsp["refreshToken"])
+			},
+		},
+		{
+			name: "会话不存在",
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Sessions{}, sql.ErrNoRows)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "刷新令牌与会话不一致",
+			editSession: func(session *db.Sessions) {
+				session.HashedRefreshToken = pkg.HashSecret(pkg.RandomString(32))
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "会话已被禁用",
+			editSession: func(session *db.Sessions) {
+				session.IsBlocked = true
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "会话已过期",
+			editSession: func(session *db.Sessions) {
+				session.ExpiresAt = time.Now().Add(-time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "刷新令牌被重复使用",
+			editSession: func(session *db.Sessions) {
+				rotatedAt := time.Now().Add(-time.Minute)
+				session.RotatedAt = &rotatedAt
+			},
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "并发轮换同一个刷新令牌",
+			buildStubs: func(store *mockdb.MockStore, session db.Sessions) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					RenewSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Sessions{}, db.ErrRefreshTokenReused)
+				store.EXPECT().
+					BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+
+			refreshToken, payload := createRefreshToken(t, server.tokenMake, username)
+			session := db.Sessions{
+				ID:                 payload.ID,
+				FamilyID:           familyID,
+				Username:           username,
+				HashedRefreshToken: pkg.HashSecret(refreshToken),
+				ExpiresAt:          payload.ExpiresAt.Time,
+			}
+			if tc.editSession != nil {
+				tc.editSession(&session)
+			}
+			tc.buildStubs(store, session)
+
+			body, err := json.Marshal(gin.H{"refreshToken": refreshToken})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/tokens/renew", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRenewAccessTokenWithAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	// 访问令牌不能换取新的令牌, 不查询会话
+	store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().RenewSessionTx(gomock.Any(), gomock.Any()).Times(0)
+
+	accessToken, _, err := server.tokenMake.CreateToken(pkg.RandomString(6), rbac.RoleCustomer, time.Hour)
+	require.NoError(t, err)
+	body, err := json.Marshal(gin.H{"refreshToken": accessToken})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/tokens/renew", bytes.NewReader(body))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func createRefreshToken(t *testing.T, tokenMaker token.Maker, username string) (string, *token.Payload) {
+	restriction := token.Restriction{Purpose: token.PurposeRefresh}
+	refreshToken, payload, err := tokenMaker.CreateScopedToken(username, rbac.RoleCustomer, restriction, time.Hour)
+	require.NoError(t, err)
+	require.NotEmpty(t, refreshToken)
+	return refreshToken, payload
+}
+
+func TestGetJWKSAPI(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicMaker, err := token.NewPasetoV4PublicMaker(privateKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		tokenMaker func(server *Server) token.Maker
+		keys       int
+	}{
+		{
+			name:       "对称密钥不发布公钥",
+			tokenMaker: func(server *Server) token.Maker { return server.tokenMake },
+			keys:       0,
+		},
+		{
+			name:       "非对称密钥",
+			tokenMaker: func(server *Server) token.Maker { return publicMaker },
+			keys:       1,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			server.tokenMake = tc.tokenMaker(server)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var keySet token.JWKSet
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &keySet))
+			require.Len(t, keySet.Keys, tc.keys)
+		})
+	}
+}
+
+func TestCreateScopedTokenAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Role = rbac.RoleCustomer
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"scopes": []string{token.ScopeAccountsRead, token.ScopeAccountsRead}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var rsp struct {
+					AccessToken string   `json:"accessToken"`
+					Scopes      []string `json:"scopes"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, []string{token.ScopeAccountsRead}, rsp.Scopes)
+
+				payload, err := tokenMaker.VerifyToken(rsp.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Username)
+				require.Equal(t, []string{token.ScopeAccountsRead}, payload.Scopes)
+				require.Empty(t, payload.ClientID)
+				require.True(t, payload.ForAudience("simple_bank"))
+			},
+		},
+		{
+			name: "有效期不超过上限",
+			body: gin.H{"scopes": []string{token.ScopeAccountsRead}, "expiresIn": int64((24 * time.Hour).Seconds())},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var rsp struct {
+					AccessTokenExpiresAt time.Time `json:"accessTokenExpiresAt"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.WithinDuration(t, time.Now().Add(time.Hour), rsp.AccessTokenExpiresAt, time.Minute)
+			},
+		},
+		{
+			name: "不支持的授权范围",
+			body: gin.H{"scopes": []string{"accounts:delete"}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, user.Role, time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "受限令牌不能再申请受限令牌",
+			body: gin.H{"scopes": []string{token.ScopeAccountsRead}},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				restriction := token.Restriction{Scopes: []string{token.ScopeAccountsRead}}
+				accessToken, _, err := tokenMaker.CreateScopedToken(user.Username, user.Role, restriction, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(constants.AuthorizationHeaderKey, "Bearer "+accessToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, tokenMaker token.Maker) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/tokens/scoped", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMake)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server.tokenMake)
+		})
+	}
+}
+
+func TestScopedTokenEnforcement(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(t, user.Username)
+
+	testCases := []struct {
+		name        string
+		restriction token.Restriction
+		method      string
+		url         string
+		buildStubs  func(store *mockdb.MockStore)
+		code        int
+	}{
+		{
+			name:        "只读令牌查询账户",
+			restriction: token.Restriction{Scopes: []string{token.ScopeAccountsRead}, Audience: []string{"simple_bank"}},
+			method:      http.MethodGet,
+			url:         fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			},
+			code: http.StatusOK,
+		},
+		{
+			name:        "只读令牌转账",
+			restriction: token.Restriction{Scopes: []string{token.ScopeAccountsRead}},
+			method:      http.MethodPut,
+			url:         "/transfers",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:        "只读令牌创建账户",
+			restriction: token.Restriction{Scopes: []string{token.ScopeAccountsRead}},
+			method:      http.MethodPut,
+			url:         "/accounts",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:        "受众不是本服务",
+			restriction: token.Restriction{Scopes: []string{token.ScopeAccountsRead}, Audience: []string{"other_service"}},
+			method:      http.MethodGet,
+			url:         fmt.Sprintf("/accounts/%d", account.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusUnauthorized,
+		},
+		{
+			name:        "受限令牌管理会话",
+			restriction: token.Restriction{Scopes: []string{token.ScopeAccountsRead, token.ScopeAccountsWrite, token.ScopeTransfersWrite}},
+			method:      http.MethodPost,
+			url:         "/users/logout-all",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().BlockUserSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			accessToken, _, err := server.tokenMake.CreateScopedToken(user.Username, rbac.RoleCustomer, tc.restriction, time.Minute)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			request.Header.Set(constants.AuthorizationHeaderKey, "Bearer "+accessToken)
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.code, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/token"
+	"simple_bank/pkg/totp"
+)
+
+const (
+	// 一次性密码允许前后各一个时间步的时钟偏差
+	totpSkew = 1
+	// 每次确认绑定时生成的恢复码数量
+	recoveryCodeCount = 10
+	// 登录挑战允许的校验次数
+	mfaMaxAttempts = 5
+	// 登录挑战令牌的字节数
+	mfaTokenSize = 32
+)
+
+// 开启两步验证的用户, 密码校验通过之后的响应
+type mfaChallengeResponse struct {
+	MfaRequired       bool      `json:"mfaRequired"`
+	MfaToken          string    `json:"mfaToken"`
+	MfaTokenExpiresAt time.Time `json:"mfaTokenExpiresAt"`
+}
+
+// totpEnabled 用户是否已确认绑定TOTP
+func (s *Server) totpEnabled(ctx *gin.Context, username string) (bool, error) {
+	userTotp, err := s.store.GetUserTotp(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return userTotp.ConfirmedAt != nil, nil
+}
+
+// createMfaChallenge 颁发短期的登录挑战令牌, 数据库中只保存令牌的散列值
+func (s *Server) createMfaChallenge(ctx *gin.Context, username string) {
+	mfaToken, err := pkg.RandomSecret(mfaTokenSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	challenge, err := s.store.CreateMfaChallenge(ctx, db.CreateMfaChallengeParams{
+		HashedToken: pkg.HashSecret(mfaToken),
+		Username:    username,
+		ExpiresAt:   time.Now().Add(s.config.MFAChallengeDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, mfaChallengeResponse{
+		MfaRequired:       true,
+		MfaToken:          mfaToken,
+		MfaTokenExpiresAt: challenge.ExpiresAt,
+	})
+}
+
+// verifySecondFactor 校验一次性密码或恢复码, 校验通过的一次性密码与恢复码都不能再次使用
+func (s *Server) verifySecondFactor(ctx *gin.Context, username string, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		_, err := s.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+			Username:   username,
+			HashedCode: pkg.HashSecret(totp.NormalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	userTotp, err := s.store.GetUserTotp(ctx, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if userTotp.ConfirmedAt == nil {
+		return false, nil
+	}
+
+	step, ok := totp.Validate(userTotp.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	// 记录使用过的时间步, 已经使用过该时间步或更晚的时间步时视为重放
+	_, err = s.store.UseTotpStep(ctx, db.UseTotpStepParams{
+		Username:     username,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// checkSecondFactor 校验已登录用户输入的一次性密码或恢复码, 与登录共用失败次数的限制, 持有令牌也不能无限次猜测
+// 校验失败时以 failedAction 记录审计事件, 返回值为false时已经写入响应
+func (s *Server) checkSecondFactor(ctx *gin.Context, username string, code string, recoveryCode string, failedAction string) bool {
+	failed := newAuditEvent(ctx, username, failedAction, auditResourceUser, username)
+	return s.guardAttempt(ctx, username, failed, i18n.MsgMfaCodeInvalid, func() (bool, error) {
+		return s.verifySecondFactor(ctx, username, code, recoveryCode)
+	})
+}
+
+// 两步验证登录的第二步, 使用登录挑战令牌与一次性密码或恢复码换取访问令牌
+func (s *Server) loginUserMfa(ctx *gin.Context) {
+	type loginUserMfaRequest struct {
+		MfaToken     string `json:"mfaToken" binding:"required"`
+		Code         string `json:"code" binding:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	var req loginUserMfaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	hashedToken := pkg.HashSecret(req.MfaToken)
+	// 校验之前先增加尝试次数, 已使用, 已过期或次数用完的挑战不会返回, 并发的尝试不会超过上限
+	challenge, err := s.store.IncrementMfaChallengeAttempts(ctx, db.IncrementMfaChallengeAttemptsParams{
+		HashedToken: hashedToken,
+		MaxAttempts: mfaMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"message": i18n.T(locale, i18n.MsgMfaChallengeInvalid)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// 一次性密码的每次失败都计入该用户名的登录失败次数, 重新登录获取新的挑战也不能继续猜测
+	failed := newAuditEvent(ctx, challenge.Username, auditLoginFailed, auditResourceUser, challenge.Username)
+	failed.After = gin.H{"mfaAttempts": challenge.Attempts}
+	verified := s.guardAttempt(ctx, challenge.Username, failed, i18n.MsgMfaCodeInvalid, func() (bool, error) {
+		return s.verifySecondFactor(ctx, challenge.Username, req.Code, req.RecoveryCode)
+	})
+	if !verified {
+		return
+	}
+
+	// 并发完成同一个挑战时只有一个请求能够成功
+	if _, err = s.store.CompleteMfaChallenge(ctx, hashedToken); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"message": i18n.T(locale, i18n.MsgMfaChallengeInvalid)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := s.store.GetUser(ctx, challenge.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := s.loginSucceeded(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// 绑定TOTP的第一步, 生成新的密钥, 用户使用身份验证器扫描otpauth URI之后再确认绑定
+func (s *Server) enrollTotp(ctx *gin.Context) {
+	type enrollTotpResponse struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauthURI"`
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	enabled, err := s.totpEnabled(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if enabled {
+		ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgTotpAlreadyEnabled)})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// 重复绑定时覆盖尚未确认的密钥
+	userTotp, err := s.store.UpsertUserTotp(ctx, db.UpsertUserTotpParams{
+		Username: payload.Username,
+		Secret:   secret,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTotpResponse{
+		Secret:     userTotp.Secret,
+		OtpauthURI: totp.URI(s.config.TOTPIssuer, payload.Username, userTotp.Secret),
+	})
+}
+
+// 绑定TOTP的第二步, 校验一次性密码之后开启两步验证, 并返回恢复码
+// 恢复码只在此时以明文返回一次
+func (s *Server) confirmTotp(ctx *gin.Context) {
+	type confirmTotpRequest struct {
+		Code string `json:"code" binding:"required,len=6,numeric"`
+	}
+	type confirmTotpResponse struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+
+	var req confirmTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	userTotp, err := s.store.GetUserTotp(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, gin.H{"message": i18n.T(locale, i18n.MsgTotpNotEnrolled)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if userTotp.ConfirmedAt != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(locale, i18n.MsgTotpAlreadyEnabled)})
+		return
+	}
+
+	step, ok := totp.Validate(userTotp.Secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgMfaCodeInvalid)})
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	hashedCodes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashedCodes[i] = pkg.HashSecret(code)
+	}
+
+	// TOTP密钥与恢复码不写入审计日志, 只记录开启状态
+	event := newAuditEvent(ctx, payload.Username, auditTotpEnable, auditResourceUser, payload.Username)
+	event.Before = gin.H{"totpEnabled": false}
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		_, err := s.store.ConfirmTotpTx(ctx, db.ConfirmTotpTxParams{
+			Username:            payload.Username,
+			Step:                step,
+			HashedRecoveryCodes: hashedCodes,
+		})
+		event.After = gin.H{"totpEnabled": true}
+		return err
+	})
+	if err != nil {
+		// 并发确认时只有一个请求能够成功
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(locale, i18n.MsgTotpAlreadyEnabled)})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTotpResponse{RecoveryCodes: recoveryCodes})
+}
+
+// 关闭两步验证, 需要提供一次性密码或恢复码
+func (s *Server) disableTotp(ctx *gin.Context) {
+	type disableTotpRequest struct {
+		Code         string `json:"code" binding:"required_without=RecoveryCode"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	var req disableTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	enabled, err := s.totpEnabled(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !enabled {
+		ctx.JSON(http.StatusNotFound, gin.H{"message": i18n.T(locale, i18n.MsgTotpNotEnrolled)})
+		return
+	}
+
+	if !s.checkSecondFactor(ctx, payload.Username, req.Code, req.RecoveryCode, auditTotpDisableFailed) {
+		return
+	}
+
+	event := newAuditEvent(ctx, payload.Username, auditTotpDisable, auditResourceUser, payload.Username)
+	event.Before = gin.H{"totpEnabled": true}
+	event.After = gin.H{"totpEnabled": false}
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		return s.store.DisableTotpTx(ctx, payload.Username)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
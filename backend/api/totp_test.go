@@ -0,0 +1,494 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/totp"
+)
+
+func confirmedUserTotp(t *testing.T, username string) db.UserTotp {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	confirmedAt := time.Now()
+	return db.UserTotp{
+		Username:    username,
+		Secret:      secret,
+		ConfirmedAt: &confirmedAt,
+	}
+}
+
+func currentTotpCode(t *testing.T, secret string) string {
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+// wrongTotpCode 修改当前验证码的最后一位, 保证与正确的验证码不同
+func wrongTotpCode(t *testing.T, secret string) string {
+	code := currentTotpCode(t, secret)
+	return code[:5] + string('0'+(code[5]-'0'+1)%10)
+}
+
+func TestLoginUserWithTotpAPI(t *testing.T) {
+	user, password := randomUser(t)
+	userTotp := confirmedUserTotp(t, user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+	store.EXPECT().
+		CreateMfaChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateMfaChallengeParams) (db.MfaChallenges, error) {
+			require.Equal(t, user.Username, arg.Username)
+			return db.MfaChallenges{HashedToken: arg.HashedToken, Username: arg.Username, ExpiresAt: arg.ExpiresAt}, nil
+		})
+	// 完成两步验证之前不颁发令牌
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	body, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var rsp map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, true, rsp["mfaRequired"])
+	require.NotEmpty(t, rsp["mfaToken"])
+	require.Nil(t, rsp["accessToken"])
+}
+
+func TestLoginUserMfaAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	userTotp := confirmedUserTotp(t, user.Username)
+	mfaToken := pkg.RandomString(32)
+	hashedToken := pkg.HashSecret(mfaToken)
+	challenge := db.MfaChallenges{
+		HashedToken: hashedToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	incrementArg := db.IncrementMfaChallengeAttemptsParams{HashedToken: hashedToken, MaxAttempts: mfaMaxAttempts}
+
+	testCases := []struct {
+		name          string
+		body          func() gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken, "code": currentTotpCode(t, userTotp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Eq(incrementArg)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+				store.EXPECT().
+					UseTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UseTotpStepParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return userTotp, nil
+					})
+				store.EXPECT().CompleteMfaChallenge(gomock.Any(), gomock.Eq(hashedToken)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.Sessions, error) {
+						return db.Sessions{ID: arg.ID, FamilyID: arg.FamilyID, Username: arg.Username}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp map[string]any
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp["accessToken"])
+				require.NotEmpty(t, rsp["refreshToken"])
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken, "recoveryCode": " ABCDE-FGHIJ "}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Eq(incrementArg)).Times(1).Return(challenge, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+						Username:   user.Username,
+						HashedCode: pkg.HashSecret("abcde-fghij"),
+					})).
+					Times(1).
+					Return(db.RecoveryCodes{}, nil)
+				store.EXPECT().CompleteMfaChallenge(gomock.Any(), gomock.Eq(hashedToken)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Sessions{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "验证码错误",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken, "code": wrongTotpCode(t, userTotp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Eq(incrementArg)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+				store.EXPECT().CompleteMfaChallenge(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "一次性密码重放",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken, "code": currentTotpCode(t, userTotp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Eq(incrementArg)).Times(1).Return(challenge, nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTotp, nil)
+				store.EXPECT().UseTotpStep(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "挑战不存在或已失效",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken, "code": "123456"}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// 已使用, 已过期或次数用完的挑战不会增加次数, 也不校验验证码
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).Times(1).Return(db.MfaChallenges{}, sql.ErrNoRows)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "缺少验证码",
+			body: func() gin.H {
+				return gin.H{"mfaToken": mfaToken}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body())
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLoginUserMfaLockout(t *testing.T) {
+	user, password := randomUser(t)
+	userTotp := confirmedUserTotp(t, user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mockdb.NewMockStore(ctrl)
+	stubAuditTx(store)
+	stubLoginFailures(store)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+	store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(userTotp, nil)
+	store.EXPECT().
+		CreateMfaChallenge(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, arg db.CreateMfaChallengeParams) (db.MfaChallenges, error) {
+			return db.MfaChallenges{HashedToken: arg.HashedToken, Username: arg.Username, ExpiresAt: arg.ExpiresAt}, nil
+		})
+	// 每个挑战都是新的, 挑战本身的次数限制不起作用
+	store.EXPECT().
+		IncrementMfaChallengeAttempts(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, arg db.IncrementMfaChallengeAttemptsParams) (db.MfaChallenges, error) {
+			return db.MfaChallenges{HashedToken: arg.HashedToken, Username: user.Username, Attempts: 1}, nil
+		})
+	store.EXPECT().CompleteMfaChallenge(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	// 不延迟, 失败5次之后锁定
+	server.lockout = lockout.NewGuard(store, lockout.Policy{
+		FreeFailures:    5,
+		MaxFailures:     5,
+		LockoutDuration: 15 * time.Minute,
+		Window:          15 * time.Minute,
+	}, lockout.Policy{})
+
+	post := func(path string, body gin.H) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		request, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// 知道密码时每次登录都能获取新的挑战, 但密码校验通过不清除失败次数, 每次猜错一次性密码都计入失败次数
+	var codes []int
+	for i := 0; i < 5; i++ {
+		recorder := post("/users/login", gin.H{"username": user.Username, "password": password})
+		if recorder.Code != http.StatusOK {
+			codes = append(codes, recorder.Code)
+			break
+		}
+		var challenge mfaChallengeResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &challenge))
+		require.True(t, challenge.MfaRequired)
+
+		recorder = post("/users/login/mfa", gin.H{"mfaToken": challenge.MfaToken, "code": wrongTotpCode(t, userTotp.Secret)})
+		codes = append(codes, recorder.Code)
+		if recorder.Code != http.StatusUnauthorized {
+			break
+		}
+	}
+	require.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+
+	// 锁定之后即使密码正确也不能再获取挑战
+	recorder := post("/users/login", gin.H{"username": user.Username, "password": password})
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
+func TestConfirmTotpAPI(t *testing.T) {
+	username := pkg.RandomString(6)
+	pending := confirmedUserTotp(t, username)
+	pending.ConfirmedAt = nil
+
+	testCases := []struct {
+		name          string
+		code          func() string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func() string { return currentTotpCode(t, pending.Secret) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(username)).Times(1).Return(pending, nil)
+				store.EXPECT().
+					ConfirmTotpTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ConfirmTotpTxParams) (db.UserTotp, error) {
+						require.Equal(t, username, arg.Username)
+						require.Equal(t, totp.Step(time.Now()), arg.Step)
+						require.Len(t, arg.HashedRecoveryCodes, recoveryCodeCount)
+						return pending, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct {
+					RecoveryCodes []string `json:"recoveryCodes"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			name: "验证码错误",
+			code: func() string { return wrongTotpCode(t, pending.Secret) },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(username)).Times(1).Return(pending, nil)
+				store.EXPECT().ConfirmTotpTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "已开启两步验证",
+			code: func() string { return "123456" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(username)).Times(1).Return(confirmedUserTotp(t, username), nil)
+				store.EXPECT().ConfirmTotpTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "未绑定",
+			code: func() string { return "123456" },
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(username)).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"code": tc.code()})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/confirm", bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestDisableTotpAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	userTotp := confirmedUserTotp(t, user.Username)
+
+	testCases := []struct {
+		name          string
+		body          func() gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func() gin.H {
+				return gin.H{"code": currentTotpCode(t, userTotp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().UseTotpStep(gomock.Any(), gomock.Any()).Times(1).Return(userTotp, nil)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).Times(1).Return(nil)
+				store.EXPECT().DisableTotpTx(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "验证码错误",
+			body: func() gin.H {
+				return gin.H{"code": wrongTotpCode(t, userTotp.Secret)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditTotpDisableFailed, event.Action)
+						return nil
+					})
+				store.EXPECT().DisableTotpTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "失败次数过多",
+			body: func() gin.H {
+				return gin.H{"recoveryCode": "abcde-fghij"}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(db.LoginFailures{FailedCount: 5, LastFailedAt: time.Now()}, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DisableTotpTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUserTotp(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(userTotp, nil)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			server.lockout = lockout.NewGuard(store, testLoginPolicy, lockout.Policy{})
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body())
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodDelete, "/users/totp", bytes.NewReader(data))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
 	"simple_bank/constants"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
 	"simple_bank/pkg/token"
+	"strconv"
 
 	db "simple_bank/db/sqlc"
 
@@ -24,7 +27,13 @@ func (s *Server) createTransfer(ctx *gin.Context) {
 
 	var req CreateTransferRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": errorResponse(err)})
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	// 验证邮箱之后才能转出金额
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	if !s.requireVerifiedEmail(ctx, payload.Username) {
 		return
 	}
 
@@ -34,28 +43,37 @@ func (s *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
-	// 比较账户的Owner与gin ctx token的payload的Username
-	// 如果一致, 说明登录的用户是该账户的拥有者
-	// 不一致抛出异常
-	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
-	if fromAccount.Owner != payload.Username {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": errorResponse(errors.New("登录的用户非该账户的拥有者"))})
+	// 只能从权限允许操作的账户转出, 客户只能从自己的账户转出
+	if !middleware.CanAccessOwner(ctx, fromAccount.Owner) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgTransferNotOwner)})
+		return
+	}
+
+	// 大额转账需要刚刚重新验证过身份
+	if !s.requireStepUp(ctx, payload.Username, req.Currency, req.Amount) {
 		return
 	}
 
 	_, valid = s.validateCurrent(ctx, req.ToAccountID, req.Currency)
-	if valid {
+	if !valid {
 		return
 	}
 
-	result, err := s.store.CreateTransfer(ctx, db.CreateTransferParams{
-		FromAccountID: req.FromAccountID,
-		ToAccountID:   req.ToAccountID,
-		Amount:        req.Amount,
+	var result db.Transfers
+	event := newAuditEvent(ctx, payload.Username, auditTransferCreate, auditResourceTransfer, "")
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		var err error
+		result, err = q.CreateTransfer(ctx, db.CreateTransferParams{
+			FromAccountID: req.FromAccountID,
+			ToAccountID:   req.ToAccountID,
+			Amount:        req.Amount,
+		})
+		event.ResourceID = strconv.FormatInt(result.ID, 10)
+		event.After = result
+		return err
 	})
-	fmt.Printf("result:%v", result)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": errorResponse(err)})
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
@@ -75,10 +93,16 @@ func (s *Server) validateCurrent(ctx *gin.Context, accountID int64, currency str
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return account, false
 	}
+	// 冻结的账户不能转入或转出金额
+	if account.IsFrozen {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": i18n.T(middleware.GetLocale(ctx), i18n.MsgAccountFrozen, accountID)})
+		return account, false
+	}
 	if currency != account.Currency {
+		locale := middleware.GetLocale(ctx)
 		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-			"message": "转账时请使用相同的货币类型",
-			"error":   fmt.Sprintf("用户ID'%d' 的货币类型不匹配: '%s' vs '%s'", accountID, currency, account.Currency),
+			"message": i18n.T(locale, i18n.MsgCurrencyMismatch),
+			"error":   i18n.T(locale, i18n.MsgCurrencyMismatchError, accountID, currency, account.Currency),
 		})
 		return account, false
 	}
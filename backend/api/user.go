@@ -1,14 +1,24 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"simple_bank/constants"
+	"simple_bank/pkg/token"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
 
+	"simple_bank/middleware"
 	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
 
 	"github.com/gin-gonic/gin"
 
@@ -19,35 +29,58 @@ func (s *Server) CreateUser(ctx *gin.Context) {
 	type CreateUserRequest struct {
 		Username string `json:"username" binding:"required"`
 		FullName string `json:"fullName" binding:"required"`
-		Password string `json:"password" binding:"required,gte=6"`
+		Password string `json:"password" binding:"required"`
 		Email    string `json:"email" binding:"required,email"`
 	}
 	type CreateUserResponse struct {
-		Username string `json:"username" binding:"required"`
-		FullName string `json:"fullName" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
+		Username        string `json:"username" binding:"required"`
+		FullName        string `json:"fullName" binding:"required"`
+		Email           string `json:"email" binding:"required,email"`
+		IsEmailVerified bool   `json:"isEmailVerified"`
 	}
 
 	var req CreateUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	// 密码的长度与强度由密码策略检查
+	if !s.checkPasswordPolicy(ctx, req.Password, req.Username, req.Email, req.FullName) {
+		return
+	}
+
+	password, err := s.passwords.Hash(req.Password)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	password, err := pkg.HashPassword(req.Password)
+	verifyToken, hashedToken, err := newEmailVerificationToken()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	arg := db.CreateUserParams{
-		Username:       req.Username,
-		FullName:       req.FullName,
-		HashedPassword: password,
-		Email:          req.Email,
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:       req.Username,
+			FullName:       req.FullName,
+			HashedPassword: password,
+			Email:          req.Email,
+		},
+		HashedVerificationToken: hashedToken,
+		VerificationExpiresAt:   time.Now().Add(s.config.EmailVerifyDuration),
 	}
 
-	user, createErr := s.store.CreateUser(ctx, arg)
+	var user db.Users
+	event := newAuditEvent(ctx, req.Username, auditUserCreate, auditResourceUser, req.Username)
+	createErr := s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		user, err = s.store.CreateUserTx(ctx, arg)
+		event.After = newUserAuditSnapshot(user)
+		return err
+	})
 	if createErr != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(createErr, &pgErr) {
@@ -56,19 +89,25 @@ func (s *Server) CreateUser(ctx *gin.Context) {
 				ctx.JSON(http.StatusForbidden, gin.H{
 					"message": pgErr.Message,
 					"code":    pgErr.Code,
-					"body":    "用户名已存在",
+					"body":    i18n.T(middleware.GetLocale(ctx), i18n.MsgUsernameExists),
 				})
 				return
 			}
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(createErr))
 		return
 	}
 
+	// 验证邮件发送失败时用户可以重新发送, 不影响注册
+	if err = s.sendEmailVerification(ctx, middleware.GetLocale(ctx), user, verifyToken, arg.VerificationExpiresAt); err != nil {
+		log.Printf("send email verification to %s: %v", user.Username, err)
+	}
+
 	rsp := CreateUserResponse{
-		Username: user.Username,
-		FullName: user.FullName,
-		Email:    user.Email,
+		Username:        user.Username,
+		FullName:        user.FullName,
+		Email:           user.Email,
+		IsEmailVerified: user.IsEmailVerified,
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
@@ -80,7 +119,7 @@ func (s *Server) GetUser(ctx *gin.Context) {
 
 	var req GetUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
 		return
 	}
 
@@ -104,29 +143,61 @@ func (s *Server) GetUser(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, rsp)
 }
 
+type userResponse struct {
+	Username          string    `json:"username"`
+	FullName          string    `json:"fullName"`
+	Email             string    `json:"email"`
+	PasswordChangedAt time.Time `json:"passwordChangedAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	IsEmailVerified   bool      `json:"isEmailVerified"`
+	Role              string    `json:"role"`
+}
+
+func newUserResponse(user db.Users) userResponse {
+	return userResponse{
+		Username:          user.Username,
+		FullName:          user.FullName,
+		Email:             user.Email,
+		PasswordChangedAt: user.PasswordChangedAt,
+		CreatedAt:         user.CreatedAt,
+		UpdatedAt:         user.UpdatedAt,
+		IsEmailVerified:   user.IsEmailVerified,
+		Role:              user.Role,
+	}
+}
+
+type loginUserResponse struct {
+	SessionID             uuid.UUID    `json:"sessionID"`
+	AccessToken           string       `json:"accessToken"`
+	AccessTokenExpiresAt  time.Time    `json:"accessTokenExpiresAt"`
+	RefreshToken          string       `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time    `json:"refreshTokenExpiresAt"`
+	User                  userResponse `json:"user"`
+}
+
 func (s *Server) loginUser(ctx *gin.Context) {
 	type loginUserRequest struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required,gte=6"`
 	}
 
-	type UserResponse struct {
-		Username          string    `json:"username"`
-		FullName          string    `json:"fullName"`
-		Email             string    `json:"email"`
-		PasswordChangedAt time.Time `json:"passwordChangedAt"`
-		CreatedAt         time.Time `json:"createdAt"`
-		UpdatedAt         time.Time `json:"updatedAt"`
+	var req loginUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
 	}
 
-	type loginUserResponse struct {
-		AccessToken string       `json:"accessToken"`
-		User        UserResponse `json:"user"`
+	// 连续登录失败过多时要求等待, 无论用户名是否存在都按相同的规则处理, 避免泄露用户名是否存在
+	// 校验密码之前先记为一次失败, 并发的猜测不能同时通过检查
+	clientIP := ctx.ClientIP()
+	wait, err := s.lockout.Reserve(ctx, req.Username, clientIP)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
-
-	var req loginUserRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+	if wait > 0 {
+		loginThrottled(ctx, wait)
 		return
 	}
 
@@ -135,40 +206,333 @@ func (s *Server) loginUser(ctx *gin.Context) {
 	if err != nil {
 		// 查不到
 		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			// 用户不存在时同样校验一次密码, 使响应时间与密码错误时一致
+			_, _ = s.passwords.Verify(req.Password, s.dummyPasswordHash())
+			s.loginFailed(ctx, req.Username)
 			return
 		}
 		// 其它错误
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	// 已删除的用户没有密码, 与用户不存在时的处理相同
+	if user.DeletedAt != nil {
+		_, _ = s.passwords.Verify(req.Password, s.dummyPasswordHash())
+		s.loginFailed(ctx, req.Username)
+		return
+	}
 
 	// 检查密码与hash之后的密码是否匹配
-	if checkErr := pkg.CheckHashedPassword(req.Password, user.HashedPassword); checkErr != nil {
-		// 401
-		ctx.JSON(http.StatusUnauthorized, gin.H{
-			"message": "密码错误",
+	needsRehash, checkErr := s.passwords.Verify(req.Password, user.HashedPassword)
+	if checkErr != nil {
+		s.loginFailed(ctx, req.Username)
+		return
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	// 开启了两步验证时先颁发登录挑战, 校验一次性密码之后才颁发令牌
+	enabled, err := s.totpEnabled(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if enabled {
+		// 用户名预先记录的失败保留到第二步校验通过为止, 只知道密码不能通过反复登录重置一次性密码的猜测次数
+		if err = s.lockout.SucceedFirstFactor(ctx, clientIP); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		s.createMfaChallenge(ctx, user.Username)
+		return
+	}
+
+	if err = s.lockout.Succeed(ctx, user.Username, clientIP); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := s.loginSucceeded(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// rehashPassword 旧算法或旧参数的散列值在登录成功之后使用当前的算法重新计算
+// 只在散列值没有被同时修改时保存, 失败时不影响本次登录
+func (s *Server) rehashPassword(ctx *gin.Context, user db.Users, password string) {
+	hashedPassword, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			HashedPassword:    hashedPassword,
+			Username:          user.Username,
+			OldHashedPassword: user.HashedPassword,
 		})
+	}
+	if err != nil {
+		log.Printf("rehash password of %s: %v", user.Username, err)
+	}
+}
+
+// loginFailed 记录登录失败的审计事件, 失败次数已在校验之前记录, 用户名不存在与密码错误返回相同的响应
+func (s *Server) loginFailed(ctx *gin.Context, username string) {
+	event := newAuditEvent(ctx, username, auditLoginFailed, auditResourceUser, username)
+	err := s.store.AuditTx(ctx, event, func(context.Context, db.Querier) error {
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	ctx.JSON(http.StatusUnauthorized, gin.H{
+		"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgInvalidCredentials),
+	})
+}
+
+// loginThrottled 登录失败次数过多, 返回429并在Retry-After中给出需要等待的秒数
+func loginThrottled(ctx *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{
+		"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgLoginThrottled, seconds),
+	})
+}
 
+// checkPassword 校验已登录用户重新输入的密码, 与登录共用失败次数的限制, 持有令牌也不能无限次猜测密码
+// 密码错误时以 failedAction 记录审计事件, 返回值为false时已经写入响应
+func (s *Server) checkPassword(ctx *gin.Context, user db.Users, password string, failedAction string) bool {
+	failed := newAuditEvent(ctx, user.Username, failedAction, auditResourceUser, user.Username)
+	return s.guardAttempt(ctx, user.Username, failed, i18n.MsgPasswordIncorrect, func() (bool, error) {
+		_, err := s.passwords.Verify(password, user.HashedPassword)
+		return err == nil, nil
+	})
+}
+
+// guardAttempt 以该用户名的登录失败次数限制一次凭证校验, 校验之前先记为一次失败, 通过之后清除失败记录
+// 校验未通过时记录审计事件 failed 并返回401, 返回值为false时已经写入响应
+func (s *Server) guardAttempt(ctx *gin.Context, username string, failed *db.AuditEvent, message string, verify func() (bool, error)) bool {
+	clientIP := ctx.ClientIP()
+	wait, err := s.lockout.Reserve(ctx, username, clientIP)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if wait > 0 {
+		loginThrottled(ctx, wait)
+		return false
+	}
+
+	ok, err := verify()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if !ok {
+		err = s.store.AuditTx(ctx, failed, func(context.Context, db.Querier) error {
+			return nil
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusUnauthorized, gin.H{"message": i18n.T(middleware.GetLocale(ctx), message)})
+		return false
+	}
+
+	if err := s.lockout.Succeed(ctx, username, clientIP); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	return true
+}
+
+// loginSucceeded 登录成功, 创建会话并在同一个事务中记录审计事件
+func (s *Server) loginSucceeded(ctx *gin.Context, user db.Users) (loginUserResponse, error) {
+	var rsp loginUserResponse
+	event := newAuditEvent(ctx, user.Username, auditLoginSucceeded, auditResourceUser, user.Username)
+	err := s.store.AuditTx(ctx, event, func(_ context.Context, q db.Querier) error {
+		var err error
+		rsp, err = s.createLoginSession(ctx, q, user)
+		event.After = gin.H{"sessionID": rsp.SessionID}
+		return err
+	})
+	return rsp, err
+}
+
+// createLoginSession 颁发访问令牌与刷新令牌, 并将刷新令牌记录到会话中
+func (s *Server) createLoginSession(ctx *gin.Context, q db.Querier, user db.Users) (loginUserResponse, error) {
 	// 颁发token
-	token, createErr := s.tokenMake.CreateToken(user.Username, s.config.AccessTokenDuration)
-	if createErr != nil {
+	accessToken, accessPayload, err := s.tokenMake.CreateToken(user.Username, user.Role, s.config.AccessTokenDuration)
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	// 颁发刷新令牌, 并记录到会话中
+	refreshToken, refreshPayload, err := s.createRefreshToken(user.Username, user.Role)
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	session, err := q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                 refreshPayload.ID,
+		FamilyID:           refreshPayload.ID,
+		Username:           user.Username,
+		HashedRefreshToken: pkg.HashSecret(refreshToken),
+		UserAgent:          ctx.Request.UserAgent(),
+		ClientIp:           ctx.ClientIP(),
+		IsBlocked:          false,
+		ExpiresAt:          refreshPayload.ExpiresAt.Time,
+	})
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	return loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiresAt.Time,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiresAt.Time,
+		User:                  newUserResponse(user),
+	}, nil
+}
+
+// 退出登录, 撤销当前的访问令牌, 提供了刷新令牌时同时禁用该次登录的会话
+func (s *Server) logoutUser(ctx *gin.Context) {
+	type logoutUserRequest struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	var req logoutUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	if err := s.revocations.Revoke(ctx, payload); err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	rsp := UserResponse{
-		Username:          user.Username,
-		FullName:          user.FullName,
-		Email:             user.Email,
-		PasswordChangedAt: user.PasswordChangedAt,
-		CreatedAt:         user.CreatedAt,
-		UpdatedAt:         user.UpdatedAt,
+	if req.RefreshToken != "" {
+		refreshPayload, err := s.tokenMake.VerifyToken(req.RefreshToken)
+		// 刷新令牌已经失效时无需再禁用会话
+		if err == nil && refreshPayload.IsRefresh() && refreshPayload.Username == payload.Username {
+			session, getErr := s.store.GetSession(ctx, refreshPayload.ID)
+			if getErr != nil && !errors.Is(getErr, sql.ErrNoRows) {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(getErr))
+				return
+			}
+			if getErr == nil && session.Username == payload.Username {
+				if blockErr := s.store.BlockSessionFamily(ctx, session.FamilyID); blockErr != nil {
+					ctx.JSON(http.StatusInternalServerError, errorResponse(blockErr))
+					return
+				}
+			}
+		}
 	}
-	ctx.JSON(http.StatusOK, loginUserResponse{
-		AccessToken: token,
-		User:        rsp,
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// 退出所有设备, 撤销该用户此前颁发的所有令牌并禁用所有会话
+func (s *Server) logoutAllDevices(ctx *gin.Context) {
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+
+	event := newAuditEvent(ctx, payload.Username, auditLogoutAll, auditResourceUser, payload.Username)
+	err := s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+		return q.BlockUserSessions(ctx, payload.Username)
 	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := s.revocations.RevokeAll(ctx, payload.Username, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// 当前令牌的颁发时间可能与撤销时间在同一秒内, 单独撤销
+	if err := s.revocations.Revoke(ctx, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// 修改密码, 需要提供当前密码
+// 修改之后此前颁发的令牌与会话全部失效, 响应中返回新的登录会话
+func (s *Server) changePassword(ctx *gin.Context) {
+	type changePasswordRequest struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	user, err := s.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !s.checkPassword(ctx, user, req.CurrentPassword, auditPasswordChangeFailed) {
+		return
+	}
+
+	if !s.checkPasswordPolicy(ctx, req.NewPassword, user.Username, user.Email, user.FullName) {
+		return
+	}
+
+	hashedPassword, err := s.passwords.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	event := newAuditEvent(ctx, user.Username, auditPasswordChange, auditResourceUser, user.Username)
+	event.Before = newUserAuditSnapshot(user)
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		user, err = s.store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+			Username:       user.Username,
+			HashedPassword: hashedPassword,
+		})
+		event.After = newUserAuditSnapshot(user)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// password_changed_at 已使数据库中的旧令牌失效, 这里同步本实例的撤销缓存
+	// 使用本实例的时间而不是数据库的时间, 数据库的时钟偏快时不会撤销之后颁发的新令牌
+	if err = s.revocations.RevokeAll(ctx, user.Username, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// 当前令牌的颁发时间可能与修改时间在同一秒内, 单独撤销
+	if err = s.revocations.Revoke(ctx, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := s.createLoginSession(ctx, s.store, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
 }
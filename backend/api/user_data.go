@@ -0,0 +1,269 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/mail"
+	"simple_bank/pkg/token"
+)
+
+// 导出的格式
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+)
+
+// exportTable 导出压缩包中的一个文件, JSON格式直接序列化value, CSV格式使用header与rows
+type exportTable struct {
+	name   string
+	value  any
+	header []string
+	rows   [][]string
+}
+
+// 导出用户的资料, 账户, 条目与转账记录, 以zip压缩包下载
+func (s *Server) exportUserData(ctx *gin.Context) {
+	type exportUserDataRequest struct {
+		Format string `form:"format" binding:"omitempty,oneof=json csv"`
+	}
+
+	var req exportUserDataRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(middleware.GetLocale(ctx), i18n.MsgExportFormatInvalid)})
+		return
+	}
+	if req.Format == "" {
+		req.Format = exportFormatJSON
+	}
+
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	user, err := s.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	accounts, err := s.store.ListOwnerAccounts(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	entries, err := s.store.ListOwnerEntries(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	transfers, err := s.store.ListOwnerTransfers(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	exportedAt := time.Now().UTC()
+	var archive bytes.Buffer
+	tables := exportTables(newUserResponse(user), accounts, entries, transfers)
+	if err = writeExportArchive(&archive, req.Format, exportedAt, tables); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	filename := fmt.Sprintf("simple_bank-%s-%s.zip", user.Username, exportedAt.Format("20060102"))
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	ctx.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// exportTables 导出的各个文件
+func exportTables(profile userResponse, accounts []db.Accounts, entries []db.Entries, transfers []db.Transfers) []exportTable {
+	tables := []exportTable{
+		{
+			name:   "profile",
+			value:  profile,
+			header: []string{"username", "fullName", "email", "isEmailVerified", "role", "passwordChangedAt", "createdAt", "updatedAt"},
+			rows: [][]string{{
+				profile.Username,
+				profile.FullName,
+				profile.Email,
+				strconv.FormatBool(profile.IsEmailVerified),
+				profile.Role,
+				formatExportTime(profile.PasswordChangedAt),
+				formatExportTime(profile.CreatedAt),
+				formatExportTime(profile.UpdatedAt),
+			}},
+		},
+		{
+			name:   "accounts",
+			value:  accounts,
+			header: []string{"id", "owner", "balance", "currency", "isFrozen", "createdAt", "closedAt"},
+		},
+		{
+			name:   "entries",
+			value:  entries,
+			header: []string{"id", "accountID", "amount", "createdAt"},
+		},
+		{
+			name:   "transfers",
+			value:  transfers,
+			header: []string{"id", "fromAccountID", "toAccountID", "amount", "createdAt"},
+		},
+	}
+
+	for _, account := range accounts {
+		closedAt := ""
+		if account.ClosedAt != nil {
+			closedAt = formatExportTime(*account.ClosedAt)
+		}
+		tables[1].rows = append(tables[1].rows, []string{
+			strconv.FormatInt(account.ID, 10),
+			account.Owner,
+			strconv.FormatInt(account.Balance, 10),
+			account.Currency,
+			strconv.FormatBool(account.IsFrozen),
+			formatExportTime(account.CreatedAt),
+			closedAt,
+		})
+	}
+	for _, entry := range entries {
+		tables[2].rows = append(tables[2].rows, []string{
+			strconv.FormatInt(entry.ID, 10),
+			strconv.FormatInt(entry.AccountID, 10),
+			strconv.FormatInt(entry.Amount, 10),
+			formatExportTime(entry.CreatedAt),
+		})
+	}
+	for _, transfer := range transfers {
+		tables[3].rows = append(tables[3].rows, []string{
+			strconv.FormatInt(transfer.ID, 10),
+			strconv.FormatInt(transfer.FromAccountID, 10),
+			strconv.FormatInt(transfer.ToAccountID, 10),
+			strconv.FormatInt(transfer.Amount, 10),
+			formatExportTime(transfer.CreatedAt),
+		})
+	}
+	return tables
+}
+
+func formatExportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// writeExportArchive 将每个表写入压缩包中的一个文件, 文件名为 表名.格式
+func writeExportArchive(w io.Writer, format string, modified time.Time, tables []exportTable) error {
+	archive := zip.NewWriter(w)
+	for _, table := range tables {
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     table.name + "." + format,
+			Method:   zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch format {
+		case exportFormatCSV:
+			writer := csv.NewWriter(file)
+			if err = writer.Write(table.header); err != nil {
+				return err
+			}
+			if err = writer.WriteAll(table.rows); err != nil {
+				return err
+			}
+		default:
+			encoder := json.NewEncoder(file)
+			encoder.SetIndent("", "  ")
+			if err = encoder.Encode(table.value); err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
+
+// 删除当前用户: 所有账户的余额必须为0, 删除之后账户关闭, 个人信息清除, 账目保留
+func (s *Server) deleteUser(ctx *gin.Context) {
+	type deleteUserRequest struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+	}
+	type deleteUserResponse struct {
+		DeletedAt      time.Time `json:"deletedAt"`
+		ClosedAccounts []int64   `json:"closedAccounts"`
+	}
+
+	var req deleteUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	user, err := s.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// 与登录共用失败次数, 防止借助已登录的会话猜测密码
+	if !s.checkPassword(ctx, user, req.CurrentPassword, auditUserDeleteFailed) {
+		return
+	}
+
+	var result db.DeleteUserTxResult
+	event := newAuditEvent(ctx, user.Username, auditUserDelete, auditResourceUser, user.Username)
+	event.Before = newUserAuditSnapshot(user)
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		result, err = s.store.DeleteUserTx(ctx, user.Username)
+		event.After = newUserAuditSnapshot(result.User)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrAccountNotEmpty):
+			ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(locale, i18n.MsgAccountNotEmpty)})
+		case errors.Is(err, db.ErrUserDeleted):
+			ctx.JSON(http.StatusGone, gin.H{"message": i18n.T(locale, i18n.MsgUserDeleted)})
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	deletedAt := *result.User.DeletedAt
+	// 数据库中的令牌已在事务中撤销, 这里同步本实例的撤销缓存
+	if err = s.revocations.RevokeAll(ctx, user.Username, deletedAt); err != nil {
+		log.Printf("revoke tokens of deleted user %s: %v", user.Username, err)
+	}
+
+	// 邮箱已被清除, 使用删除之前的邮箱通知, 发送失败不影响删除
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      []string{user.Email},
+		Subject: i18n.T(locale, i18n.MsgUserDeletedSubject),
+		Body:    i18n.T(locale, i18n.MsgUserDeletedBody, user.Username, deletedAt.Format("2006-01-02 15:04 MST")),
+	})
+	if err != nil {
+		log.Printf("send deletion notice to %s: %v", user.Username, err)
+	}
+
+	rsp := deleteUserResponse{DeletedAt: deletedAt, ClosedAccounts: []int64{}}
+	for _, account := range result.ClosedAccounts {
+		rsp.ClosedAccounts = append(rsp.ClosedAccounts, account.ID)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+)
+
+func TestExportUserDataAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(t, user.Username)
+	entry := db.Entries{ID: pkg.RandomInt(1, 1000), AccountID: account.ID, Amount: -10, CreatedAt: time.Now()}
+	transfer := db.Transfers{ID: pkg.RandomInt(1, 1000), FromAccountID: account.ID, ToAccountID: account.ID + 1, Amount: 10, CreatedAt: time.Now()}
+
+	buildStubs := func(store *mockdb.MockStore) {
+		store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+		store.EXPECT().ListOwnerAccounts(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return([]db.Accounts{account}, nil)
+		store.EXPECT().ListOwnerEntries(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return([]db.Entries{entry}, nil)
+		store.EXPECT().ListOwnerTransfers(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return([]db.Transfers{transfer}, nil)
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "JSON",
+			query:      "",
+			buildStubs: buildStubs,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+				require.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+				files := readExportArchive(t, recorder.Body.Bytes())
+				require.Len(t, files, 4)
+
+				var profile userResponse
+				require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+				require.Equal(t, user.Username, profile.Username)
+				require.Equal(t, user.Email, profile.Email)
+				require.NotContains(t, string(files["profile.json"]), user.HashedPassword)
+
+				var accounts []db.Accounts
+				require.NoError(t, json.Unmarshal(files["accounts.json"], &accounts))
+				require.Len(t, accounts, 1)
+				require.Equal(t, account.ID, accounts[0].ID)
+
+				var entries []db.Entries
+				require.NoError(t, json.Unmarshal(files["entries.json"], &entries))
+				require.Len(t, entries, 1)
+				require.Equal(t, entry.Amount, entries[0].Amount)
+
+				var transfers []db.Transfers
+				require.NoError(t, json.Unmarshal(files["transfers.json"], &transfers))
+				require.Len(t, transfers, 1)
+				require.Equal(t, transfer.ToAccountID, transfers[0].ToAccountID)
+			},
+		},
+		{
+			name:       "CSV",
+			query:      "?format=csv",
+			buildStubs: buildStubs,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				files := readExportArchive(t, recorder.Body.Bytes())
+				require.Len(t, files, 4)
+
+				profile, err := csv.NewReader(bytes.NewReader(files["profile.csv"])).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, profile, 2)
+				require.Equal(t, "username", profile[0][0])
+				require.Equal(t, user.Username, profile[1][0])
+
+				accounts, err := csv.NewReader(bytes.NewReader(files["accounts.csv"])).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, accounts, 2)
+				require.Equal(t, fmt.Sprint(account.Balance), accounts[1][2])
+				// 未关闭的账户关闭时间为空
+				require.Empty(t, accounts[1][6])
+
+				transfers, err := csv.NewReader(bytes.NewReader(files["transfers.csv"])).ReadAll()
+				require.NoError(t, err)
+				require.Len(t, transfers, 2)
+			},
+		},
+		{
+			name:  "格式错误",
+			query: "?format=xml",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/users/me/export"+tc.query, nil)
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// readExportArchive 解压导出的压缩包, 返回文件名与内容
+func readExportArchive(t *testing.T, data []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[file.Name] = content
+	}
+	return files
+}
+
+func TestDeleteUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+	account := randomAccount(t, user.Username)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"currentPassword": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+
+				deletedAt := time.Now()
+				closedAccount := account
+				closedAccount.Balance = 0
+				closedAccount.IsFrozen = true
+				closedAccount.ClosedAt = &deletedAt
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.DeleteUserTxResult{
+						User: db.Users{
+							Username:  user.Username,
+							Email:     "deleted-x@deleted.invalid",
+							DeletedAt: &deletedAt,
+						},
+						ClosedAccounts: []db.Accounts{closedAccount},
+					}, nil)
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp struct {
+					ClosedAccounts []int64 `json:"closedAccounts"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, []int64{account.ID}, rsp.ClosedAccounts)
+
+				// 使用删除之前的邮箱通知
+				require.Len(t, mailer.messages, 1)
+				require.Equal(t, []string{user.Email}, mailer.messages[0].To)
+			},
+		},
+		{
+			name: "密码错误",
+			body: gin.H{"currentPassword": "wrong-password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditUserDeleteFailed, event.Action)
+						return nil
+					})
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name: "账户余额不为0",
+			body: gin.H{"currentPassword": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					DeleteUserTx(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.DeleteUserTxResult{}, fmt.Errorf("%w: account %d", db.ErrAccountNotEmpty, account.ID))
+				store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name: "缺少密码",
+			body: gin.H{},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().DeleteUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+
+			server := newTestServer(t, store)
+			mailer := &recordingMailer{}
+			server.mailer = mailer
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodDelete, "/users/me", bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	db "simple_bank/db/sqlc"
+	"simple_bank/middleware"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/mail"
+	"simple_bank/pkg/token"
+)
+
+type updateProfileRequest struct {
+	// 字段为空则不修改
+	FullName *string `json:"fullName" binding:"omitempty,min=1,max=64"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	// 修改邮箱时必须提供当前密码, 邮箱可以用于重置密码
+	CurrentPassword string `json:"currentPassword"`
+}
+
+type updateProfileResponse struct {
+	User userResponse `json:"user"`
+	// 等待验证的新邮箱, 验证之前 user.email 仍是原来的邮箱
+	PendingEmail string `json:"pendingEmail,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// 修改当前用户的资料, 只修改请求中提供的字段
+// 姓名立即修改; 邮箱需要验证新邮箱之后才修改, 同时通知原来的邮箱
+func (s *Server) updateProfile(ctx *gin.Context) {
+	var req updateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, bindingErrorResponse(ctx, err))
+		return
+	}
+
+	locale := middleware.GetLocale(ctx)
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	user, err := s.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	changeName := req.FullName != nil && *req.FullName != user.FullName
+	changeEmail := req.Email != nil && *req.Email != user.Email
+	if !changeName && !changeEmail {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": i18n.T(locale, i18n.MsgProfileNoChanges)})
+		return
+	}
+
+	if changeEmail {
+		if !s.checkPassword(ctx, user, req.CurrentPassword, auditEmailChangeFailed) {
+			return
+		}
+		// 验证时还会再检查一次, 这里提前拒绝避免发送无法使用的验证邮件
+		if _, err = s.store.GetUserByEmail(ctx, *req.Email); err == nil {
+			ctx.JSON(http.StatusConflict, gin.H{"message": i18n.T(locale, i18n.MsgEmailTaken)})
+			return
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	if changeName {
+		event := newAuditEvent(ctx, user.Username, auditProfileUpdate, auditResourceUser, user.Username)
+		event.Before = newUserAuditSnapshot(user)
+		err = s.store.AuditTx(ctx, event, func(ctx context.Context, q db.Querier) error {
+			var err error
+			user, err = q.UpdateUser(ctx, db.UpdateUserParams{
+				FullName: req.FullName,
+				Username: user.Username,
+			})
+			event.After = newUserAuditSnapshot(user)
+			return err
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	rsp := updateProfileResponse{User: newUserResponse(user)}
+	if changeEmail {
+		if err = s.requestEmailChange(ctx, locale, user, *req.Email); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		rsp.PendingEmail = *req.Email
+		rsp.Message = i18n.T(locale, i18n.MsgEmailChangeSent)
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// requestEmailChange 记录修改邮箱的令牌, 向新邮箱发送验证链接, 并通知原来的邮箱
+func (s *Server) requestEmailChange(ctx *gin.Context, locale string, user db.Users, newEmail string) error {
+	verifyToken, hashedToken, err := newEmailVerificationToken()
+	if err != nil {
+		return err
+	}
+	// 新邮箱属于个人信息, 不写入审计日志
+	var verification db.EmailVerifications
+	event := newAuditEvent(ctx, user.Username, auditEmailChangeRequest, auditResourceUser, user.Username)
+	event.Before = newUserAuditSnapshot(user)
+	err = s.store.AuditTx(ctx, event, func(ctx context.Context, _ db.Querier) error {
+		var err error
+		verification, err = s.store.RequestEmailChangeTx(ctx, db.CreateEmailChangeParams{
+			HashedToken: hashedToken,
+			Username:    user.Username,
+			Email:       newEmail,
+			ExpiresAt:   time.Now().Add(s.config.EmailVerifyDuration),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	pending := user
+	pending.Email = newEmail
+	if err = s.sendEmailVerification(ctx, locale, pending, verifyToken, verification.ExpiresAt); err != nil {
+		return err
+	}
+
+	// 通知失败不影响修改, 只记录日志
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      []string{user.Email},
+		Subject: i18n.T(locale, i18n.MsgEmailChangeSubject),
+		Body:    i18n.T(locale, i18n.MsgEmailChangeBody, user.Username, newEmail),
+	})
+	if err != nil {
+		log.Printf("send email change notice to %s: %v", user.Username, err)
+	}
+	return nil
+}
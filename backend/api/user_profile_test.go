@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simple_bank/constants"
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+)
+
+func TestUpdateProfileAPI(t *testing.T) {
+	user, password := randomUser(t)
+	newName := pkg.RandomString(8)
+	newEmail := pkg.RandomEmail(8)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer)
+	}{
+		{
+			name: "修改姓名",
+			body: gin.H{"fullName": newName},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				updated := user
+				updated.FullName = newName
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Eq(db.UpdateUserParams{FullName: &newName, Username: user.Username})).
+					Times(1).
+					Return(updated, nil)
+				store.EXPECT().RequestEmailChangeTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp updateProfileResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, newName, rsp.User.FullName)
+				require.Empty(t, rsp.PendingEmail)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name: "修改邮箱",
+			body: gin.H{"email": newEmail, "currentPassword": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(newEmail)).Times(1).Return(db.Users{}, sql.ErrNoRows)
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					RequestEmailChangeTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateEmailChangeParams) (db.EmailVerifications, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, newEmail, arg.Email)
+						require.NotEmpty(t, arg.HashedToken)
+						return db.EmailVerifications{
+							HashedToken:   arg.HashedToken,
+							Username:      arg.Username,
+							Email:         arg.Email,
+							ExpiresAt:     arg.ExpiresAt,
+							IsEmailChange: true,
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp updateProfileResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				// 验证之前邮箱不变
+				require.Equal(t, user.Email, rsp.User.Email)
+				require.Equal(t, newEmail, rsp.PendingEmail)
+
+				// 验证链接发送到新邮箱, 通知发送到原来的邮箱
+				require.Len(t, mailer.messages, 2)
+				require.Equal(t, []string{newEmail}, mailer.messages[0].To)
+				require.Contains(t, mailer.messages[0].Body, "token=")
+				require.Equal(t, []string{user.Email}, mailer.messages[1].To)
+				require.Contains(t, mailer.messages[1].Body, newEmail)
+			},
+		},
+		{
+			name: "修改邮箱密码错误",
+			body: gin.H{"email": newEmail, "currentPassword": "wrong-password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditEmailChangeFailed, event.Action)
+						return nil
+					})
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RequestEmailChangeTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Empty(t, mailer.messages)
+			},
+		},
+		{
+			name: "新邮箱已被使用",
+			body: gin.H{"fullName": newName, "email": newEmail, "currentPassword": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(newEmail)).Times(1).Return(db.Users{Email: newEmail}, nil)
+				// 请求整体失败, 姓名也不修改
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RequestEmailChangeTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "没有需要修改的内容",
+			body: gin.H{"fullName": user.FullName, "email": user.Email},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().RequestEmailChangeTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "邮箱格式错误",
+			body: gin.H{"email": "not-an-email", "currentPassword": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, mailer *recordingMailer) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubTokenNotRevoked(store)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+
+			server := newTestServer(t, store)
+			server.config.EmailVerifyURL = "https://bank.example.com/verify-email"
+			server.config.EmailVerifyDuration = time.Hour
+			mailer := &recordingMailer{}
+			server.mailer = mailer
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPatch, "/users/me", bytes.NewReader(body))
+			require.NoError(t, err)
+			addMiddleware(t, request, constants.AuthorizationHeaderType, server.tokenMake, user.Username, rbac.RoleCustomer, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, mailer)
+		})
+	}
+}
@@ -2,14 +2,25 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"simple_bank/constants"
 	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/lockout"
+	"simple_bank/pkg/password"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
 
 	db "simple_bank/db/sqlc"
 
@@ -19,6 +30,7 @@ import (
 	mockdb "simple_bank/db/mock"
 
 	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Matcher interface {
@@ -39,15 +51,23 @@ func EqCreateUserParams(arg db.CreateUserParams, password string) gomock.Matcher
 }
 
 func (e eqMatcher) Matches(x any) bool {
-	// 将any 转换为 结构体
-	arg, ok := x.(db.CreateUserParams)
-	if !ok {
+	// 将any 转换为 结构体, 注册时在事务中同时创建邮箱验证令牌
+	var arg db.CreateUserParams
+	switch v := x.(type) {
+	case db.CreateUserParams:
+		arg = v
+	case db.CreateUserTxParams:
+		if v.HashedVerificationToken == "" {
+			return false
+		}
+		arg = v.CreateUserParams
+	default:
 		return false
 	}
 
 	// 校验hashed之后的密码
 	// 比对两个密码的hash值, 如果相同则说明正确
-	err := pkg.CheckHashedPassword(e.password, arg.HashedPassword)
+	_, err := testPasswords.Verify(e.password, arg.HashedPassword)
 	if err != nil {
 		return false
 	}
@@ -106,9 +126,9 @@ func TestCreateUserAPI(t *testing.T) {
 					Email:          user.Email,
 				}
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
+					CreateUserTx(gomock.Any(), EqCreateUserParams(arg, password)).
 					Times(1).
-					Return(db.Users{}, nil)
+					Return(user, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -124,7 +144,24 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "Password contains username",
+			body: gin.H{
+				"username": "mike",
+				"password": "Mike-2024-pass",
+				"fullName": "Mike",
+				"email":    "mike@example.com",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -141,7 +178,7 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -158,7 +195,7 @@ func TestCreateUserAPI(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -174,6 +211,7 @@ func TestCreateUserAPI(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
 			tc.buildStubs(store)
 
 			server := newTestServer(t, store)
@@ -224,9 +262,9 @@ func TestGetUserAPI(t *testing.T) {
 					Email:          user.Email,
 				}
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
+					CreateUserTx(gomock.Any(), EqCreateUserParams(arg, password)).
 					Times(1).
-					Return(db.Users{}, nil)
+					Return(user, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -292,6 +330,7 @@ func TestGetUserAPI(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
 			tc.buildStubs(store)
 
 			server := newTestServer(t, store)
@@ -315,10 +354,9 @@ func TestGetUserAPI(t *testing.T) {
 }
 
 func randomUser(t *testing.T) (user db.Users, password string) {
-	password = pkg.RandomString(6)
+	password = pkg.RandomString(10)
 
-	hashedPassword, err := pkg.HashPassword(password)
-	require.NoError(t, err)
+	hashedPassword := hashTestPassword(t, password)
 
 	user = db.Users{
 		Username:       pkg.RandomString(2),
@@ -328,3 +366,428 @@ func randomUser(t *testing.T) (user db.Users, password string) {
 	}
 	return
 }
+
+// hashLegacyPassword 生成旧算法的散列值, 登录成功之后应该重新计算
+func hashLegacyPassword(t *testing.T, plain string) string {
+	hashedPassword, err := password.NewBcryptHasher(bcrypt.MinCost).Hash(plain)
+	require.NoError(t, err)
+	return hashedPassword
+}
+
+func TestChangePasswordAPI(t *testing.T) {
+	user, password := randomUser(t)
+	newPassword := pkg.RandomString(8)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"currentPassword": password, "newPassword": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				changed := user
+				changed.PasswordChangedAt = time.Now()
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).Times(1).Return(nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangePasswordTxParams) (db.Users, error) {
+						require.Equal(t, user.Username, arg.Username)
+						_, err := testPasswords.Verify(newPassword, arg.HashedPassword)
+						require.NoError(t, err)
+						changed.HashedPassword = arg.HashedPassword
+						return changed, nil
+					})
+				// 修改密码之前颁发的令牌全部撤销, 当前令牌单独撤销
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RevokeUserTokensParams) error {
+						require.Equal(t, user.Username, arg.Username)
+						require.WithinDuration(t, changed.PasswordChangedAt, arg.RevokedBefore, time.Second)
+						return nil
+					})
+				store.EXPECT().CreateRevokedToken(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				// 颁发新的登录会话
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Sessions{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var rsp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotEmpty(t, rsp.RefreshToken)
+			},
+		},
+		{
+			name: "当前密码错误",
+			body: gin.H{"currentPassword": "wrong-password", "newPassword": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				// 与登录共用失败次数, 失败的尝试不撤销
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().
+					AuditTx(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, event *db.AuditEvent, _ func(context.Context, db.Querier) error) error {
+						require.Equal(t, auditPasswordChangeFailed, event.Action)
+						return nil
+					})
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "失败次数过多",
+			body: gin.H{"currentPassword": password, "newPassword": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(db.LoginFailures{FailedCount: 5, LastFailedAt: time.Now()}, nil)
+				// 等待期间即使当前密码正确也不修改
+				store.EXPECT().ReserveLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name: "新密码过短",
+			body: gin.H{"currentPassword": password, "newPassword": "123"},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// 校验当前密码之后才检查新密码
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "新密码包含邮箱",
+			body: gin.H{"currentPassword": password, "newPassword": "x" + user.Email},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addMiddleware(t, request, constants.AuthorizationHeaderType, tokenMaker, user.Username, rbac.RoleCustomer, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				var rsp map[string]string
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, i18n.T(i18n.DefaultLocale, i18n.MsgPasswordSimilar), rsp["message"])
+			},
+		},
+		{
+			name:      "未登录",
+			body:      gin.H{"currentPassword": password, "newPassword": newPassword},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuditTx(store)
+			stubTokenNotRevoked(store)
+
+			server := newTestServer(t, store)
+			server.lockout = lockout.NewGuard(store, testLoginPolicy, lockout.Policy{})
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, "/users/password", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMake)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// testLoginPolicy 测试中开启的按用户名的失败次数限制
+var testLoginPolicy = lockout.Policy{
+	FreeFailures:    3,
+	MaxFailures:     10,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+// reserveAttempt 校验密码之前先记为一次失败
+func reserveAttempt(t *testing.T, store *mockdb.MockStore, username string) {
+	store.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(username))).
+		Times(1).
+		Return(db.LoginFailures{}, sql.ErrNoRows)
+	store.EXPECT().
+		ReserveLoginAttempt(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.ReserveLoginAttemptParams) (db.LoginFailures, error) {
+			require.Equal(t, lockout.UserSubject(username), arg.Subject)
+			return db.LoginFailures{Subject: arg.Subject, FailedCount: 1, LastFailedAt: time.Now()}, nil
+		})
+}
+
+// stubLoginFailures 在内存中保存失败记录, 用于测试多次请求之后的锁定
+func stubLoginFailures(store *mockdb.MockStore) {
+	var mu sync.Mutex
+	failures := make(map[string]db.LoginFailures)
+	store.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, subject string) (db.LoginFailures, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failure, ok := failures[subject]
+			if !ok {
+				return db.LoginFailures{}, sql.ErrNoRows
+			}
+			return failure, nil
+		})
+	store.EXPECT().
+		ReserveLoginAttempt(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, arg db.ReserveLoginAttemptParams) (db.LoginFailures, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failure := failures[arg.Subject]
+			failure.Subject = arg.Subject
+			failure.FailedCount++
+			failure.LastFailedAt = time.Now()
+			failures[arg.Subject] = failure
+			return failure, nil
+		})
+	store.EXPECT().
+		LockLogin(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, arg db.LockLoginParams) error {
+			mu.Lock()
+			defer mu.Unlock()
+			failure := failures[arg.Subject]
+			failure.LockedUntil = arg.LockedUntil
+			failures[arg.Subject] = failure
+			return nil
+		})
+	store.EXPECT().
+		ResetLoginFailures(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ any, subject string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delete(failures, subject)
+			return nil
+		})
+}
+
+func TestLoginUserAPI(t *testing.T) {
+	user, password := randomUser(t)
+	// 早期注册的用户使用bcrypt
+	legacyUser := user
+	legacyUser.HashedPassword = hashLegacyPassword(t, password)
+
+	// 用户名不存在与密码错误返回完全相同的响应
+	invalidCredentials := func(t *testing.T, recorder *httptest.ResponseRecorder) {
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		var rsp map[string]string
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+		require.Equal(t, map[string]string{"message": i18n.T(i18n.DefaultLocale, i18n.MsgInvalidCredentials)}, rsp)
+	}
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				// 登录成功之后清除该用户名的失败记录, 包括本次预先记录的失败
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).Times(1).Return(nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Sessions{}, nil)
+				// 当前的算法与参数不需要重新计算
+				store.EXPECT().RehashUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "旧算法的散列值重新计算",
+			body: gin.H{"username": legacyUser.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, legacyUser.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(legacyUser.Username)).Times(1).Return(legacyUser, nil)
+				store.EXPECT().
+					RehashUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RehashUserPasswordParams) error {
+						require.Equal(t, legacyUser.Username, arg.Username)
+						require.Equal(t, legacyUser.HashedPassword, arg.OldHashedPassword)
+						needsRehash, err := testPasswords.Verify(password, arg.HashedPassword)
+						require.NoError(t, err)
+						require.False(t, needsRehash)
+						return nil
+					})
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Sessions{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "重新计算失败不影响登录",
+			body: gin.H{"username": legacyUser.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, legacyUser.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(legacyUser.Username)).Times(1).Return(legacyUser, nil)
+				store.EXPECT().RehashUserPassword(gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().GetUserTotp(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, sql.ErrNoRows)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.Sessions{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "旧算法的散列值密码错误",
+			body: gin.H{"username": legacyUser.Username, "password": "wrong-password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, legacyUser.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(legacyUser.Username)).Times(1).Return(legacyUser, nil)
+				store.EXPECT().RehashUserPassword(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: invalidCredentials,
+		},
+		{
+			name: "密码错误",
+			body: gin.H{"username": user.Username, "password": "wrong-password"},
+			buildStubs: func(store *mockdb.MockStore) {
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: invalidCredentials,
+		},
+		{
+			name: "用户不存在",
+			body: gin.H{"username": "nobody", "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				// 不存在的用户名同样记录失败次数
+				reserveAttempt(t, store, "nobody")
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq("nobody")).Times(1).Return(db.Users{}, sql.ErrNoRows)
+				store.EXPECT().ResetLoginFailures(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: invalidCredentials,
+		},
+		{
+			name: "用户已删除",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				deletedAt := time.Now()
+				deleted := user
+				deleted.DeletedAt = &deletedAt
+				reserveAttempt(t, store, user.Username)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(deleted, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: invalidCredentials,
+		},
+		{
+			name: "失败次数过多",
+			body: gin.H{"username": user.Username, "password": password},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetLoginFailure(gomock.Any(), gomock.Eq(lockout.UserSubject(user.Username))).
+					Times(1).
+					Return(db.LoginFailures{FailedCount: 5, LastFailedAt: time.Now()}, nil)
+				// 等待期间即使密码正确也不校验, 也不计入失败次数
+				store.EXPECT().ReserveLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				retryAfter, err := strconv.Atoi(recorder.Header().Get("Retry-After"))
+				require.NoError(t, err)
+				require.InDelta(t, 2, retryAfter, 1)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuditTx(store)
+			tc.buildStubs(store)
+
+			// 只开启按用户名的限制
+			server := newTestServer(t, store)
+			server.lockout = lockout.NewGuard(store, testLoginPolicy, lockout.Policy{})
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -1,8 +1,20 @@
 package api
 
 import (
-	"github.com/go-playground/validator/v10"
+	"errors"
+	"reflect"
 	"simple_bank/pkg"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
 )
 
 var validCurrency validator.Func = func(fl validator.FieldLevel) bool {
@@ -11,3 +23,109 @@ var validCurrency validator.Func = func(fl validator.FieldLevel) bool {
 	}
 	return false
 }
+
+var validRole validator.Func = func(fl validator.FieldLevel) bool {
+	if role, ok := fl.Field().Interface().(string); ok {
+		return rbac.ValidRole(role)
+	}
+	return false
+}
+
+var validPermission validator.Func = func(fl validator.FieldLevel) bool {
+	if permission, ok := fl.Field().Interface().(string); ok {
+		return rbac.ValidPermission(rbac.Permission(permission))
+	}
+	return false
+}
+
+var validOAuthScope validator.Func = func(fl validator.FieldLevel) bool {
+	if scope, ok := fl.Field().Interface().(string); ok {
+		return token.ValidScope(scope)
+	}
+	return false
+}
+
+// 每种语言对应的校验错误翻译器
+var validationTranslators = map[string]ut.Translator{}
+
+// registerTranslations 为校验器注册各个语言的错误翻译, 并使用请求体的字段名称作为错误中的字段名
+func registerTranslations(validate *validator.Validate) error {
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, key := range []string{"json", "form", "uri"} {
+			name := strings.SplitN(field.Tag.Get(key), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return field.Name
+	})
+
+	uni := ut.New(zh.New(), zh.New(), en.New())
+	zhTrans, _ := uni.GetTranslator("zh")
+	enTrans, _ := uni.GetTranslator("en")
+
+	if err := zhTranslations.RegisterDefaultTranslations(validate, zhTrans); err != nil {
+		return err
+	}
+	if err := enTranslations.RegisterDefaultTranslations(validate, enTrans); err != nil {
+		return err
+	}
+
+	// 自定义校验标签的翻译
+	customTranslations := map[string]map[ut.Translator]string{
+		"currency": {
+			zhTrans: "{0}为不支持的货币类型",
+			enTrans: "{0} must be a supported currency",
+		},
+		"role": {
+			zhTrans: "{0}为不支持的角色",
+			enTrans: "{0} must be a supported role",
+		},
+		"permission": {
+			zhTrans: "{0}为不支持的权限",
+			enTrans: "{0} must be a supported permission",
+		},
+		"oauthscope": {
+			zhTrans: "{0}为不支持的授权范围",
+			enTrans: "{0} must be a supported scope",
+		},
+	}
+	for tag, translations := range customTranslations {
+		for trans, text := range translations {
+			err := validate.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+				return ut.Add(tag, text, true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T(tag, fe.Field())
+				return t
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	validationTranslators[i18n.ZhCN] = zhTrans
+	validationTranslators[i18n.EnUS] = enTrans
+	return nil
+}
+
+// translateValidationErrors 将校验错误翻译为指定语言, 按字段的声明顺序返回字段名与翻译后的错误
+// 非校验错误(例如JSON格式错误)时返回false
+func translateValidationErrors(locale string, err error) (fields []string, messages []string, ok bool) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil, nil, false
+	}
+	trans, ok := validationTranslators[locale]
+	if !ok {
+		trans = validationTranslators[i18n.DefaultLocale]
+	}
+	for _, fe := range validationErrors {
+		fields = append(fields, fe.Field())
+		messages = append(messages, fe.Translate(trans))
+	}
+	return fields, messages, true
+}
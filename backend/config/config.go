@@ -1,35 +1,279 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+// 运行环境
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
 type Config struct {
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SEVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBSource              string        `mapstructure:"DB_SOURCE" redact:"dsn"`
+	DBAutoMigrate         bool          `mapstructure:"DB_AUTO_MIGRATE"` // 启动时执行未执行的数据库迁移, 否则数据库版本落后时拒绝启动
+	ServerAddress         string        `mapstructure:"SERVER_ADDRESS"`
+	Environment           string        `mapstructure:"ENVIRONMENT"`        // 运行环境: development, production, 生产环境不允许只用于开发的配置
+	HTTPReadTimeout       time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`  // 读取请求的超时时间, 包括请求头与请求体
+	HTTPWriteTimeout      time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"` // 读取请求头之后写完响应的超时时间
+	HTTPIdleTimeout       time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`  // 保持连接时等待下一个请求的超时时间
+	ShutdownTimeout       time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`   // 收到退出信号之后等待处理中的请求与后台任务结束的最长时间, 为0则立即中断
+	TokenSymmetricKey     string        `mapstructure:"TOKEN_SYMMETRIC_KEY" redact:"true"`
+	TokenType             string        `mapstructure:"TOKEN_TYPE"`                // 令牌类型, 例如 paseto-v2-local, paseto-v4-public, jwt-hs256, jwt-eddsa, jwt-rs256
+	TokenPrivateKeyFile   string        `mapstructure:"TOKEN_PRIVATE_KEY_FILE"`    // 非对称令牌类型的PEM私钥文件
+	TokenKeyDir           string        `mapstructure:"TOKEN_KEY_DIR"`             // 密钥轮换的密钥目录, 设置之后忽略以上的单个密钥配置
+	TokenKeyReload        time.Duration `mapstructure:"TOKEN_KEY_RELOAD_INTERVAL"` // 重新加载密钥目录的间隔
+	AccessTokenDuration   time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration  time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	SessionCheckUserAgent bool          `mapstructure:"SESSION_CHECK_USER_AGENT"` // 刷新令牌时是否要求与登录时的User-Agent一致
+	SessionCheckClientIP  bool          `mapstructure:"SESSION_CHECK_CLIENT_IP"`  // 刷新令牌时是否要求与登录时的客户端IP一致
+	RevocationCacheTTL    time.Duration `mapstructure:"REVOCATION_CACHE_TTL"`     // 令牌未被撤销的检查结果在内存中的缓存时间
+	TOTPIssuer            string        `mapstructure:"TOTP_ISSUER"`              // 身份验证器应用中显示的发行方名称
+	MFAChallengeDuration  time.Duration `mapstructure:"MFA_CHALLENGE_DURATION"`   // 两步验证登录挑战的有效期
+	MailerType            string        `mapstructure:"MAILER_TYPE"`              // 邮件发送方式: log, file, smtp, 生产环境不能使用log
+	MailSender            string        `mapstructure:"MAIL_SENDER"`              // 发件人地址
+	MailDir               string        `mapstructure:"MAIL_DIR"`                 // file方式下邮件写入的目录
+	SMTPHost              string        `mapstructure:"SMTP_HOST"`
+	SMTPPort              int           `mapstructure:"SMTP_PORT"`
+	SMTPUsername          string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword          string        `mapstructure:"SMTP_PASSWORD" redact:"true"`
+	PasswordResetURL      string        `mapstructure:"PASSWORD_RESET_URL"`            // 重置密码页面的地址, 令牌作为token查询参数附加在后面
+	PasswordResetDuration time.Duration `mapstructure:"PASSWORD_RESET_TOKEN_DURATION"` // 重置密码令牌的有效期
+	EmailVerifyURL        string        `mapstructure:"EMAIL_VERIFY_URL"`              // 验证邮箱页面的地址, 令牌作为token查询参数附加在后面
+	EmailVerifyDuration   time.Duration `mapstructure:"EMAIL_VERIFICATION_DURATION"`   // 邮箱验证令牌的有效期
+	LoginFreeFailures     int           `mapstructure:"LOGIN_FREE_FAILURES"`           // 同一用户名连续登录失败超过该次数之后开始延迟
+	LoginMaxFailures      int           `mapstructure:"LOGIN_MAX_FAILURES"`            // 同一用户名连续登录失败达到该次数时临时锁定, 为0则不锁定
+	LoginIPFreeFailures   int           `mapstructure:"LOGIN_IP_FREE_FAILURES"`        // 同一客户端IP连续登录失败超过该次数之后开始延迟
+	LoginIPMaxFailures    int           `mapstructure:"LOGIN_IP_MAX_FAILURES"`         // 同一客户端IP连续登录失败达到该次数时临时锁定, 为0则不锁定
+	LoginDelayBase        time.Duration `mapstructure:"LOGIN_DELAY_BASE"`              // 渐进延迟的初始时间, 之后每次失败翻倍, 为0则不延迟
+	LoginDelayMax         time.Duration `mapstructure:"LOGIN_DELAY_MAX"`               // 渐进延迟的最长时间
+	LoginLockoutDuration  time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`        // 临时锁定的时长
+	LoginFailureWindow    time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`          // 超过该时间没有再失败则重新计数
+	APIKeyTouchInterval   time.Duration `mapstructure:"API_KEY_TOUCH_INTERVAL"`        // API密钥最近使用时间的更新间隔
+	OAuthCodeDuration     time.Duration `mapstructure:"OAUTH_CODE_DURATION"`           // OAuth2授权码的有效期
+	OAuthAccessDuration   time.Duration `mapstructure:"OAUTH_ACCESS_TOKEN_DURATION"`   // 第三方应用的访问令牌的有效期
+	OAuthRefreshDuration  time.Duration `mapstructure:"OAUTH_REFRESH_TOKEN_DURATION"`  // 第三方应用的刷新令牌的有效期
+	TokenAudience         string        `mapstructure:"TOKEN_AUDIENCE"`                // 受限令牌的受众, 本服务只接受该受众的令牌
+	ScopedMaxDuration     time.Duration `mapstructure:"SCOPED_TOKEN_MAX_DURATION"`     // 用户申请的受限令牌的最长有效期
+	StepUpThresholds      string        `mapstructure:"STEP_UP_THRESHOLDS"`            // 超过该金额的转账需要重新验证身份, 按货币配置, 例如 USD:100000,CNY:700000
+	StepUpDuration        time.Duration `mapstructure:"STEP_UP_TOKEN_DURATION"`        // 加强验证令牌的有效期, 也是重新验证身份之后的有效时间
+	PasswordHashAlgorithm string        `mapstructure:"PASSWORD_HASH_ALGORITHM"`       // 新的密码散列值使用的算法: argon2id, bcrypt, 旧算法的散列值在登录时重新计算
+	Argon2Memory          uint32        `mapstructure:"ARGON2_MEMORY"`                 // argon2id使用的内存, 单位为KiB
+	Argon2Iterations      uint32        `mapstructure:"ARGON2_ITERATIONS"`             // argon2id的迭代次数
+	Argon2Parallelism     uint8         `mapstructure:"ARGON2_PARALLELISM"`            // argon2id的线程数
+	BcryptCost            int           `mapstructure:"BCRYPT_COST"`                   // bcrypt的计算成本
+	PasswordMinLength     int           `mapstructure:"PASSWORD_MIN_LENGTH"`           // 新密码的最短长度, 为0则使用默认值8
+	PasswordMinClasses    int           `mapstructure:"PASSWORD_MIN_CHAR_CLASSES"`     // 新密码至少包含的字符类别数: 小写字母, 大写字母, 数字, 符号
+	PasswordMinScore      int           `mapstructure:"PASSWORD_MIN_SCORE"`            // 新密码强度评分的最低要求, 取值0~4, 为0则不检查
+	BreachedPasswordsFile string        `mapstructure:"BREACHED_PASSWORDS_FILE"`       // 已泄露密码的SHA-1散列值文件, 为空则不检查
+	AuditCheckpointPeriod time.Duration `mapstructure:"AUDIT_CHECKPOINT_INTERVAL"`     // 记录审计散列链检查点的间隔, 为0则不记录
+}
+
+// Options 加载配置的选项
+type Options struct {
+	// 配置文件所在的目录, 文件名为app.env, 文件不存在时只使用默认值与环境变量
+	Dir string
+	// 配置文件的路径, 设置之后忽略Dir, 文件不存在时返回错误
+	File string
+	// 命令行参数, 优先级最高
+	Flags *Flags
+}
+
+// LoadConfig 加载path目录下的app.env, 见 Load
+func LoadConfig(path string) (*Config, error) {
+	return Load(Options{Dir: path})
+}
+
+// Load 按 默认值, 配置文件, 环境变量, 命令行参数 的顺序加载配置, 后面的覆盖前面的, 最后校验配置
+// 密钥可以从文件读取, 例如 DB_SOURCE_FILE=/run/secrets/db_source, 见 secretFileKey
+func Load(opts Options) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType("env")
+
+	file := opts.File
+	if file == "" {
+		file = filepath.Join(opts.Dir, "app.env")
+		if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) {
+			file = ""
+		}
+	}
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := checkFileKeys(file, v.AllKeys()); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range defaults() {
+		v.SetDefault(key, value)
+	}
+	renameKeys(v, file)
+	v.AutomaticEnv()
+	if opts.Flags != nil {
+		opts.Flags.apply(v)
+	}
+	if err := loadSecretFiles(v); err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// renamedKeys 改过名字的配置项, 旧的名字仍然可以使用, 但会输出警告
+var renamedKeys = map[string]string{
+	"SEVER_ADDRESS": "SERVER_ADDRESS",
+}
+
+// renameKeys 将配置文件与环境变量中旧的名字映射到新的名字, 同时设置时使用新的名字
+func renameKeys(v *viper.Viper, file string) {
+	for old, key := range renamedKeys {
+		if v.InConfig(old) {
+			log.Printf("config: %s in %s is deprecated, use %s", old, file, key)
+			if !v.InConfig(key) {
+				v.RegisterAlias(old, key)
+			}
+		}
+		if _, ok := os.LookupEnv(old); ok {
+			log.Printf("config: environment variable %s is deprecated, use %s", old, key)
+		}
+		if err := v.BindEnv(key, key, old); err != nil {
+			panic(err)
+		}
+	}
 }
 
-func LoadConfig(path string) (cfg *Config, err error) {
-	viper.SetConfigName("app") // name of config file (without extension)
-	viper.SetConfigType("env") // REQUIRED if the config file does not have the extension in the name
-	viper.AddConfigPath(path)  // optionally look for config in the working directory
-	err = viper.ReadInConfig() // Find and read the config file
-	if err != nil {            // Handle errors reading the config file
-		panic(fmt.Errorf("fatal error config file: %w", err))
+// checkFileKeys 配置文件中不认识的配置项视为拼写错误
+func checkFileKeys(file string, fileKeys []string) error {
+	known := make(map[string]bool)
+	for _, key := range Keys() {
+		known[key] = true
+		if isSecret(key) {
+			known[secretFileKey(key)] = true
+		}
+	}
+	for old := range renamedKeys {
+		known[old] = true
 	}
 
-	// 自动从环境变量替换配置文件
-	viper.AutomaticEnv()
+	var errs []error
+	for _, key := range fileKeys {
+		key = strings.ToUpper(key)
+		if known[key] {
+			continue
+		}
+		if suggestion := closestKey(key); suggestion != "" {
+			errs = append(errs, fmt.Errorf("%s: unknown key %s, did you mean %s?", file, key, suggestion))
+		} else {
+			errs = append(errs, fmt.Errorf("%s: unknown key %s", file, key))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	err = viper.Unmarshal(&cfg)
-	if err != nil {
-		return
+// secretFileKey 从文件读取密钥的配置项, 值为文件路径
+func secretFileKey(key string) string {
+	return key + "_FILE"
+}
+
+// loadSecretFiles 读取密钥文件的内容作为密钥, 去掉末尾的换行, 不能同时直接设置密钥
+func loadSecretFiles(v *viper.Viper) error {
+	for _, key := range Keys() {
+		if !isSecret(key) {
+			continue
+		}
+		fileKey := secretFileKey(key)
+		if err := v.BindEnv(fileKey); err != nil {
+			return err
+		}
+		path := v.GetString(fileKey)
+		if path == "" {
+			continue
+		}
+		if v.GetString(key) != "" {
+			return fmt.Errorf("%s and %s are both set", key, fileKey)
+		}
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileKey, err)
+		}
+		v.Set(key, strings.TrimRight(string(secret), "\r\n"))
 	}
+	return nil
+}
+
+// Keys 按结构体中的顺序返回所有的配置项
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		keys = append(keys, t.Field(i).Tag.Get("mapstructure"))
+	}
+	return keys
+}
 
-	return
+func isSecret(key string) bool {
+	field, ok := fieldByKey(key)
+	return ok && field.Tag.Get("redact") != ""
+}
+
+func fieldByKey(key string) (reflect.StructField, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// closestKey 返回与key的编辑距离不超过2的配置项, 没有则返回空字符串
+func closestKey(key string) string {
+	best, bestDistance := "", 3
+	for _, known := range Keys() {
+		if distance := editDistance(key, known); distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
 }
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"reflect"
+	"time"
+)
+
+// defaults 配置项的默认值, 没有列出的配置项默认为零值
+// 密钥与数据库连接串没有默认值, 必须通过配置文件, 环境变量或密钥文件设置
+func defaults() map[string]any {
+	values := map[string]any{
+		"SERVER_ADDRESS":                ":8080",
+		"ENVIRONMENT":                   EnvDevelopment,
+		"HTTP_READ_TIMEOUT":             10 * time.Second,
+		"HTTP_WRITE_TIMEOUT":            30 * time.Second,
+		"HTTP_IDLE_TIMEOUT":             2 * time.Minute,
+		"SHUTDOWN_TIMEOUT":              20 * time.Second,
+		"TOKEN_TYPE":                    "paseto-v2-local",
+		"TOKEN_KEY_RELOAD_INTERVAL":     time.Minute,
+		"ACCESS_TOKEN_DURATION":         15 * time.Minute,
+		"REFRESH_TOKEN_DURATION":        24 * time.Hour,
+		"REVOCATION_CACHE_TTL":          30 * time.Second,
+		"TOTP_ISSUER":                   "SimpleBank",
+		"MFA_CHALLENGE_DURATION":        5 * time.Minute,
+		"MAILER_TYPE":                   "log",
+		"MAIL_SENDER":                   "no-reply@simplebank.local",
+		"MAIL_DIR":                      "./tmp/mail",
+		"SMTP_PORT":                     587,
+		"PASSWORD_RESET_URL":            "http://localhost:3000/reset-password",
+		"PASSWORD_RESET_TOKEN_DURATION": 30 * time.Minute,
+		"EMAIL_VERIFY_URL":              "http://localhost:3000/verify-email",
+		"EMAIL_VERIFICATION_DURATION":   24 * time.Hour,
+		"LOGIN_FREE_FAILURES":           3,
+		"LOGIN_MAX_FAILURES":            10,
+		"LOGIN_IP_FREE_FAILURES":        20,
+		"LOGIN_IP_MAX_FAILURES":         100,
+		"LOGIN_DELAY_BASE":              time.Second,
+		"LOGIN_DELAY_MAX":               30 * time.Second,
+		"LOGIN_LOCKOUT_DURATION":        15 * time.Minute,
+		"LOGIN_FAILURE_WINDOW":          15 * time.Minute,
+		"API_KEY_TOUCH_INTERVAL":        time.Minute,
+		"OAUTH_CODE_DURATION":           5 * time.Minute,
+		"OAUTH_ACCESS_TOKEN_DURATION":   15 * time.Minute,
+		"OAUTH_REFRESH_TOKEN_DURATION":  720 * time.Hour,
+		"TOKEN_AUDIENCE":                "simple_bank",
+		"SCOPED_TOKEN_MAX_DURATION":     720 * time.Hour,
+		"STEP_UP_TOKEN_DURATION":        5 * time.Minute,
+		"PASSWORD_HASH_ALGORITHM":       "argon2id",
+		"ARGON2_MEMORY":                 65536,
+		"ARGON2_ITERATIONS":             3,
+		"ARGON2_PARALLELISM":            2,
+		"BCRYPT_COST":                   10,
+		"PASSWORD_MIN_LENGTH":           10,
+		"PASSWORD_MIN_CHAR_CLASSES":     3,
+		"PASSWORD_MIN_SCORE":            3,
+		"AUDIT_CHECKPOINT_INTERVAL":     time.Hour,
+	}
+
+	// 环境变量只覆盖已知的配置项, 每个配置项都需要一个默认值
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if _, ok := values[key]; !ok {
+			values[key] = reflect.Zero(t.Field(i).Type).Interface()
+		}
+	}
+	return values
+}
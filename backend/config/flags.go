@@ -0,0 +1,44 @@
+package config
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Flags 命令行参数, 每个配置项对应一个参数, 例如 SERVER_ADDRESS 对应 -server-address
+type Flags struct {
+	set *flag.FlagSet
+	// 参数名对应的配置项
+	keys map[string]string
+}
+
+// RegisterFlags 为每个配置项在set中注册一个参数, 解析之后传给 Load, 只有命令行中出现的参数覆盖其它来源
+func RegisterFlags(set *flag.FlagSet) *Flags {
+	flags := &Flags{set: set, keys: make(map[string]string)}
+	for _, key := range Keys() {
+		name := flagName(key)
+		flags.keys[name] = key
+		set.String(name, "", "overrides "+key)
+		if isSecret(key) {
+			fileKey := secretFileKey(key)
+			flags.keys[flagName(fileKey)] = fileKey
+			set.String(flagName(fileKey), "", "file containing "+key)
+		}
+	}
+	return flags
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// apply 命令行参数的优先级最高
+func (f *Flags) apply(v *viper.Viper) {
+	f.set.Visit(func(fl *flag.Flag) {
+		if key, ok := f.keys[fl.Name]; ok {
+			v.Set(key, fl.Value.String())
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// 与 url.URL.Redacted 一致
+const redacted = "xxxxx"
+
+// Setting 一个配置项与生效的值
+type Setting struct {
+	Key   string
+	Value string
+}
+
+// Redacted 按结构体中的顺序返回所有生效的配置项, 密钥替换为 xxxxx, 数据库连接串只隐藏密码
+func (c *Config) Redacted() []Setting {
+	value := reflect.ValueOf(*c)
+	t := value.Type()
+	settings := make([]Setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		setting := Setting{
+			Key:   field.Tag.Get("mapstructure"),
+			Value: fmt.Sprint(value.Field(i).Interface()),
+		}
+		switch field.Tag.Get("redact") {
+		case "":
+		case "dsn":
+			setting.Value = redactDSN(setting.Value)
+		default:
+			if setting.Value != "" {
+				setting.Value = redacted
+			}
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// redactDSN URL形式的连接串隐藏密码, 其它形式的连接串可能在任意位置包含密码, 全部隐藏
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return redacted
+	}
+	// 查询参数中也可能包含密码
+	query := u.Query()
+	if query.Has("password") {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+
+	"simple_bank/pkg/mail"
+	"simple_bank/pkg/password"
+	"simple_bank/pkg/token"
+)
+
+// 对称令牌的密钥长度, 与 token.NewPasetoMaker, token.NewJWTMaker 的要求一致
+const symmetricKeySize = 32
+
+// validator 收集所有不合法的配置项, 一次返回
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, key string, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	v.check(d > 0, key, "must be a positive duration, got %s", d)
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	v.check(d >= 0, key, "must not be negative, got %s", d)
+}
+
+func (v *validator) url(key string, value string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && u.IsAbs() && u.Host != "", key, "must be an absolute url, got %q", value)
+}
+
+// Validate 检查配置项的取值, 返回所有不合法的配置项
+func (c *Config) Validate() error {
+	var v validator
+
+	v.check(c.Environment == EnvDevelopment || c.Environment == EnvProduction, "ENVIRONMENT",
+		"must be %s or %s, got %q", EnvDevelopment, EnvProduction, c.Environment)
+	if c.DBSource == "" {
+		v.check(false, "DB_SOURCE", "is required")
+	} else if _, err := pgxpool.ParseConfig(c.DBSource); err != nil {
+		// 解析错误中可能包含连接串, 不输出原始错误
+		v.check(false, "DB_SOURCE", "is not a valid postgres connection string")
+	}
+	_, _, err := net.SplitHostPort(c.ServerAddress)
+	v.check(err == nil, "SERVER_ADDRESS", "must be host:port, got %q", c.ServerAddress)
+	v.nonNegative("HTTP_READ_TIMEOUT", c.HTTPReadTimeout)
+	v.nonNegative("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout)
+	v.nonNegative("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+	v.nonNegative("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+
+	// 配置了密钥目录时不使用单个密钥
+	if c.TokenKeyDir == "" {
+		switch c.TokenType {
+		case "", token.TypePasetoV2Local:
+			v.check(len(c.TokenSymmetricKey) == symmetricKeySize, "TOKEN_SYMMETRIC_KEY",
+				"must be exactly %d bytes for %s, got %d", symmetricKeySize, c.TokenType, len(c.TokenSymmetricKey))
+		case token.TypeJWTHS256:
+			v.check(len(c.TokenSymmetricKey) >= symmetricKeySize, "TOKEN_SYMMETRIC_KEY",
+				"must be at least %d bytes for %s, got %d", symmetricKeySize, c.TokenType, len(c.TokenSymmetricKey))
+		case token.TypePasetoV4Public, token.TypeJWTEdDSA, token.TypeJWTRS256:
+			v.check(c.TokenPrivateKeyFile != "", "TOKEN_PRIVATE_KEY_FILE", "is required for %s", c.TokenType)
+		default:
+			v.check(false, "TOKEN_TYPE", "unsupported token type %q", c.TokenType)
+		}
+	}
+	v.nonNegative("TOKEN_KEY_RELOAD_INTERVAL", c.TokenKeyReload)
+	v.positive("ACCESS_TOKEN_DURATION", c.AccessTokenDuration)
+	v.positive("REFRESH_TOKEN_DURATION", c.RefreshTokenDuration)
+	v.check(c.RefreshTokenDuration >= c.AccessTokenDuration, "REFRESH_TOKEN_DURATION",
+		"must not be shorter than ACCESS_TOKEN_DURATION (%s)", c.AccessTokenDuration)
+	v.nonNegative("REVOCATION_CACHE_TTL", c.RevocationCacheTTL)
+	v.positive("MFA_CHALLENGE_DURATION", c.MFAChallengeDuration)
+	v.check(c.TokenAudience != "", "TOKEN_AUDIENCE", "is required")
+	v.positive("SCOPED_TOKEN_MAX_DURATION", c.ScopedMaxDuration)
+	v.positive("STEP_UP_TOKEN_DURATION", c.StepUpDuration)
+	v.positive("OAUTH_CODE_DURATION", c.OAuthCodeDuration)
+	v.positive("OAUTH_ACCESS_TOKEN_DURATION", c.OAuthAccessDuration)
+	v.positive("OAUTH_REFRESH_TOKEN_DURATION", c.OAuthRefreshDuration)
+	v.nonNegative("API_KEY_TOUCH_INTERVAL", c.APIKeyTouchInterval)
+
+	switch c.MailerType {
+	case "", mail.TypeLog:
+		// 日志中会留下收件人等个人信息, 生产环境必须真正发送邮件
+		v.check(c.Environment != EnvProduction, "MAILER_TYPE", "must not be %s in %s", mail.TypeLog, EnvProduction)
+	case mail.TypeFile:
+		v.check(c.MailDir != "", "MAIL_DIR", "is required for mailer type %s", c.MailerType)
+	case mail.TypeSMTP:
+		v.check(c.SMTPHost != "", "SMTP_HOST", "is required for mailer type %s", c.MailerType)
+		v.check(c.SMTPPort > 0 && c.SMTPPort <= 65535, "SMTP_PORT", "must be between 1 and 65535, got %d", c.SMTPPort)
+	default:
+		v.check(false, "MAILER_TYPE", "must be one of %s, %s, %s, got %q", mail.TypeLog, mail.TypeFile, mail.TypeSMTP, c.MailerType)
+	}
+	v.url("PASSWORD_RESET_URL", c.PasswordResetURL)
+	v.positive("PASSWORD_RESET_TOKEN_DURATION", c.PasswordResetDuration)
+	v.url("EMAIL_VERIFY_URL", c.EmailVerifyURL)
+	v.positive("EMAIL_VERIFICATION_DURATION", c.EmailVerifyDuration)
+
+	v.check(c.LoginFreeFailures >= 0, "LOGIN_FREE_FAILURES", "must not be negative")
+	v.check(c.LoginMaxFailures >= 0, "LOGIN_MAX_FAILURES", "must not be negative")
+	v.check(c.LoginIPFreeFailures >= 0, "LOGIN_IP_FREE_FAILURES", "must not be negative")
+	v.check(c.LoginIPMaxFailures >= 0, "LOGIN_IP_MAX_FAILURES", "must not be negative")
+	v.nonNegative("LOGIN_DELAY_BASE", c.LoginDelayBase)
+	v.check(c.LoginDelayMax >= c.LoginDelayBase, "LOGIN_DELAY_MAX", "must not be shorter than LOGIN_DELAY_BASE (%s)", c.LoginDelayBase)
+	v.nonNegative("LOGIN_LOCKOUT_DURATION", c.LoginLockoutDuration)
+	v.positive("LOGIN_FAILURE_WINDOW", c.LoginFailureWindow)
+
+	switch c.PasswordHashAlgorithm {
+	case "", password.AlgorithmArgon2id:
+		v.check(c.Argon2Memory > 0, "ARGON2_MEMORY", "must be positive")
+		v.check(c.Argon2Iterations > 0, "ARGON2_ITERATIONS", "must be positive")
+		v.check(c.Argon2Parallelism > 0, "ARGON2_PARALLELISM", "must be positive")
+	case password.AlgorithmBcrypt:
+	default:
+		v.check(false, "PASSWORD_HASH_ALGORITHM", "must be %s or %s, got %q", password.AlgorithmArgon2id, password.AlgorithmBcrypt, c.PasswordHashAlgorithm)
+	}
+	// 旧的bcrypt散列值在登录时仍需校验, 使用argon2id时也检查
+	v.check(c.BcryptCost >= bcrypt.MinCost && c.BcryptCost <= bcrypt.MaxCost, "BCRYPT_COST",
+		"must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, c.BcryptCost)
+	v.check(c.PasswordMinLength >= 0, "PASSWORD_MIN_LENGTH", "must not be negative")
+	v.check(c.PasswordMinClasses >= 0 && c.PasswordMinClasses <= 4, "PASSWORD_MIN_CHAR_CLASSES", "must be between 0 and 4, got %d", c.PasswordMinClasses)
+	v.check(c.PasswordMinScore >= 0 && c.PasswordMinScore <= 4, "PASSWORD_MIN_SCORE", "must be between 0 and 4, got %d", c.PasswordMinScore)
+	v.nonNegative("AUDIT_CHECKPOINT_INTERVAL", c.AuditCheckpointPeriod)
+
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid config:\n%w", errors.Join(v.errs...))
+	}
+	return nil
+}
@@ -3,5 +3,16 @@ package constants
 const (
 	AuthorizationHeaderKey  = "Authorization"
 	AuthorizationHeaderType = "bearer"
+	// AuthorizationAPIKeyType 服务之间调用使用API密钥的授权类型
+	AuthorizationAPIKeyType = "apikey"
 	AuthorizationPayloadKey = "authorizationPayloadKey"
+	// PermissionScopeKey 当前路由所需权限的作用范围
+	PermissionScopeKey = "permissionScopeKey"
+	// APIKeyPermissionsKey 使用API密钥认证时密钥被授予的权限
+	APIKeyPermissionsKey = "apiKeyPermissionsKey"
+	// StepUpHeaderKey 大额转账时携带加强验证令牌的请求头
+	StepUpHeaderKey = "X-Step-Up-Token"
+	// RequestIDHeaderKey 请求ID的请求头与响应头, 客户端未提供时生成
+	RequestIDHeaderKey = "X-Request-ID"
+	RequestIDKey       = "requestIDKey"
 )
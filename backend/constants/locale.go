@@ -0,0 +1,9 @@
+package constants
+
+const (
+	AcceptLanguageHeaderKey = "Accept-Language"
+	// LocaleQueryKey 用户通过查询参数或cookie指定的语言偏好
+	LocaleQueryKey  = "lang"
+	LocaleCookieKey = "lang"
+	LocaleKey       = "localeKey"
+)
@@ -0,0 +1,22 @@
+-- 会话表: 记录用户登录时颁发的刷新令牌, 每次刷新令牌都会轮换出一个新的会话
+-- 同一次登录轮换出的所有会话共享同一个family_id, 用于检测刷新令牌的重复使用
+CREATE TABLE sessions
+(
+    id            uuid PRIMARY KEY,                          -- 刷新令牌荷载的id
+    family_id     uuid                        NOT NULL,      -- 同一次登录轮换出的会话共享的id
+    username      varchar                     NOT NULL,
+    refresh_token varchar                     NOT NULL,
+    user_agent    varchar                     NOT NULL,
+    client_ip     varchar                     NOT NULL,
+    is_blocked    boolean     DEFAULT false   NOT NULL,
+    rotated_at    timestamptz,                               -- 刷新令牌被轮换的时间, 为空则表示仍可使用
+    expires_at    timestamptz                 NOT NULL,
+    created_at    timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE sessions
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX sessions_family_id ON sessions (family_id);
+CREATE INDEX sessions_username ON sessions (username);
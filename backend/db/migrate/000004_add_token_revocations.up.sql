@@ -0,0 +1,21 @@
+-- 被撤销的令牌: 按令牌荷载的id记录, 令牌过期之后该记录即可清理
+CREATE TABLE revoked_tokens
+(
+    id         uuid PRIMARY KEY,                      -- 令牌荷载的id
+    username   varchar                     NOT NULL,
+    expires_at timestamptz                 NOT NULL,  -- 令牌的过期时间, 过期之后记录可以被清理
+    created_at timestamptz DEFAULT (now()) NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+-- 用户级别的令牌撤销: 在 revoked_before 之前颁发的该用户的令牌全部失效, 用于退出所有设备
+CREATE TABLE user_token_revocations
+(
+    username       varchar PRIMARY KEY,
+    revoked_before timestamptz NOT NULL
+);
+
+ALTER TABLE user_token_revocations
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
@@ -0,0 +1,46 @@
+-- 两步验证的TOTP密钥: 每个用户最多一个, confirmed_at 为空表示尚未确认, 登录时不要求两步验证
+CREATE TABLE user_totp
+(
+    username       varchar PRIMARY KEY,
+    secret         varchar                     NOT NULL, -- base32编码的TOTP密钥
+    last_used_step bigint      DEFAULT 0       NOT NULL, -- 最近一次校验通过的时间步, 用于拒绝同一个一次性密码的重放
+    confirmed_at   timestamptz,                          -- 用户使用一次性密码确认绑定的时间
+    created_at     timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE user_totp
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+-- 恢复码: 只保存散列值, 每个恢复码只能使用一次
+CREATE TABLE recovery_codes
+(
+    id          bigserial PRIMARY KEY,
+    username    varchar                     NOT NULL,
+    hashed_code varchar                     NOT NULL,
+    used_at     timestamptz,                          -- 使用时间, 为空则表示仍可使用
+    created_at  timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE recovery_codes
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE UNIQUE INDEX recovery_codes_username_hashed_code ON recovery_codes (username, hashed_code);
+
+-- 两步验证的登录挑战: 密码校验通过之后颁发的短期令牌, 只保存散列值
+CREATE TABLE mfa_challenges
+(
+    hashed_token varchar PRIMARY KEY,
+    username     varchar                     NOT NULL,
+    attempts     integer     DEFAULT 0       NOT NULL, -- 校验失败的次数, 超过上限之后挑战失效
+    used_at      timestamptz,                          -- 挑战完成的时间, 每个挑战只能完成一次
+    expires_at   timestamptz                 NOT NULL,
+    created_at   timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE mfa_challenges
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX mfa_challenges_expires_at ON mfa_challenges (expires_at);
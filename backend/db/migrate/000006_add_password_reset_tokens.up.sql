@@ -0,0 +1,15 @@
+-- 重置密码的令牌: 只保存散列值, 每个令牌只能使用一次, 过期之后失效
+CREATE TABLE password_reset_tokens
+(
+    hashed_token varchar PRIMARY KEY,
+    username     varchar                     NOT NULL,
+    used_at      timestamptz,                          -- 使用时间, 为空则表示仍可使用
+    expires_at   timestamptz                 NOT NULL,
+    created_at   timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE password_reset_tokens
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX password_reset_tokens_username ON password_reset_tokens (username);
@@ -0,0 +1,24 @@
+-- 用户的邮箱是否已验证, 未验证的用户不能创建账户与转出金额
+ALTER TABLE users
+    ADD COLUMN is_email_verified boolean DEFAULT false NOT NULL;
+
+-- 已有的用户在注册时没有验证邮箱的流程, 视为已验证, 避免已有用户无法继续使用
+UPDATE users
+SET is_email_verified = true;
+
+-- 邮箱验证的令牌: 只保存散列值, 同时记录发送时的邮箱, 邮箱修改之后旧的令牌不能再验证新邮箱
+CREATE TABLE email_verifications
+(
+    hashed_token varchar PRIMARY KEY,
+    username     varchar                     NOT NULL,
+    email        varchar                     NOT NULL,
+    used_at      timestamptz,                          -- 使用时间, 为空则表示仍可使用
+    expires_at   timestamptz                 NOT NULL,
+    created_at   timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE email_verifications
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX email_verifications_username ON email_verifications (username);
@@ -0,0 +1,11 @@
+-- 登录失败记录: 分别按用户名与客户端IP统计连续失败的次数, 用于渐进延迟与临时锁定
+-- subject 的格式为 user:<用户名> 或 ip:<客户端IP>, 不存在的用户名同样记录, 避免泄露用户名是否存在
+CREATE TABLE login_failures
+(
+    subject        varchar PRIMARY KEY,
+    failed_count   int         NOT NULL DEFAULT 0,
+    last_failed_at timestamptz NOT NULL DEFAULT (now()),
+    locked_until   timestamptz -- 锁定的截止时间, 为空则表示未锁定
+);
+
+CREATE INDEX login_failures_last_failed_at ON login_failures (last_failed_at);
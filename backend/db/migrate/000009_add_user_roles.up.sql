@@ -0,0 +1,8 @@
+-- 用户的角色: customer(客户), teller(柜员), auditor(审计), admin(管理员)
+-- 已有的用户都是客户, 第一个管理员需要在数据库中指定:
+-- UPDATE users SET role = 'admin' WHERE username = '...';
+ALTER TABLE users
+    ADD COLUMN role varchar DEFAULT 'customer' NOT NULL;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_role_check CHECK (role IN ('customer', 'teller', 'auditor', 'admin'));
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS account_adjustments;
+
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS is_frozen;
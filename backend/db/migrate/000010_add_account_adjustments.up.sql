@@ -0,0 +1,29 @@
+-- 冻结的账户不能转入或转出金额
+ALTER TABLE accounts
+    ADD COLUMN is_frozen boolean DEFAULT false NOT NULL;
+
+-- 余额调整记录: 后台修正余额时不直接修改余额, 而是写入一条条目并记录调整的原因与操作人
+CREATE TABLE account_adjustments
+(
+    id         bigserial PRIMARY KEY,
+    account_id bigint                      NOT NULL,
+    entry_id   bigint                      NOT NULL, -- 调整对应的条目
+    amount     bigint                      NOT NULL, -- 调整的金额, 可以是正数或者负数
+    reason     varchar                     NOT NULL, -- 调整的原因
+    created_by varchar                     NOT NULL, -- 操作的管理员
+    created_at timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE account_adjustments
+    ADD
+        FOREIGN KEY ("account_id") REFERENCES accounts ("id");
+
+ALTER TABLE account_adjustments
+    ADD
+        FOREIGN KEY ("entry_id") REFERENCES entries ("id");
+
+ALTER TABLE account_adjustments
+    ADD
+        FOREIGN KEY ("created_by") REFERENCES users ("username");
+
+CREATE INDEX account_adjustments_account_id ON account_adjustments (account_id);
@@ -0,0 +1,20 @@
+-- API密钥: 供批处理等服务调用接口, 不需要保存用户的密码
+-- 密钥的明文只在创建时返回一次, 数据库只保存SHA-256散列值, prefix 为密钥开头的公开部分, 用于在列表中辨认密钥
+CREATE TABLE api_keys
+(
+    id           uuid PRIMARY KEY,
+    username     varchar                     NOT NULL,
+    name         varchar                     NOT NULL,
+    prefix       varchar UNIQUE              NOT NULL,
+    hashed_key   varchar UNIQUE              NOT NULL,
+    permissions  text[]                      NOT NULL, -- 密钥被授予的权限, 不能超出用户角色拥有的权限
+    expires_at   timestamptz,                          -- 为空则表示永不过期
+    last_used_at timestamptz,
+    created_at   timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE api_keys
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX api_keys_username ON api_keys (username);
@@ -0,0 +1,81 @@
+-- OAuth2 第三方应用
+-- 机密应用保存client_secret的散列值, 公开应用(浏览器或移动端)没有client_secret, 只能使用PKCE
+CREATE TABLE oauth_clients
+(
+    id            varchar PRIMARY KEY,
+    name          varchar                     NOT NULL,
+    hashed_secret varchar,                               -- 为空则表示公开应用
+    redirect_uris text[]                      NOT NULL,
+    scopes        text[]                      NOT NULL,  -- 应用可以申请的授权范围
+    owner         varchar                     NOT NULL,  -- 注册应用的用户
+    created_at    timestamptz DEFAULT (now()) NOT NULL
+);
+
+-- 授权码: 用户同意授权之后颁发, 只能使用一次
+CREATE TABLE oauth_authorization_codes
+(
+    hashed_code    varchar PRIMARY KEY,
+    client_id      varchar                     NOT NULL,
+    username       varchar                     NOT NULL,
+    redirect_uri   varchar                     NOT NULL,
+    scopes         text[]                      NOT NULL,
+    code_challenge varchar                     NOT NULL, -- PKCE的code_challenge, 只支持S256
+    expires_at     timestamptz                 NOT NULL,
+    used_at        timestamptz,                          -- 为空则表示尚未使用
+    created_at     timestamptz DEFAULT (now()) NOT NULL
+);
+
+-- 用户对第三方应用的授权, 用户可以随时撤销
+CREATE TABLE oauth_consents
+(
+    username   varchar                     NOT NULL,
+    client_id  varchar                     NOT NULL,
+    scopes     text[]                      NOT NULL,
+    created_at timestamptz DEFAULT (now()) NOT NULL,
+    updated_at timestamptz DEFAULT (now()) NOT NULL,
+    PRIMARY KEY (username, client_id)
+);
+
+-- 第三方应用的刷新令牌, 每次使用之后轮换
+CREATE TABLE oauth_refresh_tokens
+(
+    hashed_token varchar PRIMARY KEY,
+    client_id    varchar                     NOT NULL,
+    username     varchar                     NOT NULL,
+    scopes       text[]                      NOT NULL,
+    expires_at   timestamptz                 NOT NULL,
+    created_at   timestamptz DEFAULT (now()) NOT NULL
+);
+
+ALTER TABLE oauth_clients
+    ADD
+        FOREIGN KEY ("owner") REFERENCES users ("username");
+
+ALTER TABLE oauth_authorization_codes
+    ADD
+        FOREIGN KEY ("client_id") REFERENCES oauth_clients ("id") ON DELETE CASCADE;
+
+ALTER TABLE oauth_authorization_codes
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+ALTER TABLE oauth_consents
+    ADD
+        FOREIGN KEY ("client_id") REFERENCES oauth_clients ("id") ON DELETE CASCADE;
+
+ALTER TABLE oauth_consents
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+ALTER TABLE oauth_refresh_tokens
+    ADD
+        FOREIGN KEY ("client_id") REFERENCES oauth_clients ("id") ON DELETE CASCADE;
+
+ALTER TABLE oauth_refresh_tokens
+    ADD
+        FOREIGN KEY ("username") REFERENCES users ("username");
+
+CREATE INDEX oauth_clients_owner ON oauth_clients (owner);
+CREATE INDEX oauth_authorization_codes_expires_at ON oauth_authorization_codes (expires_at);
+CREATE INDEX oauth_refresh_tokens_username_client_id ON oauth_refresh_tokens (username, client_id);
+CREATE INDEX oauth_refresh_tokens_expires_at ON oauth_refresh_tokens (expires_at);
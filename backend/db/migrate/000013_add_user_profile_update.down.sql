@@ -0,0 +1,6 @@
+ALTER TABLE email_verifications
+    DROP COLUMN IF EXISTS is_email_change;
+
+DROP TRIGGER IF EXISTS users_set_updated_at ON users;
+
+DROP FUNCTION IF EXISTS set_updated_at();
@@ -0,0 +1,19 @@
+-- 修改用户表的任意字段时自动更新 updated_at, 不再依赖每条 UPDATE 语句手动设置
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS
+$$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_set_updated_at
+    BEFORE UPDATE
+    ON users
+    FOR EACH ROW
+    WHEN (OLD.* IS DISTINCT FROM NEW.*)
+EXECUTE FUNCTION set_updated_at();
+
+-- 修改邮箱的验证令牌: 令牌中的邮箱为新邮箱, 验证之后才替换用户当前的邮箱
+ALTER TABLE email_verifications
+    ADD COLUMN is_email_change boolean DEFAULT false NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS closed_at;
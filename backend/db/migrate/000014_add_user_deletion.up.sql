@@ -0,0 +1,7 @@
+-- 账户的关闭时间, 关闭的账户同时冻结, 保留账户与条目, 转账记录以保证账目完整
+ALTER TABLE accounts
+    ADD COLUMN closed_at timestamptz;
+
+-- 用户的删除时间: 删除时保留用户名作为账目的关联, 姓名, 邮箱与密码替换为无意义的值
+ALTER TABLE users
+    ADD COLUMN deleted_at timestamptz;
@@ -0,0 +1,20 @@
+-- 审计事件: 记录谁在什么时间对什么资源做了什么, 与变更写在同一个事务中
+-- 只追加, 不修改也不删除
+CREATE TABLE audit_events
+(
+    id            bigserial PRIMARY KEY,
+    actor         varchar                     NOT NULL, -- 操作人的用户名, 登录时为请求中的用户名
+    action        varchar                     NOT NULL, -- 操作, 例如 transfer.create
+    resource_type varchar                     NOT NULL, -- 资源类型, 例如 account, user
+    resource_id   varchar                     NOT NULL, -- 资源的ID
+    client_ip     varchar                     NOT NULL,
+    user_agent    varchar                     NOT NULL,
+    request_id    varchar                     NOT NULL, -- 请求ID, 与访问日志关联
+    before        jsonb,                                -- 变更之前的快照, 新建的资源为空
+    after         jsonb,                                -- 变更之后的快照
+    created_at    timestamptz DEFAULT (now()) NOT NULL
+);
+
+CREATE INDEX audit_events_actor ON audit_events (actor, created_at);
+CREATE INDEX audit_events_resource ON audit_events (resource_type, resource_id);
+CREATE INDEX audit_events_created_at ON audit_events (created_at);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+
+ALTER TABLE audit_events
+    DROP COLUMN IF EXISTS hash,
+    DROP COLUMN IF EXISTS prev_hash;
@@ -0,0 +1,36 @@
+-- 审计事件的散列链: 每条事件记录上一条事件的散列值, 自身的散列值覆盖上一条的散列值与本条的内容,
+-- 修改或删除任意一条事件都会使之后的链接断开. 加入散列链之前的事件散列值为空, 不参与校验
+ALTER TABLE audit_events
+    ADD COLUMN prev_hash bytea,
+    ADD COLUMN hash      bytea;
+
+-- 审计事件只追加: 禁止删除, 只允许为刚插入的事件写入一次散列值
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'UPDATE'
+        AND OLD.hash IS NULL
+        AND NEW.hash IS NOT NULL
+        AND (to_jsonb(OLD) - 'hash') = (to_jsonb(NEW) - 'hash') THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE
+    ON audit_events
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+-- 散列链的检查点: 定期记录链头的事件与散列值, 校验时重新计算的散列值必须与检查点一致
+CREATE TABLE audit_checkpoints
+(
+    id         bigserial PRIMARY KEY,
+    event_id   bigint                      NOT NULL, -- 链头的事件ID
+    hash       bytea                       NOT NULL, -- 链头的散列值
+    created_at timestamptz DEFAULT (now()) NOT NULL
+);
+
+CREATE UNIQUE INDEX audit_checkpoints_event_id ON audit_checkpoints (event_id);
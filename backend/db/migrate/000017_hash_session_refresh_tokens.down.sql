@@ -0,0 +1,6 @@
+-- 散列值无法还原为明文, 回滚之后已有的会话全部禁用, 用户需要重新登录
+UPDATE sessions
+SET is_blocked = true;
+
+ALTER TABLE sessions
+    RENAME COLUMN hashed_refresh_token TO refresh_token;
@@ -0,0 +1,7 @@
+-- 会话中只保存刷新令牌的散列值(SHA-256, 十六进制), 与 pkg.HashSecret 一致, 数据库泄露时其中的刷新令牌无法直接使用
+-- 已有的明文令牌就地计算散列值, 已颁发的刷新令牌仍可继续使用
+ALTER TABLE sessions
+    RENAME COLUMN refresh_token TO hashed_refresh_token;
+
+UPDATE sessions
+SET hashed_refresh_token = encode(sha256(convert_to(hashed_refresh_token, 'UTF8')), 'hex');
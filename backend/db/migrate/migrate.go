@@ -0,0 +1,301 @@
+// Package migrate 将db/migrate中的迁移文件嵌入到程序中, 由程序执行数据库迁移
+// 版本记录在schema_migrations表中, 与 https://github.com/golang-migrate/migrate 的格式相同, 两者可以混用
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// NilVersion 没有执行过任何迁移
+const NilVersion int64 = -1
+
+var (
+	// ErrDirty 上一次迁移执行到一半失败, 需要手动修复之后使用force设置版本
+	ErrDirty = errors.New("database schema is dirty")
+	// ErrSchemaBehind 数据库的版本低于程序需要的版本
+	ErrSchemaBehind = errors.New("database schema is behind")
+)
+
+// Migration 一个版本的升级与降级语句
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// 迁移文件名, 例如 000016_add_audit_chain.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Parse 读取目录中的迁移文件, 按版本升序返回, 每个版本必须同时有升级与降级文件
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has two names: %s, %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator 执行嵌入的迁移文件
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+// New 创建使用嵌入的迁移文件的Migrator
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := Parse(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// Latest 程序需要的数据库版本, 即最新的迁移文件的版本
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return NilVersion
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version 返回数据库当前的版本, 以及上一次迁移是否失败
+func (m *Migrator) Version(ctx context.Context) (version int64, dirty bool, err error) {
+	return readVersion(ctx, m.pool)
+}
+
+// Check 数据库的版本低于程序需要的版本时返回 ErrSchemaBehind, 上一次迁移失败时返回 ErrDirty
+// 数据库的版本更高时允许启动, 例如回滚程序时新增的表与字段不影响旧的程序
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, version)
+	}
+	if version < m.Latest() {
+		return fmt.Errorf("%w: version %d, expected %d", ErrSchemaBehind, version, m.Latest())
+	}
+	return nil
+}
+
+// Up 依次执行所有未执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migrate up %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("migrate: applied %d_%s", migration.Version, migration.Name)
+		}
+		return nil
+	})
+}
+
+// Down 从当前版本开始依次降级n个版本
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("number of migrations to revert must be positive, got %d", n)
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for ; n > 0 && version != NilVersion; n-- {
+			i := m.index(version)
+			if i < 0 {
+				return fmt.Errorf("database version %d has no migration file", version)
+			}
+			migration := m.migrations[i]
+			version = NilVersion
+			if i > 0 {
+				version = m.migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, migration.Down, version); err != nil {
+				return fmt.Errorf("migrate down %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("migrate: reverted %d_%s", migration.Version, migration.Name)
+		}
+		return nil
+	})
+}
+
+// Force 设置数据库的版本并清除失败标记, 不执行迁移, 用于手动修复失败的迁移之后
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != NilVersion && m.index(version) < 0 {
+		return fmt.Errorf("version %d has no migration file", version)
+	}
+	return m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		if err := setVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	})
+}
+
+func (m *Migrator) index(version int64) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// withLock 持有会话级的咨询锁执行迁移, 多个实例同时启动时只有一个执行, 其他的等待之后发现已是最新版本
+// 会话级的锁与连接绑定, 因此迁移都在同一个连接上执行
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock(hashtext('schema_migrations'))"); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// ctx取消之后仍需释放锁, 否则连接归还连接池之后一直持有锁
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock(hashtext('schema_migrations'))"); err != nil {
+			log.Printf("migrate: release migration lock: %v", err)
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)"); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// currentVersion 读取持有锁时的版本, 上一次迁移失败时不再继续
+func currentVersion(ctx context.Context, conn *pgxpool.Conn) (int64, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d, fix it manually and run migrate force", ErrDirty, version)
+	}
+	return version, nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func readVersion(ctx context.Context, q querier) (version int64, dirty bool, err error) {
+	err = q.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NilVersion, false, nil
+	}
+	// 表不存在时没有执行过迁移
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P01" {
+		return NilVersion, false, nil
+	}
+	return version, dirty, err
+}
+
+// apply 在一个事务中执行迁移语句并更新版本, 失败时整体回滚, 不会留下失败标记
+func apply(ctx context.Context, conn *pgxpool.Conn, statements string, version int64) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// 没有参数时使用简单协议, 一次可以执行多条语句
+	if _, err := tx.Exec(ctx, statements); err != nil {
+		return err
+	}
+	if err := setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// setVersion 表中只保留一行, 同时清除失败标记, NilVersion时清空
+func setVersion(ctx context.Context, tx pgx.Tx, version int64) error {
+	if _, err := tx.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+		return err
+	}
+	if version == NilVersion {
+		return nil
+	}
+	_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", version)
+	return err
+}
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEmbedded(t *testing.T) {
+	migrations, err := Parse(files)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	// 嵌入的迁移文件从1开始连续编号
+	for i, migration := range migrations {
+		require.Equal(t, int64(i+1), migration.Version)
+		require.NotEmpty(t, migration.Up)
+		require.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestParse(t *testing.T) {
+	file := func(content string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(content)}
+	}
+
+	testCases := []struct {
+		name  string
+		fsys  fstest.MapFS
+		check func(t *testing.T, migrations []Migration, err error)
+	}{
+		{
+			name: "OK",
+			fsys: fstest.MapFS{
+				"000010_b.up.sql":   file("CREATE TABLE b ();"),
+				"000010_b.down.sql": file("DROP TABLE b;"),
+				"000002_a.up.sql":   file("CREATE TABLE a ();"),
+				"000002_a.down.sql": file("DROP TABLE a;"),
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.NoError(t, err)
+				require.Equal(t, []Migration{
+					{Version: 2, Name: "a", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+					{Version: 10, Name: "b", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+				}, migrations)
+			},
+		},
+		{
+			name: "缺少降级文件",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql": file("CREATE TABLE a ();"),
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.EqualError(t, err, "migration 1_a needs both an up and a down file")
+			},
+		},
+		{
+			name: "文件名不合法",
+			fsys: fstest.MapFS{
+				"a.up.sql": file("CREATE TABLE a ();"),
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.EqualError(t, err, `invalid migration file name "a.up.sql"`)
+			},
+		},
+		{
+			name: "同一版本的名字不同",
+			fsys: fstest.MapFS{
+				"000001_a.up.sql":   file("CREATE TABLE a ();"),
+				"000001_b.down.sql": file("DROP TABLE b;"),
+			},
+			check: func(t *testing.T, migrations []Migration, err error) {
+				require.EqualError(t, err, "migration version 1 has two names: a, b")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := Parse(tc.fsys)
+			tc.check(t, migrations, err)
+		})
+	}
+}
@@ -38,3 +38,30 @@ FROM accounts
 WHERE owner = $1
 ORDER BY id
 LIMIT $2 OFFSET $3;
+
+-- name: SetAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: ListOwnerAccounts :many
+SELECT *
+FROM accounts
+WHERE owner = $1
+ORDER BY id;
+
+-- name: ListOwnerAccountsForUpdate :many
+SELECT *
+FROM accounts
+WHERE owner = $1
+ORDER BY id
+    FOR NO KEY UPDATE;
+
+-- name: CloseOwnerAccounts :many
+UPDATE accounts
+SET is_frozen = true,
+    closed_at = now()
+WHERE owner = $1
+  AND closed_at IS NULL
+RETURNING *;
@@ -0,0 +1,40 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id,
+                      username,
+                      name,
+                      prefix,
+                      hashed_key,
+                      permissions,
+                      expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT *
+FROM api_keys
+WHERE hashed_key = $1
+LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE username = $1
+ORDER BY created_at;
+
+-- name: DeleteAPIKey :one
+DELETE
+FROM api_keys
+WHERE id = $1
+  AND username = $2
+RETURNING *;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = sqlc.arg(id)
+  AND (last_used_at IS NULL OR last_used_at < sqlc.arg(touched_before)::timestamptz);
+
+-- name: DeleteUserAPIKeys :exec
+DELETE
+FROM api_keys
+WHERE username = $1;
@@ -0,0 +1,14 @@
+-- name: CreateAuditCheckpoint :one
+INSERT INTO audit_checkpoints (event_id, hash)
+SELECT id, hash
+FROM audit_events
+WHERE hash IS NOT NULL
+  AND id > COALESCE((SELECT max(event_id) FROM audit_checkpoints), 0)
+ORDER BY id DESC
+LIMIT 1
+RETURNING *;
+
+-- name: ListAuditCheckpoints :many
+SELECT *
+FROM audit_checkpoints
+ORDER BY event_id;
@@ -0,0 +1,47 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (actor,
+                          action,
+                          resource_type,
+                          resource_id,
+                          client_ip,
+                          user_agent,
+                          request_id,
+                          before,
+                          after,
+                          prev_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(action)::varchar IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(resource_type)::varchar IS NULL OR resource_type = sqlc.narg(resource_type))
+  AND (sqlc.narg(resource_id)::varchar IS NULL OR resource_id = sqlc.narg(resource_id))
+  AND (sqlc.narg(since)::timestamptz IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamptz IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id DESC
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetAuditChainHead :one
+SELECT *
+FROM audit_events
+WHERE hash IS NOT NULL
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: SetAuditEventHash :exec
+UPDATE audit_events
+SET hash = sqlc.arg(hash)
+WHERE id = sqlc.arg(id);
+
+-- name: ListAuditChain :many
+SELECT *
+FROM audit_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(limit);
@@ -0,0 +1,28 @@
+-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (hashed_token, username, email, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: UseEmailVerification :one
+UPDATE email_verifications
+SET used_at = now()
+WHERE hashed_token = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteExpiredEmailVerifications :exec
+DELETE
+FROM email_verifications
+WHERE expires_at < now();
+
+-- name: CreateEmailChange :one
+INSERT INTO email_verifications (hashed_token, username, email, expires_at, is_email_change)
+VALUES ($1, $2, $3, $4, true)
+RETURNING *;
+
+-- name: InvalidateEmailVerifications :exec
+UPDATE email_verifications
+SET used_at = now()
+WHERE username = $1
+  AND used_at IS NULL;
@@ -15,3 +15,10 @@ FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2 OFFSET $3;
+
+-- name: ListOwnerEntries :many
+SELECT entries.id, entries.account_id, entries.amount, entries.created_at
+FROM entries
+         JOIN accounts ON accounts.id = entries.account_id
+WHERE accounts.owner = $1
+ORDER BY entries.id;
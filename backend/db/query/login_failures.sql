@@ -0,0 +1,44 @@
+-- name: GetLoginFailure :one
+SELECT *
+FROM login_failures
+WHERE subject = $1
+LIMIT 1;
+
+-- name: ReserveLoginAttempt :one
+INSERT INTO login_failures (subject, failed_count, last_failed_at)
+VALUES (sqlc.arg(subject), 1, now())
+ON CONFLICT (subject) DO UPDATE
+    SET failed_count   = CASE
+                             WHEN login_failures.last_failed_at < sqlc.arg(window_start) THEN 1
+                             ELSE login_failures.failed_count + 1
+        END,
+        last_failed_at = now()
+    WHERE login_failures.failed_count = sqlc.arg(failed_count)
+      AND login_failures.last_failed_at = sqlc.arg(last_failed_at)
+RETURNING *;
+
+-- name: RefundLoginAttempt :exec
+UPDATE login_failures
+SET failed_count = failed_count - 1,
+    locked_until = CASE
+                       WHEN failed_count - 1 < sqlc.arg(max_failures) THEN NULL
+                       ELSE locked_until
+        END
+WHERE subject = sqlc.arg(subject)
+  AND failed_count > 0;
+
+-- name: LockLogin :exec
+UPDATE login_failures
+SET locked_until = $2
+WHERE subject = $1;
+
+-- name: ResetLoginFailures :exec
+DELETE
+FROM login_failures
+WHERE subject = $1;
+
+-- name: DeleteStaleLoginFailures :exec
+DELETE
+FROM login_failures
+WHERE last_failed_at < $1
+  AND (locked_until IS NULL OR locked_until < now());
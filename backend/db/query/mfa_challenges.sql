@@ -0,0 +1,31 @@
+-- name: CreateMfaChallenge :one
+INSERT INTO mfa_challenges (hashed_token, username, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetMfaChallenge :one
+SELECT *
+FROM mfa_challenges
+WHERE hashed_token = $1
+LIMIT 1;
+
+-- name: IncrementMfaChallengeAttempts :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE hashed_token = sqlc.arg(hashed_token)
+  AND attempts < sqlc.arg(max_attempts)
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: CompleteMfaChallenge :one
+UPDATE mfa_challenges
+SET used_at = now()
+WHERE hashed_token = $1
+  AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteExpiredMfaChallenges :exec
+DELETE
+FROM mfa_challenges
+WHERE expires_at < now();
@@ -0,0 +1,22 @@
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (hashed_code,
+                                       client_id,
+                                       username,
+                                       redirect_uri,
+                                       scopes,
+                                       code_challenge,
+                                       expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = now()
+WHERE hashed_code = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: DeleteExpiredOAuthAuthorizationCodes :exec
+DELETE
+FROM oauth_authorization_codes
+WHERE expires_at < now();
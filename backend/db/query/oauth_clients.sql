@@ -0,0 +1,28 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id,
+                           name,
+                           hashed_secret,
+                           redirect_uris,
+                           scopes,
+                           owner)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListOAuthClients :many
+SELECT *
+FROM oauth_clients
+WHERE owner = $1
+ORDER BY created_at;
+
+-- name: DeleteOAuthClient :one
+DELETE
+FROM oauth_clients
+WHERE id = $1
+  AND owner = $2
+RETURNING *;
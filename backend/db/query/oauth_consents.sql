@@ -0,0 +1,34 @@
+-- name: UpsertOAuthConsent :one
+INSERT INTO oauth_consents (username,
+                            client_id,
+                            scopes)
+VALUES ($1, $2, $3)
+ON CONFLICT (username, client_id) DO UPDATE
+    SET scopes     = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes) ORDER BY 1),
+        updated_at = now()
+RETURNING *;
+
+-- name: GetOAuthConsent :one
+SELECT *
+FROM oauth_consents
+WHERE username = $1
+  AND client_id = $2
+LIMIT 1;
+
+-- name: ListOAuthConsents :many
+SELECT *
+FROM oauth_consents
+WHERE username = $1
+ORDER BY created_at;
+
+-- name: DeleteOAuthConsent :one
+DELETE
+FROM oauth_consents
+WHERE username = $1
+  AND client_id = $2
+RETURNING *;
+
+-- name: DeleteUserOAuthConsents :exec
+DELETE
+FROM oauth_consents
+WHERE username = $1;
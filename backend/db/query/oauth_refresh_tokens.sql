@@ -0,0 +1,30 @@
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO oauth_refresh_tokens (hashed_token,
+                                  client_id,
+                                  username,
+                                  scopes,
+                                  expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ConsumeOAuthRefreshToken :one
+DELETE
+FROM oauth_refresh_tokens
+WHERE hashed_token = $1
+RETURNING *;
+
+-- name: DeleteOAuthRefreshTokens :exec
+DELETE
+FROM oauth_refresh_tokens
+WHERE username = $1
+  AND client_id = $2;
+
+-- name: DeleteExpiredOAuthRefreshTokens :exec
+DELETE
+FROM oauth_refresh_tokens
+WHERE expires_at < now();
+
+-- name: DeleteUserOAuthRefreshTokens :exec
+DELETE
+FROM oauth_refresh_tokens
+WHERE username = $1;
@@ -0,0 +1,31 @@
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (hashed_token, username, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetPasswordResetToken :one
+SELECT *
+FROM password_reset_tokens
+WHERE hashed_token = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+LIMIT 1;
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE hashed_token = $1
+  AND used_at IS NULL
+  AND expires_at > now()
+RETURNING *;
+
+-- name: InvalidateUserPasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = now()
+WHERE username = $1
+  AND used_at IS NULL;
+
+-- name: DeleteExpiredPasswordResetTokens :exec
+DELETE
+FROM password_reset_tokens
+WHERE expires_at < now();
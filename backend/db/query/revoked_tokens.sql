@@ -0,0 +1,26 @@
+-- name: CreateRevokedToken :exec
+INSERT INTO revoked_tokens (id, username, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: IsTokenRevoked :one
+SELECT (EXISTS (SELECT 1 FROM revoked_tokens WHERE id = sqlc.arg(id))
+    OR EXISTS (SELECT 1
+               FROM user_token_revocations
+               WHERE username = sqlc.arg(username)
+                 AND revoked_before > sqlc.arg(issued_at))
+    -- 修改密码之前颁发的令牌一律失效
+    OR EXISTS (SELECT 1
+               FROM users
+               WHERE username = sqlc.arg(username)
+                 AND password_changed_at > sqlc.arg(issued_at)))::boolean AS revoked;
+
+-- name: RevokeUserTokens :exec
+INSERT INTO user_token_revocations (username, revoked_before)
+VALUES ($1, $2)
+ON CONFLICT (username) DO UPDATE SET revoked_before = EXCLUDED.revoked_before;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE
+FROM revoked_tokens
+WHERE expires_at < now();
@@ -0,0 +1,34 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id,
+                      family_id,
+                      username,
+                      hashed_refresh_token,
+                      user_agent,
+                      client_ip,
+                      is_blocked,
+                      expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetSession :one
+SELECT *
+FROM sessions
+WHERE id = $1
+LIMIT 1;
+
+-- name: RotateSession :one
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1
+  AND rotated_at IS NULL
+RETURNING *;
+
+-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = $1;
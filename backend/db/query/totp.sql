@@ -0,0 +1,51 @@
+-- name: UpsertUserTotp :one
+INSERT INTO user_totp (username, secret)
+VALUES ($1, $2)
+ON CONFLICT (username) DO UPDATE SET secret         = EXCLUDED.secret,
+                                     last_used_step = 0,
+                                     confirmed_at   = NULL,
+                                     created_at     = now()
+RETURNING *;
+
+-- name: GetUserTotp :one
+SELECT *
+FROM user_totp
+WHERE username = $1
+LIMIT 1;
+
+-- name: ConfirmUserTotp :one
+UPDATE user_totp
+SET confirmed_at   = now(),
+    last_used_step = $2
+WHERE username = $1
+  AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTotpStep :one
+UPDATE user_totp
+SET last_used_step = $2
+WHERE username = $1
+  AND last_used_step < $2
+RETURNING *;
+
+-- name: DeleteUserTotp :exec
+DELETE
+FROM user_totp
+WHERE username = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (username, hashed_code)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :one
+UPDATE recovery_codes
+SET used_at = now()
+WHERE username = $1
+  AND hashed_code = $2
+  AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE
+FROM recovery_codes
+WHERE username = $1;
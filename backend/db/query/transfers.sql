@@ -16,3 +16,16 @@ WHERE from_account_id = $1
    OR to_account_id = $2
 ORDER BY id
 LIMIT $3 OFFSET $4;
+
+-- name: ListRecentTransfers :many
+SELECT *
+FROM transfers
+ORDER BY id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: ListOwnerTransfers :many
+SELECT *
+FROM transfers
+WHERE from_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+ORDER BY id;
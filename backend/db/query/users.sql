@@ -11,3 +11,71 @@ SELECT *
 FROM users
 WHERE username = $1
 LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT *
+FROM users
+WHERE email = $1
+LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password     = $2,
+    password_changed_at = now(),
+    updated_at          = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(hashed_password)
+WHERE username = sqlc.arg(username)
+  AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true,
+    updated_at        = now()
+WHERE username = $1
+  AND email = $2
+RETURNING *;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role       = $2,
+    updated_at = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: SearchUsers :many
+SELECT *
+FROM users
+WHERE username ILIKE '%' || sqlc.arg(query) || '%'
+   OR full_name ILIKE '%' || sqlc.arg(query) || '%'
+   OR email ILIKE '%' || sqlc.arg(query) || '%'
+ORDER BY username
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: UpdateUser :one
+UPDATE users
+SET full_name = COALESCE(sqlc.narg(full_name), full_name)
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: ChangeUserEmail :one
+UPDATE users
+SET email             = $2,
+    is_email_verified = true
+WHERE username = $1
+RETURNING *;
+
+-- name: PseudonymizeUser :one
+UPDATE users
+SET full_name         = '',
+    email             = sqlc.arg(email),
+    hashed_password   = '',
+    is_email_verified = false,
+    deleted_at        = now()
+WHERE username = sqlc.arg(username)
+  AND deleted_at IS NULL
+RETURNING *;
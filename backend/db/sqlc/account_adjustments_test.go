@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdjustBalanceTx(t *testing.T) {
+	account := createRandomAccount(t)
+	admin := createRandomUser(t)
+
+	result, err := sqlStore.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID: account.ID,
+		Amount:    -account.Balance,
+		Reason:    "冲正",
+		CreatedBy: admin.Username,
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.Account.Balance)
+	require.Equal(t, -account.Balance, result.Entry.Amount)
+	require.Equal(t, result.Entry.ID, result.Adjustment.EntryID)
+	require.Equal(t, admin.Username, result.Adjustment.CreatedBy)
+
+	// 余额不能被调整为负数, 事务回滚后不留下调整记录
+	_, err = sqlStore.AdjustBalanceTx(context.Background(), AdjustBalanceTxParams{
+		AccountID: account.ID,
+		Amount:    -1,
+		Reason:    "冲正",
+		CreatedBy: admin.Username,
+	})
+	require.True(t, errors.Is(err, ErrNegativeBalance))
+
+	adjustments, err := sqlStore.ListAccountAdjustments(context.Background(), ListAccountAdjustmentsParams{
+		AccountID: account.ID,
+		Limit:     5,
+	})
+	require.NoError(t, err)
+	require.Len(t, adjustments, 1)
+}
+
+func TestSetAccountFrozen(t *testing.T) {
+	account := createRandomAccount(t)
+	require.False(t, account.IsFrozen)
+
+	frozen, err := sqlStore.SetAccountFrozen(context.Background(), SetAccountFrozenParams{ID: account.ID, IsFrozen: true})
+	require.NoError(t, err)
+	require.True(t, frozen.IsFrozen)
+}
@@ -13,7 +13,7 @@ const AddAccountBalancer = `-- name: AddAccountBalancer :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 `
 
 type AddAccountBalancerParams struct {
@@ -26,7 +26,7 @@ type AddAccountBalancerParams struct {
 //	UPDATE accounts
 //	SET balance = balance + $1
 //	WHERE id = $2
-//	RETURNING id, owner, balance, currency, created_at
+//	RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 func (q *Queries) AddAccountBalancer(ctx context.Context, arg AddAccountBalancerParams) (Accounts, error) {
 	row := q.db.QueryRow(ctx, AddAccountBalancer, arg.Amount, arg.ID)
 	var i Accounts
@@ -36,14 +36,61 @@ func (q *Queries) AddAccountBalancer(ctx context.Context, arg AddAccountBalancer
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
+const CloseOwnerAccounts = `-- name: CloseOwnerAccounts :many
+UPDATE accounts
+SET is_frozen = true,
+    closed_at = now()
+WHERE owner = $1
+  AND closed_at IS NULL
+RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+`
+
+// CloseOwnerAccounts
+//
+//	UPDATE accounts
+//	SET is_frozen = true,
+//	    closed_at = now()
+//	WHERE owner = $1
+//	  AND closed_at IS NULL
+//	RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+func (q *Queries) CloseOwnerAccounts(ctx context.Context, owner string) ([]Accounts, error) {
+	rows, err := q.db.Query(ctx, CloseOwnerAccounts, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Accounts{}
+	for rows.Next() {
+		var i Accounts
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const CreateAccount = `-- name: CreateAccount :one
 INSERT INTO accounts(owner, balance, currency)
 VALUES ($1, $2, $3)
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 `
 
 type CreateAccountParams struct {
@@ -56,7 +103,7 @@ type CreateAccountParams struct {
 //
 //	INSERT INTO accounts(owner, balance, currency)
 //	VALUES ($1, $2, $3)
-//	RETURNING id, owner, balance, currency, created_at
+//	RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Accounts, error) {
 	row := q.db.QueryRow(ctx, CreateAccount, arg.Owner, arg.Balance, arg.Currency)
 	var i Accounts
@@ -66,6 +113,8 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
@@ -87,7 +136,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const GetAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 FROM accounts
 WHERE id = $1
 ORDER BY id
@@ -95,7 +144,7 @@ ORDER BY id
 
 // GetAccount
 //
-//	SELECT id, owner, balance, currency, created_at
+//	SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 //	FROM accounts
 //	WHERE id = $1
 //	ORDER BY id
@@ -108,12 +157,14 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Accounts, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
 const GetAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 FROM accounts
 WHERE id = $1
     FOR NO KEY UPDATE
@@ -121,7 +172,7 @@ WHERE id = $1
 
 // GetAccountForUpdate
 //
-//	SELECT id, owner, balance, currency, created_at
+//	SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 //	FROM accounts
 //	WHERE id = $1
 //	    FOR NO KEY UPDATE
@@ -134,12 +185,14 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Accounts,
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
 const ListAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 FROM accounts
 WHERE owner = $1
 ORDER BY id
@@ -154,7 +207,7 @@ type ListAccountsParams struct {
 
 // ListAccounts
 //
-//	SELECT id, owner, balance, currency, created_at
+//	SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 //	FROM accounts
 //	WHERE owner = $1
 //	ORDER BY id
@@ -174,6 +227,8 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.ClosedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -185,11 +240,128 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	return items, nil
 }
 
+const ListOwnerAccounts = `-- name: ListOwnerAccounts :many
+SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+FROM accounts
+WHERE owner = $1
+ORDER BY id
+`
+
+// ListOwnerAccounts
+//
+//	SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+//	FROM accounts
+//	WHERE owner = $1
+//	ORDER BY id
+func (q *Queries) ListOwnerAccounts(ctx context.Context, owner string) ([]Accounts, error) {
+	rows, err := q.db.Query(ctx, ListOwnerAccounts, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Accounts{}
+	for rows.Next() {
+		var i Accounts
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListOwnerAccountsForUpdate = `-- name: ListOwnerAccountsForUpdate :many
+SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+FROM accounts
+WHERE owner = $1
+ORDER BY id
+    FOR NO KEY UPDATE
+`
+
+// ListOwnerAccountsForUpdate
+//
+//	SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+//	FROM accounts
+//	WHERE owner = $1
+//	ORDER BY id
+//	    FOR NO KEY UPDATE
+func (q *Queries) ListOwnerAccountsForUpdate(ctx context.Context, owner string) ([]Accounts, error) {
+	rows, err := q.db.Query(ctx, ListOwnerAccountsForUpdate, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Accounts{}
+	for rows.Next() {
+		var i Accounts
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const SetAccountFrozen = `-- name: SetAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+`
+
+type SetAccountFrozenParams struct {
+	ID       int64 `json:"id"`
+	IsFrozen bool  `json:"isFrozen"`
+}
+
+// SetAccountFrozen
+//
+//	UPDATE accounts
+//	SET is_frozen = $2
+//	WHERE id = $1
+//	RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+func (q *Queries) SetAccountFrozen(ctx context.Context, arg SetAccountFrozenParams) (Accounts, error) {
+	row := q.db.QueryRow(ctx, SetAccountFrozen, arg.ID, arg.IsFrozen)
+	var i Accounts
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
 const UpdateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
 SET balance = $2
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 `
 
 type UpdateAccountParams struct {
@@ -202,7 +374,7 @@ type UpdateAccountParams struct {
 //	UPDATE accounts
 //	SET balance = $2
 //	WHERE id = $1
-//	RETURNING id, owner, balance, currency, created_at
+//	RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Accounts, error) {
 	row := q.db.QueryRow(ctx, UpdateAccount, arg.ID, arg.Balance)
 	var i Accounts
@@ -212,6 +384,8 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.ClosedAt,
 	)
 	return i, err
 }
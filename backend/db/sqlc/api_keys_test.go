@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestAPIKey(t *testing.T) {
+	sqlStore = newDB(t)
+	user := createRandomUser(t)
+	hashedKey := pkg.HashSecret(pkg.RandomString(32))
+
+	apiKey, err := sqlStore.CreateAPIKey(context.Background(), CreateAPIKeyParams{
+		ID:          uuid.New(),
+		Username:    user.Username,
+		Name:        "nightly-report",
+		Prefix:      "sbk_" + pkg.RandomString(8),
+		HashedKey:   hashedKey,
+		Permissions: []string{"accounts:read"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"accounts:read"}, apiKey.Permissions)
+	require.Nil(t, apiKey.ExpiresAt)
+	require.Nil(t, apiKey.LastUsedAt)
+
+	// 最近使用时间晚于 touched_before 时不再更新
+	err = sqlStore.TouchAPIKey(context.Background(), TouchAPIKeyParams{ID: apiKey.ID, TouchedBefore: time.Now()})
+	require.NoError(t, err)
+	got, err := sqlStore.GetAPIKeyByHash(context.Background(), hashedKey)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+	lastUsedAt := *got.LastUsedAt
+
+	err = sqlStore.TouchAPIKey(context.Background(), TouchAPIKeyParams{ID: apiKey.ID, TouchedBefore: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	got, err = sqlStore.GetAPIKeyByHash(context.Background(), hashedKey)
+	require.NoError(t, err)
+	require.Equal(t, lastUsedAt, *got.LastUsedAt)
+
+	// 只能删除自己的密钥
+	_, err = sqlStore.DeleteAPIKey(context.Background(), DeleteAPIKeyParams{ID: apiKey.ID, Username: "other"})
+	require.Error(t, err)
+	_, err = sqlStore.DeleteAPIKey(context.Background(), DeleteAPIKeyParams{ID: apiKey.ID, Username: user.Username})
+	require.NoError(t, err)
+
+	apiKeys, err := sqlStore.ListAPIKeys(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Empty(t, apiKeys)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// appendAuditEvent 将事件追加到散列链的末尾
+// 事务级的咨询锁使并发的事务依次追加, 锁在事务结束时释放, 因此事件ID的顺序就是链的顺序
+func appendAuditEvent(ctx context.Context, q *Queries, arg CreateAuditEventParams) error {
+	if err := q.LockAuditChain(ctx); err != nil {
+		return err
+	}
+	head, err := q.GetAuditChainHead(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	arg.PrevHash = head.Hash
+	event, err := q.CreateAuditEvent(ctx, arg)
+	if err != nil {
+		return err
+	}
+	// 按数据库返回的内容计算散列值, 与校验时读取的内容一致, 例如jsonb规范化之后的快照
+	return q.SetAuditEventHash(ctx, SetAuditEventHashParams{
+		Hash: AuditEventHash(event),
+		ID:   event.ID,
+	})
+}
+
+// AuditEventHash 计算事件在散列链中的散列值: SHA-256(上一条的散列值, 本条的各个字段)
+// 每个字段之前写入长度, 字段的边界移动之后得到的散列值不同
+func AuditEventHash(event AuditEvents) []byte {
+	h := sha256.New()
+	var size [binary.MaxVarintLen64]byte
+	writeField := func(value []byte) {
+		h.Write(size[:binary.PutUvarint(size[:], uint64(len(value)))])
+		h.Write(value)
+	}
+
+	writeField(event.PrevHash)
+	writeField([]byte(strconv.FormatInt(event.ID, 10)))
+	for _, value := range []string{
+		event.Actor,
+		event.Action,
+		event.ResourceType,
+		event.ResourceID,
+		event.ClientIp,
+		event.UserAgent,
+		event.RequestID,
+	} {
+		writeField([]byte(value))
+	}
+	writeField(event.Before)
+	writeField(event.After)
+	writeField([]byte(event.CreatedAt.UTC().Format(time.RFC3339Nano)))
+	return h.Sum(nil)
+}
+
+// AuditChainBreak 散列链中第一个断开的链接
+type AuditChainBreak struct {
+	EventID int64
+	Reason  string
+}
+
+// AuditChainReport 散列链的校验结果
+type AuditChainReport struct {
+	Events      int64 // 校验的事件数
+	Unchained   int64 // 加入散列链之前的事件数
+	Checkpoints int   // 核对的检查点数
+	HeadID      int64
+	Head        []byte
+	// 为nil时散列链完整
+	Broken *AuditChainBreak
+}
+
+// VerifyAuditChain 按ID顺序遍历审计事件, 重新计算每条事件的散列值并核对检查点, 遇到第一个断开的链接时停止
+// 修改事件会使重新计算的散列值不一致, 删除或插入事件会使下一条的prev_hash不一致,
+// 删除链尾的事件或重新计算整条链时与检查点不一致
+func VerifyAuditChain(ctx context.Context, q Querier, batchSize int64) (AuditChainReport, error) {
+	var report AuditChainReport
+
+	checkpoints, err := q.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return report, err
+	}
+	checkpointHashes := make(map[int64][]byte, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		checkpointHashes[checkpoint.EventID] = checkpoint.Hash
+	}
+
+	var afterID int64
+	for {
+		events, err := q.ListAuditChain(ctx, ListAuditChainParams{AfterID: afterID, Limit: batchSize})
+		if err != nil {
+			return report, err
+		}
+
+		for _, event := range events {
+			afterID = event.ID
+			if event.Hash == nil {
+				if report.Head == nil {
+					report.Unchained++
+					continue
+				}
+				report.Broken = &AuditChainBreak{EventID: event.ID, Reason: "hash is missing"}
+				return report, nil
+			}
+
+			report.Events++
+			if !bytes.Equal(event.PrevHash, report.Head) {
+				report.Broken = &AuditChainBreak{EventID: event.ID, Reason: "prev_hash does not match the previous event"}
+				return report, nil
+			}
+			hash := AuditEventHash(event)
+			if !bytes.Equal(hash, event.Hash) {
+				report.Broken = &AuditChainBreak{EventID: event.ID, Reason: "hash does not match the event content"}
+				return report, nil
+			}
+			if checkpoint, ok := checkpointHashes[event.ID]; ok {
+				if !bytes.Equal(checkpoint, hash) {
+					report.Broken = &AuditChainBreak{EventID: event.ID, Reason: "hash does not match the checkpoint"}
+					return report, nil
+				}
+				report.Checkpoints++
+				delete(checkpointHashes, event.ID)
+			}
+			report.HeadID = event.ID
+			report.Head = hash
+		}
+
+		if int64(len(events)) < batchSize {
+			break
+		}
+	}
+
+	// 剩下的检查点对应的事件已不存在, 链尾被删除
+	for _, checkpoint := range checkpoints {
+		if _, ok := checkpointHashes[checkpoint.EventID]; ok {
+			report.Broken = &AuditChainBreak{EventID: checkpoint.EventID, Reason: "checkpointed event is missing"}
+			return report, nil
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestAuditTx(t *testing.T) {
+	account := createRandomAccount(t)
+	ctx := context.Background()
+	actor := pkg.RandomString(8)
+	resourceID := strconv.FormatInt(account.ID, 10)
+
+	// 变更与审计事件在同一个事务中提交, 嵌套的事务方法加入同一个事务
+	event := &AuditEvent{
+		Actor:        actor,
+		Action:       "admin.balance_adjust",
+		ResourceType: "account",
+		ResourceID:   resourceID,
+		ClientIP:     "10.0.0.1",
+		RequestID:    pkg.RandomString(16),
+		Before:       account,
+	}
+	err := sqlStore.AuditTx(ctx, event, func(ctx context.Context, q Querier) error {
+		result, err := sqlStore.AdjustBalanceTx(ctx, AdjustBalanceTxParams{
+			AccountID: account.ID,
+			Amount:    10,
+			Reason:    "audit test",
+			CreatedBy: actor,
+		})
+		event.After = result
+		return err
+	})
+	require.NoError(t, err)
+
+	events, err := sqlStore.ListAuditEvents(ctx, ListAuditEventsParams{Actor: &actor, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, event.Action, events[0].Action)
+	require.Equal(t, resourceID, events[0].ResourceID)
+	require.Equal(t, event.RequestID, events[0].RequestID)
+	require.NotEmpty(t, events[0].Before)
+	require.NotEmpty(t, events[0].After)
+	// 事件已加入散列链
+	require.Len(t, events[0].Hash, 32)
+	require.Equal(t, AuditEventHash(events[0]), events[0].Hash)
+
+	// 变更失败时回滚, 不记录审计事件
+	errAbort := errors.New("abort")
+	err = sqlStore.AuditTx(ctx, &AuditEvent{Actor: actor, Action: "account.create"}, func(ctx context.Context, q Querier) error {
+		if _, err := q.UpdateAccount(ctx, UpdateAccountParams{ID: account.ID, Balance: 0}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	events, err = sqlStore.ListAuditEvents(ctx, ListAuditEventsParams{Actor: &actor, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	got, err := sqlStore.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, got.Balance)
+}
+
+// chainQuerier 只实现校验散列链用到的查询
+type chainQuerier struct {
+	Querier
+	events      []AuditEvents
+	checkpoints []AuditCheckpoints
+}
+
+func (q *chainQuerier) ListAuditChain(_ context.Context, arg ListAuditChainParams) ([]AuditEvents, error) {
+	var events []AuditEvents
+	for _, event := range q.events {
+		if event.ID > arg.AfterID && int64(len(events)) < arg.Limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (q *chainQuerier) ListAuditCheckpoints(context.Context) ([]AuditCheckpoints, error) {
+	return q.checkpoints, nil
+}
+
+// newAuditChain 生成n条链接好的事件, 前unchained条为加入散列链之前的事件
+func newAuditChain(n int, unchained int) []AuditEvents {
+	events := make([]AuditEvents, n)
+	var prev []byte
+	for i := range events {
+		events[i] = AuditEvents{
+			ID:        int64(i + 1),
+			Actor:     pkg.RandomString(8),
+			Action:    "transfer.create",
+			After:     []byte(`{"amount": 10}`),
+			CreatedAt: time.Now().Add(time.Duration(i) * time.Second),
+		}
+		if i < unchained {
+			continue
+		}
+		events[i].PrevHash = prev
+		events[i].Hash = AuditEventHash(events[i])
+		prev = events[i].Hash
+	}
+	return events
+}
+
+// rehashAuditChain 从第from条开始重新计算散列值, 模拟篡改之后重新计算整条链
+func rehashAuditChain(events []AuditEvents, from int) {
+	for i := from; i < len(events); i++ {
+		if i > 0 {
+			events[i].PrevHash = events[i-1].Hash
+		}
+		events[i].Hash = AuditEventHash(events[i])
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	testCases := []struct {
+		name   string
+		tamper func(q *chainQuerier)
+		check  func(t *testing.T, report AuditChainReport)
+	}{
+		{
+			name:   "OK",
+			tamper: func(q *chainQuerier) {},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Nil(t, report.Broken)
+				require.Equal(t, int64(5), report.Events)
+				require.Equal(t, int64(1), report.Unchained)
+				require.Equal(t, 1, report.Checkpoints)
+				require.Equal(t, int64(6), report.HeadID)
+			},
+		},
+		{
+			name: "修改事件",
+			tamper: func(q *chainQuerier) {
+				q.events[3].After = []byte(`{"amount": 1000}`)
+			},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Equal(t, &AuditChainBreak{EventID: 4, Reason: "hash does not match the event content"}, report.Broken)
+			},
+		},
+		{
+			name: "删除事件",
+			tamper: func(q *chainQuerier) {
+				q.events = append(q.events[:2], q.events[3:]...)
+			},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Equal(t, int64(4), report.Broken.EventID)
+				require.Equal(t, "prev_hash does not match the previous event", report.Broken.Reason)
+			},
+		},
+		{
+			name: "删除散列值",
+			tamper: func(q *chainQuerier) {
+				q.events[4].Hash = nil
+			},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Equal(t, &AuditChainBreak{EventID: 5, Reason: "hash is missing"}, report.Broken)
+			},
+		},
+		{
+			name: "修改之后重新计算散列链",
+			tamper: func(q *chainQuerier) {
+				q.events[2].Actor = "mallory"
+				rehashAuditChain(q.events, 2)
+			},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Equal(t, &AuditChainBreak{EventID: 4, Reason: "hash does not match the checkpoint"}, report.Broken)
+			},
+		},
+		{
+			name: "删除链尾",
+			tamper: func(q *chainQuerier) {
+				q.events = q.events[:3]
+			},
+			check: func(t *testing.T, report AuditChainReport) {
+				require.Equal(t, &AuditChainBreak{EventID: 4, Reason: "checkpointed event is missing"}, report.Broken)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			events := newAuditChain(6, 1)
+			q := &chainQuerier{
+				events:      events,
+				checkpoints: []AuditCheckpoints{{ID: 1, EventID: 4, Hash: events[3].Hash}},
+			}
+			tc.tamper(q)
+
+			// 每批2条, 覆盖分批读取
+			report, err := VerifyAuditChain(context.Background(), q, 2)
+			require.NoError(t, err)
+			tc.check(t, report)
+		})
+	}
+}
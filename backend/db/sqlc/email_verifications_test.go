@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestCreateUserTxAndVerifyEmailTx(t *testing.T) {
+	sqlStore = newDB(t)
+
+	hashedToken := pkg.HashSecret(pkg.RandomString(32))
+	user, err := sqlStore.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       pkg.RandomString(6),
+			FullName:       pkg.RandomString(6),
+			HashedPassword: pkg.RandomString(10),
+			Email:          pkg.RandomEmail(6),
+		},
+		HashedVerificationToken: hashedToken,
+		VerificationExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.False(t, user.IsEmailVerified)
+
+	verified, err := sqlStore.VerifyEmailTx(context.Background(), hashedToken)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, verified.Username)
+	require.True(t, verified.IsEmailVerified)
+
+	// 令牌只能使用一次
+	_, err = sqlStore.VerifyEmailTx(context.Background(), hashedToken)
+	require.ErrorIs(t, err, ErrEmailVerificationInvalid)
+
+	// 不存在的令牌不能使用
+	_, err = sqlStore.VerifyEmailTx(context.Background(), pkg.HashSecret(pkg.RandomString(32)))
+	require.ErrorIs(t, err, ErrEmailVerificationInvalid)
+}
+
+func TestRequestEmailChangeTx(t *testing.T) {
+	sqlStore = newDB(t)
+
+	registerToken := pkg.HashSecret(pkg.RandomString(32))
+	user, err := sqlStore.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       pkg.RandomString(6),
+			FullName:       pkg.RandomString(6),
+			HashedPassword: pkg.RandomString(10),
+			Email:          pkg.RandomEmail(6),
+		},
+		HashedVerificationToken: registerToken,
+		VerificationExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	requestChange := func(email string) string {
+		hashedToken := pkg.HashSecret(pkg.RandomString(32))
+		verification, err := sqlStore.RequestEmailChangeTx(context.Background(), CreateEmailChangeParams{
+			HashedToken: hashedToken,
+			Username:    user.Username,
+			Email:       email,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		require.True(t, verification.IsEmailChange)
+		return hashedToken
+	}
+
+	// 发往旧邮箱的密码重置令牌
+	resetToken := pkg.HashSecret(pkg.RandomString(32))
+	_, err = sqlStore.CreatePasswordResetToken(context.Background(), CreatePasswordResetTokenParams{
+		HashedToken: resetToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	firstToken := requestChange(pkg.RandomEmail(6))
+	newEmail := pkg.RandomEmail(6)
+	secondToken := requestChange(newEmail)
+
+	// 之前的令牌全部失效, 包括注册时的令牌
+	_, err = sqlStore.VerifyEmailTx(context.Background(), firstToken)
+	require.ErrorIs(t, err, ErrEmailVerificationInvalid)
+	_, err = sqlStore.VerifyEmailTx(context.Background(), registerToken)
+	require.ErrorIs(t, err, ErrEmailVerificationInvalid)
+
+	changed, err := sqlStore.VerifyEmailTx(context.Background(), secondToken)
+	require.NoError(t, err)
+	require.Equal(t, newEmail, changed.Email)
+	require.True(t, changed.IsEmailVerified)
+	require.True(t, changed.UpdatedAt.After(user.UpdatedAt))
+
+	// 修改邮箱之后旧邮箱收到的重置令牌失效
+	_, err = sqlStore.GetPasswordResetToken(context.Background(), resetToken)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// 验证之前新邮箱被其他用户注册
+	other := createRandomUser(t)
+	takenToken := requestChange(other.Email)
+	_, err = sqlStore.VerifyEmailTx(context.Background(), takenToken)
+	require.ErrorIs(t, err, ErrEmailTaken)
+}
@@ -107,3 +107,43 @@ func (q *Queries) ListEntry(ctx context.Context, arg ListEntryParams) ([]Entries
 	}
 	return items, nil
 }
+
+const ListOwnerEntries = `-- name: ListOwnerEntries :many
+SELECT entries.id, entries.account_id, entries.amount, entries.created_at
+FROM entries
+         JOIN accounts ON accounts.id = entries.account_id
+WHERE accounts.owner = $1
+ORDER BY entries.id
+`
+
+// ListOwnerEntries
+//
+//	SELECT entries.id, entries.account_id, entries.amount, entries.created_at
+//	FROM entries
+//	         JOIN accounts ON accounts.id = entries.account_id
+//	WHERE accounts.owner = $1
+//	ORDER BY entries.id
+func (q *Queries) ListOwnerEntries(ctx context.Context, owner string) ([]Entries, error) {
+	rows, err := q.db.Query(ctx, ListOwnerEntries, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entries{}
+	for rows.Next() {
+		var i Entries
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
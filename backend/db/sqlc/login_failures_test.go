@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestReserveLoginAttempt(t *testing.T) {
+	sqlStore = newDB(t)
+	ctx := context.Background()
+	subject := "user:" + pkg.RandomString(8)
+	windowStart := time.Now().Add(-time.Minute)
+
+	// 以读取到的记录作为条件, 没有记录时为零值
+	var last LoginFailures
+	for i := 1; i <= 3; i++ {
+		failure, err := sqlStore.ReserveLoginAttempt(ctx, ReserveLoginAttemptParams{
+			Subject:      subject,
+			WindowStart:  windowStart,
+			FailedCount:  last.FailedCount,
+			LastFailedAt: last.LastFailedAt,
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(i), failure.FailedCount)
+		require.Nil(t, failure.LockedUntil)
+		last = failure
+	}
+
+	// 读取之后记录已被修改, 不增加次数
+	_, err := sqlStore.ReserveLoginAttempt(ctx, ReserveLoginAttemptParams{
+		Subject:      subject,
+		WindowStart:  windowStart,
+		FailedCount:  last.FailedCount - 1,
+		LastFailedAt: last.LastFailedAt,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	lockedUntil := time.Now().Add(time.Minute)
+	err = sqlStore.LockLogin(ctx, LockLoginParams{Subject: subject, LockedUntil: &lockedUntil})
+	require.NoError(t, err)
+	failure, err := sqlStore.GetLoginFailure(ctx, subject)
+	require.NoError(t, err)
+	require.WithinDuration(t, lockedUntil, *failure.LockedUntil, time.Second)
+
+	// 撤销一次失败, 次数低于上限时解除锁定
+	err = sqlStore.RefundLoginAttempt(ctx, RefundLoginAttemptParams{MaxFailures: 3, Subject: subject})
+	require.NoError(t, err)
+	failure, err = sqlStore.GetLoginFailure(ctx, subject)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), failure.FailedCount)
+	require.Nil(t, failure.LockedUntil)
+
+	// 上一次失败早于统计窗口时重新计数
+	failure, err = sqlStore.ReserveLoginAttempt(ctx, ReserveLoginAttemptParams{
+		Subject:      subject,
+		WindowStart:  time.Now().Add(time.Minute),
+		FailedCount:  failure.FailedCount,
+		LastFailedAt: failure.LastFailedAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), failure.FailedCount)
+
+	require.NoError(t, sqlStore.ResetLoginFailures(ctx, subject))
+	_, err = sqlStore.GetLoginFailure(ctx, subject)
+	require.Error(t, err)
+}
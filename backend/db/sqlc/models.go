@@ -6,16 +6,75 @@ package db
 
 import (
 	"time"
+
+	"github.com/google/uuid"
 )
 
+type AccountAdjustments struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"accountID"`
+	EntryID   int64     `json:"entryID"`
+	Amount    int64     `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type Accounts struct {
+	ID        int64      `json:"id"`
+	Owner     string     `json:"owner"`
+	Balance   int64      `json:"balance"`
+	Currency  string     `json:"currency"`
+	CreatedAt time.Time  `json:"createdAt"`
+	IsFrozen  bool       `json:"isFrozen"`
+	ClosedAt  *time.Time `json:"closedAt"`
+}
+
+type ApiKeys struct {
+	ID          uuid.UUID  `json:"id"`
+	Username    string     `json:"username"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	HashedKey   string     `json:"hashedKey"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type AuditCheckpoints struct {
 	ID        int64     `json:"id"`
-	Owner     string    `json:"owner"`
-	Balance   int64     `json:"balance"`
-	Currency  string    `json:"currency"`
+	EventID   int64     `json:"eventID"`
+	Hash      []byte    `json:"hash"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type AuditEvents struct {
+	ID           int64     `json:"id"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceID"`
+	ClientIp     string    `json:"clientIp"`
+	UserAgent    string    `json:"userAgent"`
+	RequestID    string    `json:"requestID"`
+	Before       []byte    `json:"before"`
+	After        []byte    `json:"after"`
+	CreatedAt    time.Time `json:"createdAt"`
+	PrevHash     []byte    `json:"prevHash"`
+	Hash         []byte    `json:"hash"`
+}
+
+type EmailVerifications struct {
+	HashedToken   string     `json:"hashedToken"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	UsedAt        *time.Time `json:"usedAt"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	IsEmailChange bool       `json:"isEmailChange"`
+}
+
 type Entries struct {
 	ID        int64     `json:"id"`
 	AccountID int64     `json:"accountID"`
@@ -23,6 +82,97 @@ type Entries struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type LoginFailures struct {
+	Subject      string     `json:"subject"`
+	FailedCount  int32      `json:"failedCount"`
+	LastFailedAt time.Time  `json:"lastFailedAt"`
+	LockedUntil  *time.Time `json:"lockedUntil"`
+}
+
+type MfaChallenges struct {
+	HashedToken string     `json:"hashedToken"`
+	Username    string     `json:"username"`
+	Attempts    int32      `json:"attempts"`
+	UsedAt      *time.Time `json:"usedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type OauthAuthorizationCodes struct {
+	HashedCode    string     `json:"hashedCode"`
+	ClientID      string     `json:"clientID"`
+	Username      string     `json:"username"`
+	RedirectUri   string     `json:"redirectUri"`
+	Scopes        []string   `json:"scopes"`
+	CodeChallenge string     `json:"codeChallenge"`
+	ExpiresAt     time.Time  `json:"expiresAt"`
+	UsedAt        *time.Time `json:"usedAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+type OauthClients struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	HashedSecret *string   `json:"hashedSecret"`
+	RedirectUris []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	Owner        string    `json:"owner"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type OauthConsents struct {
+	Username  string    `json:"username"`
+	ClientID  string    `json:"clientID"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type OauthRefreshTokens struct {
+	HashedToken string    `json:"hashedToken"`
+	ClientID    string    `json:"clientID"`
+	Username    string    `json:"username"`
+	Scopes      []string  `json:"scopes"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type PasswordResetTokens struct {
+	HashedToken string     `json:"hashedToken"`
+	Username    string     `json:"username"`
+	UsedAt      *time.Time `json:"usedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+type RecoveryCodes struct {
+	ID         int64      `json:"id"`
+	Username   string     `json:"username"`
+	HashedCode string     `json:"hashedCode"`
+	UsedAt     *time.Time `json:"usedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type RevokedTokens struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Sessions struct {
+	ID                 uuid.UUID  `json:"id"`
+	FamilyID           uuid.UUID  `json:"familyID"`
+	Username           string     `json:"username"`
+	HashedRefreshToken string     `json:"hashedRefreshToken"`
+	UserAgent          string     `json:"userAgent"`
+	ClientIp           string     `json:"clientIp"`
+	IsBlocked          bool       `json:"isBlocked"`
+	RotatedAt          *time.Time `json:"rotatedAt"`
+	ExpiresAt          time.Time  `json:"expiresAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
 type Transfers struct {
 	ID            int64     `json:"id"`
 	FromAccountID int64     `json:"fromAccountID"`
@@ -31,12 +181,28 @@ type Transfers struct {
 	CreatedAt     time.Time `json:"createdAt"`
 }
 
+type UserTokenRevocations struct {
+	Username      string    `json:"username"`
+	RevokedBefore time.Time `json:"revokedBefore"`
+}
+
+type UserTotp struct {
+	Username     string     `json:"username"`
+	Secret       string     `json:"secret"`
+	LastUsedStep int64      `json:"lastUsedStep"`
+	ConfirmedAt  *time.Time `json:"confirmedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
 type Users struct {
-	Username          string    `json:"username"`
-	FullName          string    `json:"fullName"`
-	HashedPassword    string    `json:"hashedPassword"`
-	Email             string    `json:"email"`
-	PasswordChangedAt time.Time `json:"passwordChangedAt"`
-	CreatedAt         time.Time `json:"createdAt"`
-	UpdatedAt         time.Time `json:"updatedAt"`
+	Username          string     `json:"username"`
+	FullName          string     `json:"fullName"`
+	HashedPassword    string     `json:"hashedPassword"`
+	Email             string     `json:"email"`
+	PasswordChangedAt time.Time  `json:"passwordChangedAt"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	UpdatedAt         time.Time  `json:"updatedAt"`
+	IsEmailVerified   bool       `json:"isEmailVerified"`
+	Role              string     `json:"role"`
+	DeletedAt         *time.Time `json:"deletedAt"`
 }
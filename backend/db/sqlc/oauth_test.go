@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func createRandomOAuthClient(t *testing.T) OauthClients {
+	sqlStore = newDB(t)
+	owner := createRandomUser(t)
+
+	client, err := sqlStore.CreateOAuthClient(context.Background(), CreateOAuthClientParams{
+		ID:           pkg.RandomString(16),
+		Name:         "partner",
+		RedirectUris: []string{"https://partner.example.com/callback"},
+		Scopes:       []string{"accounts:read", "transfers:write"},
+		Owner:        owner.Username,
+	})
+	require.NoError(t, err)
+	require.Nil(t, client.HashedSecret)
+	return client
+}
+
+func TestConsumeOAuthAuthorizationCode(t *testing.T) {
+	client := createRandomOAuthClient(t)
+	user := createRandomUser(t)
+	hashedCode := pkg.HashSecret(pkg.RandomString(32))
+
+	err := sqlStore.CreateOAuthAuthorizationCode(context.Background(), CreateOAuthAuthorizationCodeParams{
+		HashedCode:    hashedCode,
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        []string{"accounts:read"},
+		CodeChallenge: pkg.RandomString(43),
+		ExpiresAt:     time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	code, err := sqlStore.ConsumeOAuthAuthorizationCode(context.Background(), hashedCode)
+	require.NoError(t, err)
+	require.NotNil(t, code.UsedAt)
+
+	// 授权码只能使用一次
+	_, err = sqlStore.ConsumeOAuthAuthorizationCode(context.Background(), hashedCode)
+	require.Error(t, err)
+}
+
+func TestUpsertOAuthConsent(t *testing.T) {
+	client := createRandomOAuthClient(t)
+	user := createRandomUser(t)
+
+	_, err := sqlStore.UpsertOAuthConsent(context.Background(), UpsertOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+		Scopes:   []string{"transfers:write"},
+	})
+	require.NoError(t, err)
+
+	// 再次授权时合并授权范围
+	consent, err := sqlStore.UpsertOAuthConsent(context.Background(), UpsertOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+		Scopes:   []string{"accounts:read", "transfers:write"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"accounts:read", "transfers:write"}, consent.Scopes)
+}
+
+func TestRotateOAuthRefreshTokenTx(t *testing.T) {
+	client := createRandomOAuthClient(t)
+	user := createRandomUser(t)
+	hashedToken := pkg.HashSecret(pkg.RandomString(32))
+
+	_, err := sqlStore.CreateOAuthRefreshToken(context.Background(), CreateOAuthRefreshTokenParams{
+		HashedToken: hashedToken,
+		ClientID:    client.ID,
+		Username:    user.Username,
+		Scopes:      []string{"accounts:read"},
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	// 其它应用不能使用该刷新令牌, 失败时旧令牌不受影响
+	_, err = sqlStore.RotateOAuthRefreshTokenTx(context.Background(), RotateOAuthRefreshTokenTxParams{
+		HashedToken:    hashedToken,
+		ClientID:       "other",
+		NewHashedToken: pkg.HashSecret(pkg.RandomString(32)),
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.True(t, errors.Is(err, ErrOAuthRefreshTokenInvalid))
+
+	newHashedToken := pkg.HashSecret(pkg.RandomString(32))
+	refreshToken, err := sqlStore.RotateOAuthRefreshTokenTx(context.Background(), RotateOAuthRefreshTokenTxParams{
+		HashedToken:    hashedToken,
+		ClientID:       client.ID,
+		NewHashedToken: newHashedToken,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, newHashedToken, refreshToken.HashedToken)
+	require.Equal(t, []string{"accounts:read"}, refreshToken.Scopes)
+
+	// 旧令牌已被轮换
+	_, err = sqlStore.RotateOAuthRefreshTokenTx(context.Background(), RotateOAuthRefreshTokenTxParams{
+		HashedToken:    hashedToken,
+		ClientID:       client.ID,
+		NewHashedToken: pkg.HashSecret(pkg.RandomString(32)),
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.True(t, errors.Is(err, ErrOAuthRefreshTokenInvalid))
+
+	// 撤销授权之后刷新令牌一并删除
+	_, err = sqlStore.UpsertOAuthConsent(context.Background(), UpsertOAuthConsentParams{
+		Username: user.Username,
+		ClientID: client.ID,
+		Scopes:   []string{"accounts:read"},
+	})
+	require.NoError(t, err)
+	_, err = sqlStore.RevokeOAuthConsentTx(context.Background(), DeleteOAuthConsentParams{Username: user.Username, ClientID: client.ID})
+	require.NoError(t, err)
+	_, err = sqlStore.ConsumeOAuthRefreshToken(context.Background(), newHashedToken)
+	require.Error(t, err)
+}
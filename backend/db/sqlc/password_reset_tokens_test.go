@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestResetPasswordTx(t *testing.T) {
+	sqlStore = newDB(t)
+	user := createRandomUser(t)
+
+	hashedToken := pkg.HashSecret(pkg.RandomString(32))
+	_, err := sqlStore.CreatePasswordResetToken(context.Background(), CreatePasswordResetTokenParams{
+		HashedToken: hashedToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		HashedToken:    hashedToken,
+		HashedPassword: pkg.RandomString(10),
+	}
+	updated, err := sqlStore.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedPassword, updated.HashedPassword)
+	require.True(t, updated.PasswordChangedAt.After(user.PasswordChangedAt))
+
+	// 令牌只能使用一次
+	_, err = sqlStore.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrPasswordResetTokenInvalid)
+
+	// 过期的令牌不能使用
+	expiredToken := pkg.HashSecret(pkg.RandomString(32))
+	_, err = sqlStore.CreatePasswordResetToken(context.Background(), CreatePasswordResetTokenParams{
+		HashedToken: expiredToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	_, err = sqlStore.ResetPasswordTx(context.Background(), ResetPasswordTxParams{HashedToken: expiredToken})
+	require.ErrorIs(t, err, ErrPasswordResetTokenInvalid)
+}
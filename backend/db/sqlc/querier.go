@@ -6,6 +6,9 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
@@ -14,20 +17,210 @@ type Querier interface {
 	//  UPDATE accounts
 	//  SET balance = balance + $1
 	//  WHERE id = $2
-	//  RETURNING id, owner, balance, currency, created_at
+	//  RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 	AddAccountBalancer(ctx context.Context, arg AddAccountBalancerParams) (Accounts, error)
+	//BlockSessionFamily
+	//
+	//  UPDATE sessions
+	//  SET is_blocked = true
+	//  WHERE family_id = $1
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	//BlockUserSessions
+	//
+	//  UPDATE sessions
+	//  SET is_blocked = true
+	//  WHERE username = $1
+	BlockUserSessions(ctx context.Context, username string) error
+	//ChangeUserEmail
+	//
+	//  UPDATE users
+	//  SET email             = $2,
+	//      is_email_verified = true
+	//  WHERE username = $1
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (Users, error)
+	//CloseOwnerAccounts
+	//
+	//  UPDATE accounts
+	//  SET is_frozen = true,
+	//      closed_at = now()
+	//  WHERE owner = $1
+	//    AND closed_at IS NULL
+	//  RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+	CloseOwnerAccounts(ctx context.Context, owner string) ([]Accounts, error)
+	//CompleteMfaChallenge
+	//
+	//  UPDATE mfa_challenges
+	//  SET used_at = now()
+	//  WHERE hashed_token = $1
+	//    AND used_at IS NULL
+	//  RETURNING hashed_token, username, attempts, used_at, expires_at, created_at
+	CompleteMfaChallenge(ctx context.Context, hashedToken string) (MfaChallenges, error)
+	//ConfirmUserTotp
+	//
+	//  UPDATE user_totp
+	//  SET confirmed_at   = now(),
+	//      last_used_step = $2
+	//  WHERE username = $1
+	//    AND confirmed_at IS NULL
+	//  RETURNING username, secret, last_used_step, confirmed_at, created_at
+	ConfirmUserTotp(ctx context.Context, arg ConfirmUserTotpParams) (UserTotp, error)
+	//ConsumeOAuthAuthorizationCode
+	//
+	//  UPDATE oauth_authorization_codes
+	//  SET used_at = now()
+	//  WHERE hashed_code = $1
+	//    AND used_at IS NULL
+	//    AND expires_at > now()
+	//  RETURNING hashed_code, client_id, username, redirect_uri, scopes, code_challenge, expires_at, used_at, created_at
+	ConsumeOAuthAuthorizationCode(ctx context.Context, hashedCode string) (OauthAuthorizationCodes, error)
+	//ConsumeOAuthRefreshToken
+	//
+	//  DELETE
+	//  FROM oauth_refresh_tokens
+	//  WHERE hashed_token = $1
+	//  RETURNING hashed_token, client_id, username, scopes, expires_at, created_at
+	ConsumeOAuthRefreshToken(ctx context.Context, hashedToken string) (OauthRefreshTokens, error)
+	//CreateAPIKey
+	//
+	//  INSERT INTO api_keys (id,
+	//                        username,
+	//                        name,
+	//                        prefix,
+	//                        hashed_key,
+	//                        permissions,
+	//                        expires_at)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7)
+	//  RETURNING id, username, name, prefix, hashed_key, permissions, expires_at, last_used_at, created_at
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKeys, error)
 	//CreateAccount
 	//
 	//  INSERT INTO accounts(owner, balance, currency)
 	//  VALUES ($1, $2, $3)
-	//  RETURNING id, owner, balance, currency, created_at
+	//  RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Accounts, error)
+	//CreateAccountAdjustment
+	//
+	//  INSERT INTO account_adjustments (account_id,
+	//                                   entry_id,
+	//                                   amount,
+	//                                   reason,
+	//                                   created_by)
+	//  VALUES ($1, $2, $3, $4, $5)
+	//  RETURNING id, account_id, entry_id, amount, reason, created_by, created_at
+	CreateAccountAdjustment(ctx context.Context, arg CreateAccountAdjustmentParams) (AccountAdjustments, error)
+	//CreateAuditCheckpoint
+	//
+	//  INSERT INTO audit_checkpoints (event_id, hash)
+	//  SELECT id, hash
+	//  FROM audit_events
+	//  WHERE hash IS NOT NULL
+	//    AND id > COALESCE((SELECT max(event_id) FROM audit_checkpoints), 0)
+	//  ORDER BY id DESC
+	//  LIMIT 1
+	//  RETURNING id, event_id, hash, created_at
+	CreateAuditCheckpoint(ctx context.Context) (AuditCheckpoints, error)
+	//CreateAuditEvent
+	//
+	//  INSERT INTO audit_events (actor,
+	//                            action,
+	//                            resource_type,
+	//                            resource_id,
+	//                            client_ip,
+	//                            user_agent,
+	//                            request_id,
+	//                            before,
+	//                            after,
+	//                            prev_hash)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	//  RETURNING id, actor, action, resource_type, resource_id, client_ip, user_agent, request_id, before, after, created_at, prev_hash, hash
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvents, error)
+	//CreateEmailChange
+	//
+	//  INSERT INTO email_verifications (hashed_token, username, email, expires_at, is_email_change)
+	//  VALUES ($1, $2, $3, $4, true)
+	//  RETURNING hashed_token, username, email, used_at, expires_at, created_at, is_email_change
+	CreateEmailChange(ctx context.Context, arg CreateEmailChangeParams) (EmailVerifications, error)
+	//CreateEmailVerification
+	//
+	//  INSERT INTO email_verifications (hashed_token, username, email, expires_at)
+	//  VALUES ($1, $2, $3, $4)
+	//  RETURNING hashed_token, username, email, used_at, expires_at, created_at, is_email_change
+	CreateEmailVerification(ctx context.Context, arg CreateEmailVerificationParams) (EmailVerifications, error)
 	//CreateEntry
 	//
 	//  INSERT INTO entries(account_id, amount)
 	//  VALUES ($1, $2)
 	//  RETURNING id, account_id, amount, created_at
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entries, error)
+	//CreateMfaChallenge
+	//
+	//  INSERT INTO mfa_challenges (hashed_token, username, expires_at)
+	//  VALUES ($1, $2, $3)
+	//  RETURNING hashed_token, username, attempts, used_at, expires_at, created_at
+	CreateMfaChallenge(ctx context.Context, arg CreateMfaChallengeParams) (MfaChallenges, error)
+	//CreateOAuthAuthorizationCode
+	//
+	//  INSERT INTO oauth_authorization_codes (hashed_code,
+	//                                         client_id,
+	//                                         username,
+	//                                         redirect_uri,
+	//                                         scopes,
+	//                                         code_challenge,
+	//                                         expires_at)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7)
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error
+	//CreateOAuthClient
+	//
+	//  INSERT INTO oauth_clients (id,
+	//                             name,
+	//                             hashed_secret,
+	//                             redirect_uris,
+	//                             scopes,
+	//                             owner)
+	//  VALUES ($1, $2, $3, $4, $5, $6)
+	//  RETURNING id, name, hashed_secret, redirect_uris, scopes, owner, created_at
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClients, error)
+	//CreateOAuthRefreshToken
+	//
+	//  INSERT INTO oauth_refresh_tokens (hashed_token,
+	//                                    client_id,
+	//                                    username,
+	//                                    scopes,
+	//                                    expires_at)
+	//  VALUES ($1, $2, $3, $4, $5)
+	//  RETURNING hashed_token, client_id, username, scopes, expires_at, created_at
+	CreateOAuthRefreshToken(ctx context.Context, arg CreateOAuthRefreshTokenParams) (OauthRefreshTokens, error)
+	//CreatePasswordResetToken
+	//
+	//  INSERT INTO password_reset_tokens (hashed_token, username, expires_at)
+	//  VALUES ($1, $2, $3)
+	//  RETURNING hashed_token, username, used_at, expires_at, created_at
+	CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetTokens, error)
+	//CreateRecoveryCode
+	//
+	//  INSERT INTO recovery_codes (username, hashed_code)
+	//  VALUES ($1, $2)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	//CreateRevokedToken
+	//
+	//  INSERT INTO revoked_tokens (id, username, expires_at)
+	//  VALUES ($1, $2, $3)
+	//  ON CONFLICT (id) DO NOTHING
+	CreateRevokedToken(ctx context.Context, arg CreateRevokedTokenParams) error
+	//CreateSession
+	//
+	//  INSERT INTO sessions (id,
+	//                        family_id,
+	//                        username,
+	//                        hashed_refresh_token,
+	//                        user_agent,
+	//                        client_ip,
+	//                        is_blocked,
+	//                        expires_at)
+	//  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	//  RETURNING id, family_id, username, hashed_refresh_token, user_agent, client_ip, is_blocked, rotated_at, expires_at, created_at
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Sessions, error)
 	//CreateTransfer
 	//
 	//  INSERT INTO transfers(from_account_id, to_account_id, amount)
@@ -41,28 +234,147 @@ type Querier interface {
 	//                     hashed_password,
 	//                     email)
 	//  VALUES ($1, $2, $3, $4)
-	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 	CreateUser(ctx context.Context, arg CreateUserParams) (Users, error)
+	//DeleteAPIKey
+	//
+	//  DELETE
+	//  FROM api_keys
+	//  WHERE id = $1
+	//    AND username = $2
+	//  RETURNING id, username, name, prefix, hashed_key, permissions, expires_at, last_used_at, created_at
+	DeleteAPIKey(ctx context.Context, arg DeleteAPIKeyParams) (ApiKeys, error)
 	//DeleteAccount
 	//
 	//  DELETE
 	//  FROM accounts
 	//  WHERE id = $1
 	DeleteAccount(ctx context.Context, id int64) error
+	//DeleteExpiredEmailVerifications
+	//
+	//  DELETE
+	//  FROM email_verifications
+	//  WHERE expires_at < now()
+	DeleteExpiredEmailVerifications(ctx context.Context) error
+	//DeleteExpiredMfaChallenges
+	//
+	//  DELETE
+	//  FROM mfa_challenges
+	//  WHERE expires_at < now()
+	DeleteExpiredMfaChallenges(ctx context.Context) error
+	//DeleteExpiredOAuthAuthorizationCodes
+	//
+	//  DELETE
+	//  FROM oauth_authorization_codes
+	//  WHERE expires_at < now()
+	DeleteExpiredOAuthAuthorizationCodes(ctx context.Context) error
+	//DeleteExpiredOAuthRefreshTokens
+	//
+	//  DELETE
+	//  FROM oauth_refresh_tokens
+	//  WHERE expires_at < now()
+	DeleteExpiredOAuthRefreshTokens(ctx context.Context) error
+	//DeleteExpiredPasswordResetTokens
+	//
+	//  DELETE
+	//  FROM password_reset_tokens
+	//  WHERE expires_at < now()
+	DeleteExpiredPasswordResetTokens(ctx context.Context) error
+	//DeleteExpiredRevokedTokens
+	//
+	//  DELETE
+	//  FROM revoked_tokens
+	//  WHERE expires_at < now()
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	//DeleteOAuthClient
+	//
+	//  DELETE
+	//  FROM oauth_clients
+	//  WHERE id = $1
+	//    AND owner = $2
+	//  RETURNING id, name, hashed_secret, redirect_uris, scopes, owner, created_at
+	DeleteOAuthClient(ctx context.Context, arg DeleteOAuthClientParams) (OauthClients, error)
+	//DeleteOAuthConsent
+	//
+	//  DELETE
+	//  FROM oauth_consents
+	//  WHERE username = $1
+	//    AND client_id = $2
+	//  RETURNING username, client_id, scopes, created_at, updated_at
+	DeleteOAuthConsent(ctx context.Context, arg DeleteOAuthConsentParams) (OauthConsents, error)
+	//DeleteOAuthRefreshTokens
+	//
+	//  DELETE
+	//  FROM oauth_refresh_tokens
+	//  WHERE username = $1
+	//    AND client_id = $2
+	DeleteOAuthRefreshTokens(ctx context.Context, arg DeleteOAuthRefreshTokensParams) error
+	//DeleteRecoveryCodes
+	//
+	//  DELETE
+	//  FROM recovery_codes
+	//  WHERE username = $1
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	//DeleteStaleLoginFailures
+	//
+	//  DELETE
+	//  FROM login_failures
+	//  WHERE last_failed_at < $1
+	//    AND (locked_until IS NULL OR locked_until < now())
+	DeleteStaleLoginFailures(ctx context.Context, lastFailedAt time.Time) error
+	//DeleteUserAPIKeys
+	//
+	//  DELETE
+	//  FROM api_keys
+	//  WHERE username = $1
+	DeleteUserAPIKeys(ctx context.Context, username string) error
+	//DeleteUserOAuthConsents
+	//
+	//  DELETE
+	//  FROM oauth_consents
+	//  WHERE username = $1
+	DeleteUserOAuthConsents(ctx context.Context, username string) error
+	//DeleteUserOAuthRefreshTokens
+	//
+	//  DELETE
+	//  FROM oauth_refresh_tokens
+	//  WHERE username = $1
+	DeleteUserOAuthRefreshTokens(ctx context.Context, username string) error
+	//DeleteUserTotp
+	//
+	//  DELETE
+	//  FROM user_totp
+	//  WHERE username = $1
+	DeleteUserTotp(ctx context.Context, username string) error
+	//GetAPIKeyByHash
+	//
+	//  SELECT id, username, name, prefix, hashed_key, permissions, expires_at, last_used_at, created_at
+	//  FROM api_keys
+	//  WHERE hashed_key = $1
+	//  LIMIT 1
+	GetAPIKeyByHash(ctx context.Context, hashedKey string) (ApiKeys, error)
 	//GetAccount
 	//
-	//  SELECT id, owner, balance, currency, created_at
+	//  SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 	//  FROM accounts
 	//  WHERE id = $1
 	//  ORDER BY id
 	GetAccount(ctx context.Context, id int64) (Accounts, error)
 	//GetAccountForUpdate
 	//
-	//  SELECT id, owner, balance, currency, created_at
+	//  SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 	//  FROM accounts
 	//  WHERE id = $1
 	//      FOR NO KEY UPDATE
 	GetAccountForUpdate(ctx context.Context, id int64) (Accounts, error)
+	//GetAuditChainHead
+	//
+	//  SELECT id, actor, action, resource_type, resource_id, client_ip, user_agent, request_id, before, after, created_at, prev_hash, hash
+	//  FROM audit_events
+	//  WHERE hash IS NOT NULL
+	//  ORDER BY id DESC
+	//  LIMIT 1
+	GetAuditChainHead(ctx context.Context) (AuditEvents, error)
 	//GetEntry
 	//
 	//  SELECT id, account_id, amount, created_at
@@ -70,6 +382,51 @@ type Querier interface {
 	//  WHERE id = $1
 	//  LIMIT 1
 	GetEntry(ctx context.Context, id int64) (Entries, error)
+	//GetLoginFailure
+	//
+	//  SELECT subject, failed_count, last_failed_at, locked_until
+	//  FROM login_failures
+	//  WHERE subject = $1
+	//  LIMIT 1
+	GetLoginFailure(ctx context.Context, subject string) (LoginFailures, error)
+	//GetMfaChallenge
+	//
+	//  SELECT hashed_token, username, attempts, used_at, expires_at, created_at
+	//  FROM mfa_challenges
+	//  WHERE hashed_token = $1
+	//  LIMIT 1
+	GetMfaChallenge(ctx context.Context, hashedToken string) (MfaChallenges, error)
+	//GetOAuthClient
+	//
+	//  SELECT id, name, hashed_secret, redirect_uris, scopes, owner, created_at
+	//  FROM oauth_clients
+	//  WHERE id = $1
+	//  LIMIT 1
+	GetOAuthClient(ctx context.Context, id string) (OauthClients, error)
+	//GetOAuthConsent
+	//
+	//  SELECT username, client_id, scopes, created_at, updated_at
+	//  FROM oauth_consents
+	//  WHERE username = $1
+	//    AND client_id = $2
+	//  LIMIT 1
+	GetOAuthConsent(ctx context.Context, arg GetOAuthConsentParams) (OauthConsents, error)
+	//GetPasswordResetToken
+	//
+	//  SELECT hashed_token, username, used_at, expires_at, created_at
+	//  FROM password_reset_tokens
+	//  WHERE hashed_token = $1
+	//    AND used_at IS NULL
+	//    AND expires_at > now()
+	//  LIMIT 1
+	GetPasswordResetToken(ctx context.Context, hashedToken string) (PasswordResetTokens, error)
+	//GetSession
+	//
+	//  SELECT id, family_id, username, hashed_refresh_token, user_agent, client_ip, is_blocked, rotated_at, expires_at, created_at
+	//  FROM sessions
+	//  WHERE id = $1
+	//  LIMIT 1
+	GetSession(ctx context.Context, id uuid.UUID) (Sessions, error)
 	//GetTransfer
 	//
 	//  SELECT id, from_account_id, to_account_id, amount, created_at
@@ -79,19 +436,112 @@ type Querier interface {
 	GetTransfer(ctx context.Context, id int64) (Transfers, error)
 	//GetUser
 	//
-	//  SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+	//  SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 	//  FROM users
 	//  WHERE username = $1
 	//  LIMIT 1
 	GetUser(ctx context.Context, username string) (Users, error)
+	//GetUserByEmail
+	//
+	//  SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	//  FROM users
+	//  WHERE email = $1
+	//  LIMIT 1
+	GetUserByEmail(ctx context.Context, email string) (Users, error)
+	//GetUserTotp
+	//
+	//  SELECT username, secret, last_used_step, confirmed_at, created_at
+	//  FROM user_totp
+	//  WHERE username = $1
+	//  LIMIT 1
+	GetUserTotp(ctx context.Context, username string) (UserTotp, error)
+	//IncrementMfaChallengeAttempts
+	//
+	//  UPDATE mfa_challenges
+	//  SET attempts = attempts + 1
+	//  WHERE hashed_token = $1
+	//    AND attempts < $2
+	//    AND used_at IS NULL
+	//    AND expires_at > now()
+	//  RETURNING hashed_token, username, attempts, used_at, expires_at, created_at
+	IncrementMfaChallengeAttempts(ctx context.Context, arg IncrementMfaChallengeAttemptsParams) (MfaChallenges, error)
+	//InvalidateEmailVerifications
+	//
+	//  UPDATE email_verifications
+	//  SET used_at = now()
+	//  WHERE username = $1
+	//    AND used_at IS NULL
+	InvalidateEmailVerifications(ctx context.Context, username string) error
+	//InvalidateUserPasswordResetTokens
+	//
+	//  UPDATE password_reset_tokens
+	//  SET used_at = now()
+	//  WHERE username = $1
+	//    AND used_at IS NULL
+	InvalidateUserPasswordResetTokens(ctx context.Context, username string) error
+	//IsTokenRevoked
+	//
+	//  SELECT (EXISTS (SELECT 1 FROM revoked_tokens WHERE id = $1)
+	//      OR EXISTS (SELECT 1
+	//                 FROM user_token_revocations
+	//                 WHERE username = $2
+	//                   AND revoked_before > $3)
+	//      -- 修改密码之前颁发的令牌一律失效
+	//      OR EXISTS (SELECT 1
+	//                 FROM users
+	//                 WHERE username = $2
+	//                   AND password_changed_at > $3))::boolean AS revoked
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
+	//ListAPIKeys
+	//
+	//  SELECT id, username, name, prefix, hashed_key, permissions, expires_at, last_used_at, created_at
+	//  FROM api_keys
+	//  WHERE username = $1
+	//  ORDER BY created_at
+	ListAPIKeys(ctx context.Context, username string) ([]ApiKeys, error)
+	//ListAccountAdjustments
+	//
+	//  SELECT id, account_id, entry_id, amount, reason, created_by, created_at
+	//  FROM account_adjustments
+	//  WHERE account_id = $1
+	//  ORDER BY id DESC
+	//  LIMIT $2 OFFSET $3
+	ListAccountAdjustments(ctx context.Context, arg ListAccountAdjustmentsParams) ([]AccountAdjustments, error)
 	//ListAccounts
 	//
-	//  SELECT id, owner, balance, currency, created_at
+	//  SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
 	//  FROM accounts
 	//  WHERE owner = $1
 	//  ORDER BY id
 	//  LIMIT $2 OFFSET $3
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Accounts, error)
+	//ListAuditChain
+	//
+	//  SELECT id, actor, action, resource_type, resource_id, client_ip, user_agent, request_id, before, after, created_at, prev_hash, hash
+	//  FROM audit_events
+	//  WHERE id > $1
+	//  ORDER BY id
+	//  LIMIT $2
+	ListAuditChain(ctx context.Context, arg ListAuditChainParams) ([]AuditEvents, error)
+	//ListAuditCheckpoints
+	//
+	//  SELECT id, event_id, hash, created_at
+	//  FROM audit_checkpoints
+	//  ORDER BY event_id
+	ListAuditCheckpoints(ctx context.Context) ([]AuditCheckpoints, error)
+	//ListAuditEvents
+	//
+	//  SELECT id, actor, action, resource_type, resource_id, client_ip, user_agent, request_id, before, after, created_at, prev_hash, hash
+	//  FROM audit_events
+	//  WHERE ($1::varchar IS NULL OR actor = $1)
+	//    AND ($2::varchar IS NULL OR action = $2)
+	//    AND ($3::varchar IS NULL OR resource_type = $3)
+	//    AND ($4::varchar IS NULL OR resource_id = $4)
+	//    AND ($5::timestamptz IS NULL OR created_at >= $5)
+	//    AND ($6::timestamptz IS NULL OR created_at < $6)
+	//  ORDER BY id DESC
+	//  LIMIT $7 OFFSET $8
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvents, error)
 	//ListEntry
 	//
 	//  SELECT id, account_id, amount, created_at
@@ -100,6 +550,58 @@ type Querier interface {
 	//  ORDER BY id
 	//  LIMIT $2 OFFSET $3
 	ListEntry(ctx context.Context, arg ListEntryParams) ([]Entries, error)
+	//ListOAuthClients
+	//
+	//  SELECT id, name, hashed_secret, redirect_uris, scopes, owner, created_at
+	//  FROM oauth_clients
+	//  WHERE owner = $1
+	//  ORDER BY created_at
+	ListOAuthClients(ctx context.Context, owner string) ([]OauthClients, error)
+	//ListOAuthConsents
+	//
+	//  SELECT username, client_id, scopes, created_at, updated_at
+	//  FROM oauth_consents
+	//  WHERE username = $1
+	//  ORDER BY created_at
+	ListOAuthConsents(ctx context.Context, username string) ([]OauthConsents, error)
+	//ListOwnerAccounts
+	//
+	//  SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+	//  FROM accounts
+	//  WHERE owner = $1
+	//  ORDER BY id
+	ListOwnerAccounts(ctx context.Context, owner string) ([]Accounts, error)
+	//ListOwnerAccountsForUpdate
+	//
+	//  SELECT id, owner, balance, currency, created_at, is_frozen, closed_at
+	//  FROM accounts
+	//  WHERE owner = $1
+	//  ORDER BY id
+	//      FOR NO KEY UPDATE
+	ListOwnerAccountsForUpdate(ctx context.Context, owner string) ([]Accounts, error)
+	//ListOwnerEntries
+	//
+	//  SELECT entries.id, entries.account_id, entries.amount, entries.created_at
+	//  FROM entries
+	//           JOIN accounts ON accounts.id = entries.account_id
+	//  WHERE accounts.owner = $1
+	//  ORDER BY entries.id
+	ListOwnerEntries(ctx context.Context, owner string) ([]Entries, error)
+	//ListOwnerTransfers
+	//
+	//  SELECT id, from_account_id, to_account_id, amount, created_at
+	//  FROM transfers
+	//  WHERE from_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+	//     OR to_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+	//  ORDER BY id
+	ListOwnerTransfers(ctx context.Context, owner string) ([]Transfers, error)
+	//ListRecentTransfers
+	//
+	//  SELECT id, from_account_id, to_account_id, amount, created_at
+	//  FROM transfers
+	//  ORDER BY id DESC
+	//  LIMIT $1 OFFSET $2
+	ListRecentTransfers(ctx context.Context, arg ListRecentTransfersParams) ([]Transfers, error)
 	//ListTransfers
 	//
 	//  SELECT id, from_account_id, to_account_id, amount, created_at
@@ -109,13 +611,206 @@ type Querier interface {
 	//  ORDER BY id
 	//  LIMIT $3 OFFSET $4
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfers, error)
+	//LockAuditChain
+	//
+	//  SELECT pg_advisory_xact_lock(hashtext('audit_events'))
+	LockAuditChain(ctx context.Context) error
+	//LockLogin
+	//
+	//  UPDATE login_failures
+	//  SET locked_until = $2
+	//  WHERE subject = $1
+	LockLogin(ctx context.Context, arg LockLoginParams) error
+	//PseudonymizeUser
+	//
+	//  UPDATE users
+	//  SET full_name         = '',
+	//      email             = $1,
+	//      hashed_password   = '',
+	//      is_email_verified = false,
+	//      deleted_at        = now()
+	//  WHERE username = $2
+	//    AND deleted_at IS NULL
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	PseudonymizeUser(ctx context.Context, arg PseudonymizeUserParams) (Users, error)
+	//RefundLoginAttempt
+	//
+	//  UPDATE login_failures
+	//  SET failed_count = failed_count - 1,
+	//      locked_until = CASE
+	//                         WHEN failed_count - 1 < $1 THEN NULL
+	//                         ELSE locked_until
+	//          END
+	//  WHERE subject = $2
+	//    AND failed_count > 0
+	RefundLoginAttempt(ctx context.Context, arg RefundLoginAttemptParams) error
+	//RehashUserPassword
+	//
+	//  UPDATE users
+	//  SET hashed_password = $1
+	//  WHERE username = $2
+	//    AND hashed_password = $3
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error
+	//ReserveLoginAttempt
+	//
+	//  INSERT INTO login_failures (subject, failed_count, last_failed_at)
+	//  VALUES ($1, 1, now())
+	//  ON CONFLICT (subject) DO UPDATE
+	//      SET failed_count   = CASE
+	//                               WHEN login_failures.last_failed_at < $2 THEN 1
+	//                               ELSE login_failures.failed_count + 1
+	//          END,
+	//          last_failed_at = now()
+	//      WHERE login_failures.failed_count = $3
+	//        AND login_failures.last_failed_at = $4
+	//  RETURNING subject, failed_count, last_failed_at, locked_until
+	ReserveLoginAttempt(ctx context.Context, arg ReserveLoginAttemptParams) (LoginFailures, error)
+	//ResetLoginFailures
+	//
+	//  DELETE
+	//  FROM login_failures
+	//  WHERE subject = $1
+	ResetLoginFailures(ctx context.Context, subject string) error
+	//RevokeUserTokens
+	//
+	//  INSERT INTO user_token_revocations (username, revoked_before)
+	//  VALUES ($1, $2)
+	//  ON CONFLICT (username) DO UPDATE SET revoked_before = EXCLUDED.revoked_before
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	//RotateSession
+	//
+	//  UPDATE sessions
+	//  SET rotated_at = now()
+	//  WHERE id = $1
+	//    AND rotated_at IS NULL
+	//  RETURNING id, family_id, username, hashed_refresh_token, user_agent, client_ip, is_blocked, rotated_at, expires_at, created_at
+	RotateSession(ctx context.Context, id uuid.UUID) (Sessions, error)
+	//SearchUsers
+	//
+	//  SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	//  FROM users
+	//  WHERE username ILIKE '%' || $1 || '%'
+	//     OR full_name ILIKE '%' || $1 || '%'
+	//     OR email ILIKE '%' || $1 || '%'
+	//  ORDER BY username
+	//  LIMIT $2 OFFSET $3
+	SearchUsers(ctx context.Context, arg SearchUsersParams) ([]Users, error)
+	//SetAccountFrozen
+	//
+	//  UPDATE accounts
+	//  SET is_frozen = $2
+	//  WHERE id = $1
+	//  RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
+	SetAccountFrozen(ctx context.Context, arg SetAccountFrozenParams) (Accounts, error)
+	//SetAuditEventHash
+	//
+	//  UPDATE audit_events
+	//  SET hash = $1
+	//  WHERE id = $2
+	SetAuditEventHash(ctx context.Context, arg SetAuditEventHashParams) error
+	//TouchAPIKey
+	//
+	//  UPDATE api_keys
+	//  SET last_used_at = now()
+	//  WHERE id = $1
+	//    AND (last_used_at IS NULL OR last_used_at < $2::timestamptz)
+	TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error
 	//UpdateAccount
 	//
 	//  UPDATE accounts
 	//  SET balance = $2
 	//  WHERE id = $1
-	//  RETURNING id, owner, balance, currency, created_at
+	//  RETURNING id, owner, balance, currency, created_at, is_frozen, closed_at
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Accounts, error)
+	//UpdateUser
+	//
+	//  UPDATE users
+	//  SET full_name = COALESCE($1, full_name)
+	//  WHERE username = $2
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (Users, error)
+	//UpdateUserPassword
+	//
+	//  UPDATE users
+	//  SET hashed_password     = $2,
+	//      password_changed_at = now(),
+	//      updated_at          = now()
+	//  WHERE username = $1
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (Users, error)
+	//UpdateUserRole
+	//
+	//  UPDATE users
+	//  SET role       = $2,
+	//      updated_at = now()
+	//  WHERE username = $1
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (Users, error)
+	//UpsertOAuthConsent
+	//
+	//  INSERT INTO oauth_consents (username,
+	//                              client_id,
+	//                              scopes)
+	//  VALUES ($1, $2, $3)
+	//  ON CONFLICT (username, client_id) DO UPDATE
+	//      SET scopes     = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes) ORDER BY 1),
+	//          updated_at = now()
+	//  RETURNING username, client_id, scopes, created_at, updated_at
+	UpsertOAuthConsent(ctx context.Context, arg UpsertOAuthConsentParams) (OauthConsents, error)
+	//UpsertUserTotp
+	//
+	//  INSERT INTO user_totp (username, secret)
+	//  VALUES ($1, $2)
+	//  ON CONFLICT (username) DO UPDATE SET secret         = EXCLUDED.secret,
+	//                                       last_used_step = 0,
+	//                                       confirmed_at   = NULL,
+	//                                       created_at     = now()
+	//  RETURNING username, secret, last_used_step, confirmed_at, created_at
+	UpsertUserTotp(ctx context.Context, arg UpsertUserTotpParams) (UserTotp, error)
+	//UseEmailVerification
+	//
+	//  UPDATE email_verifications
+	//  SET used_at = now()
+	//  WHERE hashed_token = $1
+	//    AND used_at IS NULL
+	//    AND expires_at > now()
+	//  RETURNING hashed_token, username, email, used_at, expires_at, created_at, is_email_change
+	UseEmailVerification(ctx context.Context, hashedToken string) (EmailVerifications, error)
+	//UsePasswordResetToken
+	//
+	//  UPDATE password_reset_tokens
+	//  SET used_at = now()
+	//  WHERE hashed_token = $1
+	//    AND used_at IS NULL
+	//    AND expires_at > now()
+	//  RETURNING hashed_token, username, used_at, expires_at, created_at
+	UsePasswordResetToken(ctx context.Context, hashedToken string) (PasswordResetTokens, error)
+	//UseRecoveryCode
+	//
+	//  UPDATE recovery_codes
+	//  SET used_at = now()
+	//  WHERE username = $1
+	//    AND hashed_code = $2
+	//    AND used_at IS NULL
+	//  RETURNING id, username, hashed_code, used_at, created_at
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCodes, error)
+	//UseTotpStep
+	//
+	//  UPDATE user_totp
+	//  SET last_used_step = $2
+	//  WHERE username = $1
+	//    AND last_used_step < $2
+	//  RETURNING username, secret, last_used_step, confirmed_at, created_at
+	UseTotpStep(ctx context.Context, arg UseTotpStepParams) (UserTotp, error)
+	//VerifyUserEmail
+	//
+	//  UPDATE users
+	//  SET is_email_verified = true,
+	//      updated_at        = now()
+	//  WHERE username = $1
+	//    AND email = $2
+	//  RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (Users, error)
 }
 
 var _ Querier = (*Queries)(nil)
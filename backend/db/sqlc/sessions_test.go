@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestCreateSession(t *testing.T) {
+	sqlStore = newDB(t)
+	createRandomSession(t)
+}
+
+func TestRenewSessionTx(t *testing.T) {
+	sqlStore = newDB(t)
+	session1 := createRandomSession(t)
+
+	arg := RenewSessionTxParams{
+		SessionID: session1.ID,
+		NewSession: CreateSessionParams{
+			ID:                 uuid.New(),
+			Username:           session1.Username,
+			HashedRefreshToken: pkg.HashSecret(pkg.RandomString(32)),
+			UserAgent:          session1.UserAgent,
+			ClientIp:           session1.ClientIp,
+			ExpiresAt:          time.Now().Add(time.Hour),
+		},
+	}
+	session2, err := sqlStore.RenewSessionTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.NewSession.ID, session2.ID)
+	// 轮换出的会话与旧会话属于同一次登录
+	require.Equal(t, session1.FamilyID, session2.FamilyID)
+	require.Nil(t, session2.RotatedAt)
+
+	rotated, err := sqlStore.GetSession(context.Background(), session1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, rotated.RotatedAt)
+
+	// 再次轮换同一个会话
+	arg.NewSession.ID = uuid.New()
+	_, err = sqlStore.RenewSessionTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	err = sqlStore.BlockSessionFamily(context.Background(), session1.FamilyID)
+	require.NoError(t, err)
+	blocked, err := sqlStore.GetSession(context.Background(), session2.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+}
+
+func createRandomSession(t *testing.T) Sessions {
+	user := createRandomUser(t)
+	id := uuid.New()
+	arg := CreateSessionParams{
+		ID:                 id,
+		FamilyID:           id,
+		Username:           user.Username,
+		HashedRefreshToken: pkg.HashSecret(pkg.RandomString(32)),
+		UserAgent:          pkg.RandomString(10),
+		ClientIp:           "127.0.0.1",
+		IsBlocked:          false,
+		ExpiresAt:          time.Now().Add(time.Hour),
+	}
+
+	session, err := sqlStore.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.FamilyID, session.FamilyID)
+	require.Equal(t, arg.Username, session.Username)
+	require.Equal(t, arg.HashedRefreshToken, session.HashedRefreshToken)
+	require.False(t, session.IsBlocked)
+	require.Nil(t, session.RotatedAt)
+	require.NotZero(t, session.CreatedAt)
+
+	return session
+}
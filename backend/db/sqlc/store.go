@@ -2,15 +2,34 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransfersParams) (TransfersTxResult, error)
+	RenewSessionTx(ctx context.Context, arg RenewSessionTxParams) (Sessions, error)
+	ConfirmTotpTx(ctx context.Context, arg ConfirmTotpTxParams) (UserTotp, error)
+	DisableTotpTx(ctx context.Context, username string) error
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (Users, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (Users, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (Users, error)
+	VerifyEmailTx(ctx context.Context, hashedToken string) (Users, error)
+	RequestEmailChangeTx(ctx context.Context, arg CreateEmailChangeParams) (EmailVerifications, error)
+	DeleteUserTx(ctx context.Context, username string) (DeleteUserTxResult, error)
+	AuditTx(ctx context.Context, event *AuditEvent, fn func(ctx context.Context, q Querier) error) error
+	AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error)
+	RotateOAuthRefreshTokenTx(ctx context.Context, arg RotateOAuthRefreshTokenTxParams) (OauthRefreshTokens, error)
+	RevokeOAuthConsentTx(ctx context.Context, arg DeleteOAuthConsentParams) (OauthConsents, error)
 }
 
 type SQLStore struct {
@@ -25,8 +44,16 @@ func NewStore(db *pgxpool.Pool) Store {
 	}
 }
 
+// ctx中正在进行的事务
+type txKey struct{}
+
 // execTx 通用的事务方法, 通过外部传递函数作为事务的运行内容
+// ctx中已有事务时(例如在 AuditTx 中调用其它的事务方法)加入该事务, 由外层的事务提交或回滚
 func (s *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	if query, ok := ctx.Value(txKey{}).(*Queries); ok {
+		return fn(query)
+	}
+
 	// 开始一个事务, 如sql的begin
 	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
@@ -49,6 +76,69 @@ func (s *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
 	return tx.Commit(ctx)
 }
 
+// AuditEvent 审计事件, Before 与 After 为变更前后的快照, 序列化为JSON保存, 不能包含密码等敏感信息
+type AuditEvent struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	ClientIP     string
+	UserAgent    string
+	RequestID    string
+	Before       any
+	After        any
+}
+
+func (e *AuditEvent) params() (CreateAuditEventParams, error) {
+	before, err := marshalSnapshot(e.Before)
+	if err != nil {
+		return CreateAuditEventParams{}, err
+	}
+	after, err := marshalSnapshot(e.After)
+	if err != nil {
+		return CreateAuditEventParams{}, err
+	}
+	return CreateAuditEventParams{
+		Actor:        e.Actor,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		ClientIp:     e.ClientIP,
+		UserAgent:    e.UserAgent,
+		RequestID:    e.RequestID,
+		Before:       before,
+		After:        after,
+	}, nil
+}
+
+// marshalSnapshot 快照为nil时保存为NULL
+func marshalSnapshot(snapshot any) ([]byte, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	return json.Marshal(snapshot)
+}
+
+// AuditTx 在同一个事务中执行变更并记录审计事件, 变更失败时不记录, 记录失败时变更回滚
+// fn 中通过 q 执行查询, 调用其它的事务方法时传入 fn 的 ctx 以加入同一个事务;
+// fn 可以在执行过程中补充 event 的资源ID与快照. fn 为nil时只记录事件, 例如登录失败
+// 事件追加到散列链的末尾, 见 appendAuditEvent
+func (s *SQLStore) AuditTx(ctx context.Context, event *AuditEvent, fn func(ctx context.Context, q Querier) error) error {
+	return s.execTx(ctx, func(q *Queries) error {
+		if fn != nil {
+			if err := fn(context.WithValue(ctx, txKey{}, q), q); err != nil {
+				return err
+			}
+		}
+
+		arg, err := event.params()
+		if err != nil {
+			return err
+		}
+		return appendAuditEvent(ctx, q, arg)
+	})
+}
+
 type TransfersParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
@@ -150,3 +240,460 @@ func addMoney(
 	// 命名的返回值会自动填充, 以下的return就相当于account1, account2, err
 	return
 }
+
+// ErrRefreshTokenReused 刷新令牌已被轮换过, 再次使用说明令牌可能已经泄露
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type RenewSessionTxParams struct {
+	// 被轮换的会话id
+	SessionID uuid.UUID `json:"session_id"`
+	// 轮换出的新会话
+	NewSession CreateSessionParams `json:"new_session"`
+}
+
+// RenewSessionTx 轮换会话
+// 1. 将旧的会话标记为已轮换, 旧会话已被轮换过则返回 ErrRefreshTokenReused
+// 2. 创建一个同一family的新会话
+// 两步在同一个事务中执行, 保证并发使用同一个刷新令牌时只有一个请求能够轮换成功
+func (s *SQLStore) RenewSessionTx(ctx context.Context, arg RenewSessionTxParams) (Sessions, error) {
+	var session Sessions
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		old, err := q.RotateSession(ctx, arg.SessionID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRefreshTokenReused
+			}
+			return err
+		}
+
+		newSession := arg.NewSession
+		newSession.FamilyID = old.FamilyID
+		session, err = q.CreateSession(ctx, newSession)
+		return err
+	})
+
+	return session, err
+}
+
+type ConfirmTotpTxParams struct {
+	Username string `json:"username"`
+	// 确认时校验通过的一次性密码的时间步
+	Step int64 `json:"step"`
+	// 新生成的恢复码的散列值
+	HashedRecoveryCodes []string `json:"hashed_recovery_codes"`
+}
+
+// ConfirmTotpTx 确认绑定TOTP
+// 1. 将待确认的TOTP密钥标记为已确认, 已确认过则返回 sql.ErrNoRows
+// 2. 删除旧的恢复码并保存新的恢复码
+func (s *SQLStore) ConfirmTotpTx(ctx context.Context, arg ConfirmTotpTxParams) (UserTotp, error) {
+	var userTotp UserTotp
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		userTotp, err = q.ConfirmUserTotp(ctx, ConfirmUserTotpParams{
+			Username:     arg.Username,
+			LastUsedStep: arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+		for _, hashedCode := range arg.HashedRecoveryCodes {
+			err = q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   arg.Username,
+				HashedCode: hashedCode,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return userTotp, err
+}
+
+// DisableTotpTx 关闭两步验证, 同时删除TOTP密钥与所有恢复码
+func (s *SQLStore) DisableTotpTx(ctx context.Context, username string) error {
+	return s.execTx(ctx, func(q *Queries) error {
+		if err := q.DeleteRecoveryCodes(ctx, username); err != nil {
+			return err
+		}
+		return q.DeleteUserTotp(ctx, username)
+	})
+}
+
+// ErrPasswordResetTokenInvalid 重置密码的令牌不存在, 已过期或已被使用
+var ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or has expired")
+
+type ResetPasswordTxParams struct {
+	HashedToken    string `json:"hashed_token"`
+	HashedPassword string `json:"hashed_password"`
+}
+
+// ResetPasswordTx 使用重置令牌修改密码
+// 1. 将令牌标记为已使用, 令牌无效时返回 ErrPasswordResetTokenInvalid
+// 2. 修改密码并更新 password_changed_at
+// 3. 作废该用户其它未使用的重置令牌, 并禁用该用户的所有会话
+func (s *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (Users, error) {
+	var user Users
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		resetToken, err := q.UsePasswordResetToken(ctx, arg.HashedToken)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrPasswordResetTokenInvalid
+			}
+			return err
+		}
+
+		user, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:       resetToken.Username,
+			HashedPassword: arg.HashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.InvalidateUserPasswordResetTokens(ctx, resetToken.Username); err != nil {
+			return err
+		}
+		return q.BlockUserSessions(ctx, resetToken.Username)
+	})
+
+	return user, err
+}
+
+type ChangePasswordTxParams struct {
+	Username       string `json:"username"`
+	HashedPassword string `json:"hashed_password"`
+}
+
+// ChangePasswordTx 已登录的用户修改密码
+// 1. 修改密码并更新 password_changed_at, 此前颁发的令牌随之失效
+// 2. 作废该用户未使用的重置令牌, 并禁用该用户的所有会话
+func (s *SQLStore) ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (Users, error) {
+	var user Users
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:       arg.Username,
+			HashedPassword: arg.HashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.InvalidateUserPasswordResetTokens(ctx, arg.Username); err != nil {
+			return err
+		}
+		return q.BlockUserSessions(ctx, arg.Username)
+	})
+
+	return user, err
+}
+
+type CreateUserTxParams struct {
+	CreateUserParams
+	// 注册时发送的邮箱验证令牌的散列值
+	HashedVerificationToken string    `json:"hashed_verification_token"`
+	VerificationExpiresAt   time.Time `json:"verification_expires_at"`
+}
+
+// CreateUserTx 创建用户, 同时记录注册时发送的邮箱验证令牌
+func (s *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (Users, error) {
+	var user Users
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateEmailVerification(ctx, CreateEmailVerificationParams{
+			HashedToken: arg.HashedVerificationToken,
+			Username:    user.Username,
+			Email:       user.Email,
+			ExpiresAt:   arg.VerificationExpiresAt,
+		})
+		return err
+	})
+
+	return user, err
+}
+
+// ErrEmailVerificationInvalid 邮箱验证令牌不存在, 已过期, 已被使用, 或者用户已修改了邮箱
+var ErrEmailVerificationInvalid = errors.New("email verification token is invalid or has expired")
+
+// ErrEmailTaken 新邮箱在验证之前已被其他用户使用
+var ErrEmailTaken = errors.New("email is already used by another user")
+
+// VerifyEmailTx 使用邮箱验证令牌验证邮箱
+// 1. 将令牌标记为已使用
+// 2. 修改邮箱的令牌: 将用户的邮箱替换为新邮箱并标记为已验证, 同时使其余未使用的令牌与密码重置令牌失效
+// 3. 注册时的令牌: 令牌对应的邮箱仍是用户当前的邮箱时, 将邮箱标记为已验证
+// 令牌无效时返回 ErrEmailVerificationInvalid, 新邮箱已被使用时返回 ErrEmailTaken
+func (s *SQLStore) VerifyEmailTx(ctx context.Context, hashedToken string) (Users, error) {
+	var user Users
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		verification, err := q.UseEmailVerification(ctx, hashedToken)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrEmailVerificationInvalid
+			}
+			return err
+		}
+
+		if verification.IsEmailChange {
+			user, err = q.ChangeUserEmail(ctx, ChangeUserEmailParams{
+				Username: verification.Username,
+				Email:    verification.Email,
+			})
+			if err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+					return ErrEmailTaken
+				}
+				return err
+			}
+			// 发往旧邮箱或其他新邮箱的令牌不能再修改邮箱
+			if err = q.InvalidateEmailVerifications(ctx, verification.Username); err != nil {
+				return err
+			}
+			// 发往旧邮箱的密码重置令牌不能再重置密码
+			return q.InvalidateUserPasswordResetTokens(ctx, verification.Username)
+		}
+
+		user, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			Username: verification.Username,
+			Email:    verification.Email,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEmailVerificationInvalid
+		}
+		return err
+	})
+
+	return user, err
+}
+
+// RequestEmailChangeTx 记录修改邮箱的验证令牌, 之前未使用的令牌全部失效, 只有最后一次请求的新邮箱可以验证
+func (s *SQLStore) RequestEmailChangeTx(ctx context.Context, arg CreateEmailChangeParams) (EmailVerifications, error) {
+	var verification EmailVerifications
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		if err := q.InvalidateEmailVerifications(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		var err error
+		verification, err = q.CreateEmailChange(ctx, arg)
+		return err
+	})
+
+	return verification, err
+}
+
+// ErrAccountNotEmpty 删除用户时仍有余额不为0的账户
+var ErrAccountNotEmpty = errors.New("account balance is not zero")
+
+// ErrUserDeleted 用户已被删除
+var ErrUserDeleted = errors.New("user has been deleted")
+
+type DeleteUserTxResult struct {
+	User           Users      `json:"user"`
+	ClosedAccounts []Accounts `json:"closed_accounts"`
+}
+
+// DeleteUserTx 删除用户, 保留账户, 条目与转账记录, 用户名作为账目的关联保留
+// 1. 锁定用户的所有账户, 有余额不为0的账户时返回 ErrAccountNotEmpty
+// 2. 关闭并冻结所有账户
+// 3. 姓名, 邮箱与密码替换为无意义的值, 已删除时返回 ErrUserDeleted
+// 4. 删除或失效登录凭据: 会话, 令牌, 两步验证, API密钥, OAuth授权, 未使用的邮件令牌
+func (s *SQLStore) DeleteUserTx(ctx context.Context, username string) (DeleteUserTxResult, error) {
+	var result DeleteUserTxResult
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		accounts, err := q.ListOwnerAccountsForUpdate(ctx, username)
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			if account.Balance != 0 {
+				return fmt.Errorf("%w: account %d", ErrAccountNotEmpty, account.ID)
+			}
+		}
+
+		result.ClosedAccounts, err = q.CloseOwnerAccounts(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		// 邮箱有唯一约束, 使用随机的不可投递地址
+		result.User, err = q.PseudonymizeUser(ctx, PseudonymizeUserParams{
+			Email:    fmt.Sprintf("deleted-%s@deleted.invalid", uuid.New()),
+			Username: username,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserDeleted
+			}
+			return err
+		}
+
+		err = q.RevokeUserTokens(ctx, RevokeUserTokensParams{
+			Username:      username,
+			RevokedBefore: *result.User.DeletedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		cleanups := []func(context.Context, string) error{
+			q.BlockUserSessions,
+			q.DeleteRecoveryCodes,
+			q.DeleteUserTotp,
+			q.DeleteUserAPIKeys,
+			q.DeleteUserOAuthRefreshTokens,
+			q.DeleteUserOAuthConsents,
+			q.InvalidateEmailVerifications,
+			q.InvalidateUserPasswordResetTokens,
+		}
+		for _, cleanup := range cleanups {
+			if err = cleanup(ctx, username); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// ErrNegativeBalance 调整之后账户的余额为负数
+var ErrNegativeBalance = errors.New("account balance cannot be negative")
+
+type AdjustBalanceTxParams struct {
+	AccountID int64  `json:"account_id"`
+	Amount    int64  `json:"amount"`
+	Reason    string `json:"reason"`
+	CreatedBy string `json:"created_by"`
+}
+
+type AdjustBalanceTxResult struct {
+	Account    Accounts           `json:"account"`
+	Entry      Entries            `json:"entry"`
+	Adjustment AccountAdjustments `json:"adjustment"`
+}
+
+// AdjustBalanceTx 后台修正账户余额, 与转账一样通过条目记录余额的变化
+// 1. 条目表记录一条数据, 记录调整的金额
+// 2. 更新账户的余额, 调整之后余额为负数时返回 ErrNegativeBalance
+// 3. 记录调整的原因与操作人
+func (s *SQLStore) AdjustBalanceTx(ctx context.Context, arg AdjustBalanceTxParams) (AdjustBalanceTxResult, error) {
+	var result AdjustBalanceTxResult
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.Entry, err = q.CreateEntry(ctx, CreateEntryParams{
+			AccountID: arg.AccountID,
+			Amount:    arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Account, err = q.AddAccountBalancer(ctx, AddAccountBalancerParams{
+			Amount: arg.Amount,
+			ID:     arg.AccountID,
+		})
+		if err != nil {
+			return err
+		}
+		if result.Account.Balance < 0 {
+			return ErrNegativeBalance
+		}
+
+		result.Adjustment, err = q.CreateAccountAdjustment(ctx, CreateAccountAdjustmentParams{
+			AccountID: arg.AccountID,
+			EntryID:   result.Entry.ID,
+			Amount:    arg.Amount,
+			Reason:    arg.Reason,
+			CreatedBy: arg.CreatedBy,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// ErrOAuthRefreshTokenInvalid 第三方应用的刷新令牌不存在, 已过期或不属于该应用
+var ErrOAuthRefreshTokenInvalid = errors.New("oauth refresh token is invalid or has expired")
+
+type RotateOAuthRefreshTokenTxParams struct {
+	// 客户端提交的刷新令牌的散列值
+	HashedToken string
+	ClientID    string
+	// 轮换出的新刷新令牌的散列值与过期时间
+	NewHashedToken string
+	ExpiresAt      time.Time
+}
+
+// RotateOAuthRefreshTokenTx 使用第三方应用的刷新令牌, 删除旧令牌并颁发授权范围相同的新令牌
+// 令牌无效时返回 ErrOAuthRefreshTokenInvalid, 事务回滚, 旧令牌不受影响
+func (s *SQLStore) RotateOAuthRefreshTokenTx(ctx context.Context, arg RotateOAuthRefreshTokenTxParams) (OauthRefreshTokens, error) {
+	var refreshToken OauthRefreshTokens
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		old, err := q.ConsumeOAuthRefreshToken(ctx, arg.HashedToken)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOAuthRefreshTokenInvalid
+			}
+			return err
+		}
+		if old.ClientID != arg.ClientID || !time.Now().Before(old.ExpiresAt) {
+			return ErrOAuthRefreshTokenInvalid
+		}
+
+		refreshToken, err = q.CreateOAuthRefreshToken(ctx, CreateOAuthRefreshTokenParams{
+			HashedToken: arg.NewHashedToken,
+			ClientID:    old.ClientID,
+			Username:    old.Username,
+			Scopes:      old.Scopes,
+			ExpiresAt:   arg.ExpiresAt,
+		})
+		return err
+	})
+
+	return refreshToken, err
+}
+
+// RevokeOAuthConsentTx 撤销用户对第三方应用的授权, 同时删除该应用的刷新令牌
+// 已颁发的访问令牌有效期很短, 到期之后应用无法再访问
+func (s *SQLStore) RevokeOAuthConsentTx(ctx context.Context, arg DeleteOAuthConsentParams) (OauthConsents, error) {
+	var consent OauthConsents
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		consent, err = q.DeleteOAuthConsent(ctx, arg)
+		if err != nil {
+			return err
+		}
+		return q.DeleteOAuthRefreshTokens(ctx, DeleteOAuthRefreshTokensParams{
+			Username: arg.Username,
+			ClientID: arg.ClientID,
+		})
+	})
+
+	return consent, err
+}
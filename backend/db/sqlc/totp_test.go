@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestConfirmTotpTx(t *testing.T) {
+	sqlStore = newDB(t)
+	user := createRandomUser(t)
+
+	userTotp, err := sqlStore.UpsertUserTotp(context.Background(), UpsertUserTotpParams{
+		Username: user.Username,
+		Secret:   pkg.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.Nil(t, userTotp.ConfirmedAt)
+
+	hashedCode := pkg.HashSecret(pkg.RandomString(10))
+	confirmed, err := sqlStore.ConfirmTotpTx(context.Background(), ConfirmTotpTxParams{
+		Username:            user.Username,
+		Step:                100,
+		HashedRecoveryCodes: []string{hashedCode},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, confirmed.ConfirmedAt)
+	require.Equal(t, int64(100), confirmed.LastUsedStep)
+
+	// 已确认的TOTP不能重复确认
+	_, err = sqlStore.ConfirmTotpTx(context.Background(), ConfirmTotpTxParams{Username: user.Username, Step: 101})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// 同一个时间步只能使用一次
+	_, err = sqlStore.UseTotpStep(context.Background(), UseTotpStepParams{Username: user.Username, LastUsedStep: 100})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = sqlStore.UseTotpStep(context.Background(), UseTotpStepParams{Username: user.Username, LastUsedStep: 101})
+	require.NoError(t, err)
+
+	// 恢复码只能使用一次
+	arg := UseRecoveryCodeParams{Username: user.Username, HashedCode: hashedCode}
+	_, err = sqlStore.UseRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	_, err = sqlStore.UseRecoveryCode(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, sqlStore.DisableTotpTx(context.Background(), user.Username))
+	_, err = sqlStore.GetUserTotp(context.Background(), user.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestIncrementMfaChallengeAttempts(t *testing.T) {
+	sqlStore = newDB(t)
+	ctx := context.Background()
+	user := createRandomUser(t)
+	hashedToken := pkg.HashSecret(pkg.RandomString(32))
+
+	_, err := sqlStore.CreateMfaChallenge(ctx, CreateMfaChallengeParams{
+		HashedToken: hashedToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	arg := IncrementMfaChallengeAttemptsParams{HashedToken: hashedToken, MaxAttempts: 2}
+	for i := 1; i <= 2; i++ {
+		challenge, err := sqlStore.IncrementMfaChallengeAttempts(ctx, arg)
+		require.NoError(t, err)
+		require.Equal(t, int32(i), challenge.Attempts)
+	}
+
+	// 次数用完之后不再增加
+	_, err = sqlStore.IncrementMfaChallengeAttempts(ctx, arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	challenge, err := sqlStore.GetMfaChallenge(ctx, hashedToken)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), challenge.Attempts)
+
+	// 已使用的挑战不再增加
+	arg.MaxAttempts = 5
+	_, err = sqlStore.CompleteMfaChallenge(ctx, hashedToken)
+	require.NoError(t, err)
+	_, err = sqlStore.IncrementMfaChallengeAttempts(ctx, arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	// 已过期的挑战不再增加
+	expiredToken := pkg.HashSecret(pkg.RandomString(32))
+	_, err = sqlStore.CreateMfaChallenge(ctx, CreateMfaChallengeParams{
+		HashedToken: expiredToken,
+		Username:    user.Username,
+		ExpiresAt:   time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	_, err = sqlStore.IncrementMfaChallengeAttempts(ctx, IncrementMfaChallengeAttemptsParams{HashedToken: expiredToken, MaxAttempts: 5})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
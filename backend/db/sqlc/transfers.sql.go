@@ -65,6 +65,91 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfers, error)
 	return i, err
 }
 
+const ListOwnerTransfers = `-- name: ListOwnerTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at
+FROM transfers
+WHERE from_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+   OR to_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+ORDER BY id
+`
+
+// ListOwnerTransfers
+//
+//	SELECT id, from_account_id, to_account_id, amount, created_at
+//	FROM transfers
+//	WHERE from_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+//	   OR to_account_id IN (SELECT id FROM accounts WHERE owner = $1)
+//	ORDER BY id
+func (q *Queries) ListOwnerTransfers(ctx context.Context, owner string) ([]Transfers, error) {
+	rows, err := q.db.Query(ctx, ListOwnerTransfers, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfers{}
+	for rows.Next() {
+		var i Transfers
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const ListRecentTransfers = `-- name: ListRecentTransfers :many
+SELECT id, from_account_id, to_account_id, amount, created_at
+FROM transfers
+ORDER BY id DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListRecentTransfersParams struct {
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+// ListRecentTransfers
+//
+//	SELECT id, from_account_id, to_account_id, amount, created_at
+//	FROM transfers
+//	ORDER BY id DESC
+//	LIMIT $1 OFFSET $2
+func (q *Queries) ListRecentTransfers(ctx context.Context, arg ListRecentTransfersParams) ([]Transfers, error) {
+	rows, err := q.db.Query(ctx, ListRecentTransfers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transfers{}
+	for rows.Next() {
+		var i Transfers
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const ListTransfers = `-- name: ListTransfers :many
 SELECT id, from_account_id, to_account_id, amount, created_at
 FROM transfers
@@ -9,13 +9,51 @@ import (
 	"context"
 )
 
+const ChangeUserEmail = `-- name: ChangeUserEmail :one
+UPDATE users
+SET email             = $2,
+    is_email_verified = true
+WHERE username = $1
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type ChangeUserEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// ChangeUserEmail
+//
+//	UPDATE users
+//	SET email             = $2,
+//	    is_email_verified = true
+//	WHERE username = $1
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) ChangeUserEmail(ctx context.Context, arg ChangeUserEmailParams) (Users, error) {
+	row := q.db.QueryRow(ctx, ChangeUserEmail, arg.Username, arg.Email)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
 const CreateUser = `-- name: CreateUser :one
 INSERT INTO users (username,
                    full_name,
                    hashed_password,
                    email)
 VALUES ($1, $2, $3, $4)
-RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 `
 
 type CreateUserParams struct {
@@ -32,7 +70,7 @@ type CreateUserParams struct {
 //	                   hashed_password,
 //	                   email)
 //	VALUES ($1, $2, $3, $4)
-//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Users, error) {
 	row := q.db.QueryRow(ctx, CreateUser,
 		arg.Username,
@@ -49,12 +87,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (Users,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const GetUser = `-- name: GetUser :one
-SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 FROM users
 WHERE username = $1
 LIMIT 1
@@ -62,7 +103,7 @@ LIMIT 1
 
 // GetUser
 //
-//	SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at
+//	SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
 //	FROM users
 //	WHERE username = $1
 //	LIMIT 1
@@ -77,6 +118,320 @@ func (q *Queries) GetUser(ctx context.Context, username string) (Users, error) {
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const GetUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+// GetUserByEmail
+//
+//	SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+//	FROM users
+//	WHERE email = $1
+//	LIMIT 1
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (Users, error) {
+	row := q.db.QueryRow(ctx, GetUserByEmail, email)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const PseudonymizeUser = `-- name: PseudonymizeUser :one
+UPDATE users
+SET full_name         = '',
+    email             = $1,
+    hashed_password   = '',
+    is_email_verified = false,
+    deleted_at        = now()
+WHERE username = $2
+  AND deleted_at IS NULL
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type PseudonymizeUserParams struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+// PseudonymizeUser
+//
+//	UPDATE users
+//	SET full_name         = '',
+//	    email             = $1,
+//	    hashed_password   = '',
+//	    is_email_verified = false,
+//	    deleted_at        = now()
+//	WHERE username = $2
+//	  AND deleted_at IS NULL
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) PseudonymizeUser(ctx context.Context, arg PseudonymizeUserParams) (Users, error) {
+	row := q.db.QueryRow(ctx, PseudonymizeUser, arg.Email, arg.Username)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const RehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE username = $2
+  AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	HashedPassword    string `json:"hashedPassword"`
+	Username          string `json:"username"`
+	OldHashedPassword string `json:"oldHashedPassword"`
+}
+
+// RehashUserPassword
+//
+//	UPDATE users
+//	SET hashed_password = $1
+//	WHERE username = $2
+//	  AND hashed_password = $3
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, RehashUserPassword, arg.HashedPassword, arg.Username, arg.OldHashedPassword)
+	return err
+}
+
+const SearchUsers = `-- name: SearchUsers :many
+SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+FROM users
+WHERE username ILIKE '%' || $1 || '%'
+   OR full_name ILIKE '%' || $1 || '%'
+   OR email ILIKE '%' || $1 || '%'
+ORDER BY username
+LIMIT $2 OFFSET $3
+`
+
+type SearchUsersParams struct {
+	Query  string `json:"query"`
+	Limit  int64  `json:"limit"`
+	Offset int64  `json:"offset"`
+}
+
+// SearchUsers
+//
+//	SELECT username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+//	FROM users
+//	WHERE username ILIKE '%' || $1 || '%'
+//	   OR full_name ILIKE '%' || $1 || '%'
+//	   OR email ILIKE '%' || $1 || '%'
+//	ORDER BY username
+//	LIMIT $2 OFFSET $3
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]Users, error) {
+	rows, err := q.db.Query(ctx, SearchUsers, arg.Query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Users{}
+	for rows.Next() {
+		var i Users
+		if err := rows.Scan(
+			&i.Username,
+			&i.FullName,
+			&i.HashedPassword,
+			&i.Email,
+			&i.PasswordChangedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.IsEmailVerified,
+			&i.Role,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const UpdateUser = `-- name: UpdateUser :one
+UPDATE users
+SET full_name = COALESCE($1, full_name)
+WHERE username = $2
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type UpdateUserParams struct {
+	FullName *string `json:"fullName"`
+	Username string  `json:"username"`
+}
+
+// UpdateUser
+//
+//	UPDATE users
+//	SET full_name = COALESCE($1, full_name)
+//	WHERE username = $2
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (Users, error) {
+	row := q.db.QueryRow(ctx, UpdateUser, arg.FullName, arg.Username)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const UpdateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password     = $2,
+    password_changed_at = now(),
+    updated_at          = now()
+WHERE username = $1
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type UpdateUserPasswordParams struct {
+	Username       string `json:"username"`
+	HashedPassword string `json:"hashedPassword"`
+}
+
+// UpdateUserPassword
+//
+//	UPDATE users
+//	SET hashed_password     = $2,
+//	    password_changed_at = now(),
+//	    updated_at          = now()
+//	WHERE username = $1
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (Users, error) {
+	row := q.db.QueryRow(ctx, UpdateUserPassword, arg.Username, arg.HashedPassword)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const UpdateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role       = $2,
+    updated_at = now()
+WHERE username = $1
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type UpdateUserRoleParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// UpdateUserRole
+//
+//	UPDATE users
+//	SET role       = $2,
+//	    updated_at = now()
+//	WHERE username = $1
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (Users, error) {
+	row := q.db.QueryRow(ctx, UpdateUserRole, arg.Username, arg.Role)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const VerifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true,
+    updated_at        = now()
+WHERE username = $1
+  AND email = $2
+RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+`
+
+type VerifyUserEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// VerifyUserEmail
+//
+//	UPDATE users
+//	SET is_email_verified = true,
+//	    updated_at        = now()
+//	WHERE username = $1
+//	  AND email = $2
+//	RETURNING username, full_name, hashed_password, email, password_changed_at, created_at, updated_at, is_email_verified, role, deleted_at
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (Users, error) {
+	row := q.db.QueryRow(ctx, VerifyUserEmail, arg.Username, arg.Email)
+	var i Users
+	err := row.Scan(
+		&i.Username,
+		&i.FullName,
+		&i.HashedPassword,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsEmailVerified,
+		&i.Role,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/stretchr/testify/require"
 	"simple_bank/pkg"
@@ -20,6 +23,26 @@ func TestGetUser(t *testing.T) {
 	require.Equal(t, user1.Username, user.Username)
 }
 
+func TestUpdateUser(t *testing.T) {
+	user := createRandomUser(t)
+
+	// 只修改提供的字段, 触发器更新 updated_at
+	fullName := pkg.RandomString(6)
+	updated, err := sqlStore.UpdateUser(context.Background(), UpdateUserParams{
+		FullName: &fullName,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, fullName, updated.FullName)
+	require.Equal(t, user.Email, updated.Email)
+	require.True(t, updated.UpdatedAt.After(user.UpdatedAt))
+
+	unchanged, err := sqlStore.UpdateUser(context.Background(), UpdateUserParams{Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, fullName, unchanged.FullName)
+	require.Equal(t, updated.UpdatedAt, unchanged.UpdatedAt)
+}
+
 func createRandomUser(t *testing.T) Users {
 	ctx := context.Background()
 
@@ -46,3 +69,90 @@ func createRandomUser(t *testing.T) Users {
 
 	return user
 }
+
+func TestChangePasswordTx(t *testing.T) {
+	sqlStore = newDB(t)
+	user := createRandomUser(t)
+
+	arg := ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: pkg.RandomString(10),
+	}
+	updated, err := sqlStore.ChangePasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedPassword, updated.HashedPassword)
+	require.True(t, updated.PasswordChangedAt.After(user.PasswordChangedAt))
+
+	// 修改密码之前颁发的令牌被视为已撤销, 时间精确到微秒
+	revoked, err := sqlStore.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: updated.PasswordChangedAt.Add(-time.Microsecond),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// 修改密码之后颁发的令牌不受影响
+	revoked, err = sqlStore.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: user.Username,
+		IssuedAt: updated.PasswordChangedAt.Add(time.Microsecond),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	sqlStore = newDB(t)
+	user := createRandomUser(t)
+	// 新注册的用户都是客户
+	require.Equal(t, "customer", user.Role)
+
+	updated, err := sqlStore.UpdateUserRole(context.Background(), UpdateUserRoleParams{
+		Username: user.Username,
+		Role:     "teller",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "teller", updated.Role)
+
+	// 不支持的角色违反约束
+	_, err = sqlStore.UpdateUserRole(context.Background(), UpdateUserRoleParams{
+		Username: user.Username,
+		Role:     "root",
+	})
+	require.Error(t, err)
+}
+
+func TestDeleteUserTx(t *testing.T) {
+	account := createRandomAccount(t)
+	ctx := context.Background()
+
+	// 有余额时不能删除
+	_, err := sqlStore.DeleteUserTx(ctx, account.Owner)
+	require.ErrorIs(t, err, ErrAccountNotEmpty)
+
+	_, err = sqlStore.UpdateAccount(ctx, UpdateAccountParams{ID: account.ID, Balance: 0})
+	require.NoError(t, err)
+
+	result, err := sqlStore.DeleteUserTx(ctx, account.Owner)
+	require.NoError(t, err)
+	require.Equal(t, account.Owner, result.User.Username)
+	require.Empty(t, result.User.FullName)
+	require.Empty(t, result.User.HashedPassword)
+	require.NotNil(t, result.User.DeletedAt)
+	require.Len(t, result.ClosedAccounts, 1)
+	require.True(t, result.ClosedAccounts[0].IsFrozen)
+	require.NotNil(t, result.ClosedAccounts[0].ClosedAt)
+
+	// 删除之前颁发的令牌全部失效
+	revoked, err := sqlStore.IsTokenRevoked(ctx, IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: account.Owner,
+		IssuedAt: result.User.DeletedAt.Add(-time.Microsecond),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	_, err = sqlStore.DeleteUserTx(ctx, account.Owner)
+	require.ErrorIs(t, err, ErrUserDeleted)
+}
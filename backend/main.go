@@ -2,35 +2,112 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"simple_bank/config"
 
 	"simple_bank/api"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"simple_bank/db/migrate"
 	db "simple_bank/db/sqlc"
 )
 
 func main() {
-	cfg, err := config.LoadConfig(".")
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] [verify-audit | print-config | migrate up | migrate down N | migrate version | migrate force VERSION]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", "", "config file, defaults to app.env in the working directory if present")
+	configFlags := config.RegisterFlags(flags)
+	_ = flags.Parse(os.Args[1:])
+
+	cfg, err := config.Load(config.Options{Dir: ".", File: *configFile, Flags: configFlags})
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// 输出生效的配置, 不需要连接数据库
+	if flags.Arg(0) == "print-config" {
+		for _, setting := range cfg.Redacted() {
+			fmt.Printf("%s=%s\n", setting.Key, setting.Value)
+		}
+		return
 	}
 
 	conn, newDBErr := pgxpool.New(context.Background(), cfg.DBSource)
 	if newDBErr != nil {
-		panic(fmt.Sprintf("Unable to connect to database: %v", err))
+		panic(fmt.Sprintf("Unable to connect to database: %v", newDBErr))
 	}
 
 	store := db.NewStore(conn)
+	migrator, err := migrate.New(conn)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to load migrations: %v", err))
+	}
+
+	// 收到SIGINT或SIGTERM之后优雅退出, 再次收到信号时立即退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// 子命令, 执行之后退出
+	if flags.NArg() > 0 {
+		switch flags.Arg(0) {
+		case "verify-audit":
+			code := verifyAudit(ctx, store)
+			conn.Close()
+			os.Exit(code)
+		case "migrate":
+			code := runMigrate(ctx, migrator, flags.Args()[1:])
+			conn.Close()
+			os.Exit(code)
+		default:
+			conn.Close()
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", flags.Arg(0))
+			flags.Usage()
+			os.Exit(2)
+		}
+	}
+
+	// 多个实例同时启动时由咨询锁保证只有一个执行迁移
+	if cfg.DBAutoMigrate {
+		if err := migrator.Up(ctx); err != nil {
+			conn.Close()
+			log.Fatalf("migrate database: %v", err)
+		}
+	}
+	// 数据库版本落后时查询会失败, 拒绝启动
+	if err := migrator.Check(ctx); err != nil {
+		conn.Close()
+		if errors.Is(err, migrate.ErrSchemaBehind) {
+			log.Fatalf("%v, run \"migrate up\" or set DB_AUTO_MIGRATE=true", err)
+		}
+		log.Fatalf("check database schema: %v", err)
+	}
+
 	server, newServerErr := api.NewServer(cfg, store)
 	if newServerErr != nil {
-		panic(fmt.Sprintf("Unable to create server: %v", err))
+		panic(fmt.Sprintf("Unable to create server: %v", newServerErr))
 	}
 
-	err = server.Start(cfg.ServerAddress)
+	log.Printf("listening on %s", cfg.ServerAddress)
+	err = server.Start(ctx, cfg.ServerAddress)
+	// 服务退出之后关闭连接池, 此时处理中的请求已经结束
+	conn.Close()
 	if err != nil {
-		panic(fmt.Sprintf("Unable to start server: %v", err))
+		log.Fatalf("server stopped: %v", err)
 	}
+	log.Printf("server stopped")
 }
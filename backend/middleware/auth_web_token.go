@@ -3,19 +3,27 @@ package middleware
 import (
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"simple_bank/constants"
+	"simple_bank/pkg/apikey"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/revocation"
 	"simple_bank/pkg/token"
 	"strings"
 )
 
-func AuthWebTokenMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// AuthWebTokenMiddleware 校验Authorization头中的访问令牌
+// apiKeys 不为nil时同时接受 "ApiKey <密钥>" 形式的API密钥, 为nil时只接受访问令牌
+// audience 不为空时拒绝受众中不包含它的令牌, 没有受众的令牌不受限制
+func AuthWebTokenMiddleware(tokenMaker token.Maker, revocations revocation.Checker, apiKeys apikey.Verifier, audience string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		locale := GetLocale(ctx)
 		// 获取AuthorizationHeader头这个key的值
 		header := ctx.GetHeader(constants.AuthorizationHeaderKey)
 		if header == "" || len(header) == 0 {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": errors.New("未提供 authorization 标头").Error(),
+				"error": i18n.T(locale, i18n.MsgAuthHeaderMissing),
 			})
 			return
 		}
@@ -24,15 +32,21 @@ func AuthWebTokenMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		// 判断切片是否合法
 		if len(fields) != 2 {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": errors.New("授权标头格式无效").Error(),
+				"error": i18n.T(locale, i18n.MsgAuthHeaderInvalid),
 			})
 			return
 		}
 
+		// API密钥由密钥自身的权限限制可以访问的路由
+		if apiKeys != nil && strings.ToLower(fields[0]) == constants.AuthorizationAPIKeyType {
+			authenticateAPIKey(ctx, apiKeys, fields[1])
+			return
+		}
+
 		// 判断切片是否为服务器支持的授权类型
 		if strings.ToLower(fields[0]) != constants.AuthorizationHeaderType {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": errors.New("服务器不支持的授权类型").Error(),
+				"error": i18n.T(locale, i18n.MsgAuthTypeUnsupported),
 			})
 			return
 		}
@@ -42,11 +56,73 @@ func AuthWebTokenMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 		payload, err := tokenMaker.VerifyToken(accessToken)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(locale, tokenErrorMessage(err)),
+			})
+			return
+		}
+		// 刷新令牌只能在换取令牌的接口中使用
+		if !payload.IsAccess() {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(locale, i18n.MsgTokenInvalid),
+			})
+			return
+		}
+		if audience != "" && !payload.ForAudience(audience) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(locale, i18n.MsgTokenAudienceInvalid),
+			})
+			return
+		}
+
+		// 签名与有效期之外, 还需检查令牌是否已被撤销(退出登录)
+		revoked, err := revocations.IsRevoked(ctx, payload)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
+		if revoked {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(locale, i18n.MsgTokenRevoked),
+			})
+			return
+		}
 		ctx.Set(constants.AuthorizationPayloadKey, payload)
 		ctx.Next()
 	}
 }
+
+// authenticateAPIKey 校验API密钥, 将密钥所属用户的荷载与密钥的权限保存到上下文中
+func authenticateAPIKey(ctx *gin.Context, apiKeys apikey.Verifier, key string) {
+	principal, err := apiKeys.Verify(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrExpiredKey):
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(GetLocale(ctx), i18n.MsgAPIKeyExpired),
+			})
+		case errors.Is(err, apikey.ErrInvalidKey):
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(GetLocale(ctx), i18n.MsgAPIKeyInvalid),
+			})
+		default:
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	ctx.Set(constants.AuthorizationPayloadKey, principal.Payload)
+	ctx.Set(constants.APIKeyPermissionsKey, principal.Permissions)
+	ctx.Next()
+}
+
+// tokenErrorMessage 将不同Maker返回的校验错误映射为消息key
+func tokenErrorMessage(err error) string {
+	if errors.Is(err, token.ErrExpiredToken) || errors.Is(err, jwt.ErrTokenExpired) {
+		return i18n.MsgTokenExpired
+	}
+	return i18n.MsgTokenInvalid
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+// Authorize 要求令牌的角色拥有该权限, 需要在 AuthWebTokenMiddleware 之后使用
+// 使用API密钥时同时要求密钥被授予了该权限, 受限令牌的授权范围由 RequireScope 检查
+// 权限的作用范围保存到上下文中, 处理函数通过 CanAccessOwner 判断能否操作某个用户的资源
+func Authorize(permission rbac.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+		scope := rbac.ScopeOf(payload.Role, permission)
+		// 使用API密钥时还要求密钥被授予了该权限
+		if granted, ok := ctx.Get(constants.APIKeyPermissionsKey); ok && !slices.Contains(granted.([]rbac.Permission), permission) {
+			scope = rbac.ScopeNone
+		}
+		// 第三方应用只能操作用户自己的资源
+		if payload.ClientID != "" {
+			scope = min(scope, rbac.ScopeOwn)
+		}
+		if scope == rbac.ScopeNone {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(GetLocale(ctx), i18n.MsgPermissionDenied),
+			})
+			return
+		}
+		ctx.Set(constants.PermissionScopeKey, scope)
+		ctx.Next()
+	}
+}
+
+// CanAccessOwner 当前路由的权限是否允许操作owner的资源
+// 作用范围为所有用户时总是允许, 只能操作自己的资源时要求owner为登录的用户
+func CanAccessOwner(ctx *gin.Context, owner string) bool {
+	scope, _ := ctx.Get(constants.PermissionScopeKey)
+	if scope == rbac.ScopeAll {
+		return true
+	}
+	payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+	return scope == rbac.ScopeOwn && owner == payload.Username
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"simple_bank/constants"
+	"simple_bank/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Locale 根据用户的语言偏好(查询参数, cookie)与 Accept-Language 协商请求的语言
+func Locale() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Set(constants.LocaleKey, negotiateLocale(ctx))
+		ctx.Next()
+	}
+}
+
+// GetLocale 获取当前请求协商出的语言, 未经过 Locale 中间件时即时协商
+func GetLocale(ctx *gin.Context) string {
+	if locale, ok := ctx.Get(constants.LocaleKey); ok {
+		if s, ok := locale.(string); ok {
+			return s
+		}
+	}
+	return negotiateLocale(ctx)
+}
+
+func negotiateLocale(ctx *gin.Context) string {
+	preference := ctx.Query(constants.LocaleQueryKey)
+	if preference == "" {
+		preference, _ = ctx.Cookie(constants.LocaleCookieKey)
+	}
+	return i18n.Negotiate(preference, ctx.GetHeader(constants.AcceptLanguageHeaderKey))
+}
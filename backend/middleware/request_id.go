@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"simple_bank/constants"
+)
+
+// 客户端提供的请求ID的最大长度, 过长或包含不可见字符时重新生成
+const maxRequestIDLength = 128
+
+// RequestID 为每个请求分配请求ID, 写入响应头, 审计事件与日志通过请求ID关联同一个请求
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(constants.RequestIDHeaderKey)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		ctx.Set(constants.RequestIDKey, requestID)
+		ctx.Header(constants.RequestIDHeaderKey, requestID)
+		ctx.Next()
+	}
+}
+
+// GetRequestID 获取当前请求的请求ID, 未经过 RequestID 中间件时为空
+func GetRequestID(ctx *gin.Context) string {
+	return ctx.GetString(constants.RequestIDKey)
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range requestID {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
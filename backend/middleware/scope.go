@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"simple_bank/constants"
+	"simple_bank/pkg/i18n"
+	"simple_bank/pkg/token"
+)
+
+// RequireScope 受限令牌的授权范围必须包含scope, 不受限的令牌与API密钥不受影响
+// 按路由组使用, 例如查询接口要求 accounts:read, 转账要求 transfers:write
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+		if !payload.HasScope(scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(GetLocale(ctx), i18n.MsgTokenScopeDenied, scope),
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// FullAccessOnly 拒绝受限令牌, 用于会话, 凭证, 授权管理与后台管理等接口
+// 第三方应用与用户申请的只读令牌都不能访问这些接口
+func FullAccessOnly() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload := ctx.MustGet(constants.AuthorizationPayloadKey).(*token.Payload)
+		if payload.IsScoped() {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(GetLocale(ctx), i18n.MsgPermissionDenied),
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
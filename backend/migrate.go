@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"simple_bank/db/migrate"
+)
+
+const migrateUsage = "usage: migrate up | down N | version | force VERSION"
+
+// runMigrate 执行migrate子命令, 返回进程的退出码: 0 成功, 1 迁移出错, 2 参数错误
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = migrator.Up(ctx)
+	case "down":
+		// 降级会删除数据, 必须指定版本数
+		n, parseErr := parseMigrateArg(args)
+		if parseErr != nil || n <= 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = migrator.Down(ctx, int(n))
+	case "version":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	case "force":
+		// -1 表示清空版本
+		version, parseErr := parseMigrateArg(args)
+		if parseErr != nil || version < migrate.NilVersion {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = migrator.Force(ctx, version)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n%s\n", args[0], migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", args[0], err)
+		return 1
+	}
+
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate version: %v\n", err)
+		return 1
+	}
+	if version == migrate.NilVersion {
+		fmt.Printf("version: none, latest: %d\n", migrator.Latest())
+	} else {
+		fmt.Printf("version: %d, latest: %d, dirty: %t\n", version, migrator.Latest(), dirty)
+	}
+	return 0
+}
+
+func parseMigrateArg(args []string) (int64, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("expected one argument")
+	}
+	return strconv.ParseInt(args[1], 10, 64)
+}
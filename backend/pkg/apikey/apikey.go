@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+const (
+	// 所有密钥共同的开头, 便于在日志与代码仓库中识别泄露的密钥
+	keyPrefix = "sbk_"
+	// 公开部分的随机字节数
+	prefixSize = 4
+	// 密钥部分的随机字节数
+	secretSize = 32
+)
+
+var (
+	ErrInvalidKey = errors.New("api key is invalid")
+	ErrExpiredKey = errors.New("api key has expired")
+)
+
+// Key 新生成的API密钥
+// Plaintext 只在创建时返回给用户一次, 数据库只保存 Prefix 与 Hashed
+type Key struct {
+	Plaintext string
+	Prefix    string
+	Hashed    string
+}
+
+// Generate 生成API密钥, 格式为 sbk_<8位十六进制的公开部分>_<base64url编码的密钥部分>
+func Generate() (Key, error) {
+	buf := make([]byte, prefixSize)
+	if _, err := rand.Read(buf); err != nil {
+		return Key{}, err
+	}
+	prefix := keyPrefix + hex.EncodeToString(buf)
+
+	secret, err := pkg.RandomSecret(secretSize)
+	if err != nil {
+		return Key{}, err
+	}
+	plaintext := prefix + "_" + secret
+	return Key{
+		Plaintext: plaintext,
+		Prefix:    prefix,
+		Hashed:    pkg.HashSecret(plaintext),
+	}, nil
+}
+
+// Principal 通过API密钥认证的调用方
+// Payload 与访问令牌的荷载相同, 角色为用户当前的角色, Permissions 为密钥被授予的权限
+type Principal struct {
+	Payload     *token.Payload
+	Permissions []rbac.Permission
+}
+
+// Verifier 校验API密钥
+type Verifier interface {
+	Verify(ctx context.Context, key string) (*Principal, error)
+}
+
+// PostgresVerifier 按散列值在Postgres中查询API密钥
+// 最近使用时间最多每touchInterval更新一次, 避免每个请求都写数据库
+type PostgresVerifier struct {
+	querier       db.Querier
+	touchInterval time.Duration
+}
+
+func NewPostgresVerifier(querier db.Querier, touchInterval time.Duration) *PostgresVerifier {
+	return &PostgresVerifier{
+		querier:       querier,
+		touchInterval: touchInterval,
+	}
+}
+
+func (v *PostgresVerifier) Verify(ctx context.Context, key string) (*Principal, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, ErrInvalidKey
+	}
+
+	apiKey, err := v.querier.GetAPIKeyByHash(ctx, pkg.HashSecret(key))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && !now.Before(*apiKey.ExpiresAt) {
+		return nil, ErrExpiredKey
+	}
+
+	// 角色以用户当前的角色为准, 角色被降级之后密钥超出的权限随之失效
+	user, err := v.querier.GetUser(ctx, apiKey.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.querier.TouchAPIKey(ctx, db.TouchAPIKeyParams{
+		ID:            apiKey.ID,
+		TouchedBefore: now.Add(-v.touchInterval),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &token.Payload{
+		ID:       apiKey.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(apiKey.CreatedAt),
+			ID:       apiKey.ID.String(),
+		},
+	}
+	if apiKey.ExpiresAt != nil {
+		payload.ExpiresAt = jwt.NewNumericDate(*apiKey.ExpiresAt)
+	}
+
+	permissions := make([]rbac.Permission, 0, len(apiKey.Permissions))
+	for _, permission := range apiKey.Permissions {
+		permissions = append(permissions, rbac.Permission(permission))
+	}
+	return &Principal{Payload: payload, Permissions: permissions}, nil
+}
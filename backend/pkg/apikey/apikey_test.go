@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+)
+
+func TestGenerate(t *testing.T) {
+	key, err := Generate()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key.Plaintext, key.Prefix+"_"))
+	require.True(t, strings.HasPrefix(key.Prefix, keyPrefix))
+	require.Len(t, key.Prefix, len(keyPrefix)+2*prefixSize)
+	require.Equal(t, pkg.HashSecret(key.Plaintext), key.Hashed)
+
+	other, err := Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, key.Plaintext, other.Plaintext)
+}
+
+func TestVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	verifier := NewPostgresVerifier(querier, time.Minute)
+
+	key, err := Generate()
+	require.NoError(t, err)
+	apiKey := db.ApiKeys{
+		ID:          uuid.New(),
+		Username:    "alice",
+		Prefix:      key.Prefix,
+		HashedKey:   key.Hashed,
+		Permissions: []string{string(rbac.AccountsRead)},
+		CreatedAt:   time.Now(),
+	}
+
+	querier.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Eq(key.Hashed)).Times(1).Return(apiKey, nil)
+	querier.EXPECT().GetUser(gomock.Any(), gomock.Eq("alice")).Times(1).Return(db.Users{Username: "alice", Role: rbac.RoleTeller}, nil)
+	querier.EXPECT().
+		TouchAPIKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.TouchAPIKeyParams) error {
+			require.Equal(t, apiKey.ID, arg.ID)
+			require.WithinDuration(t, time.Now().Add(-time.Minute), arg.TouchedBefore, time.Second)
+			return nil
+		})
+
+	principal, err := verifier.Verify(context.Background(), key.Plaintext)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, principal.Payload.ID)
+	require.Equal(t, "alice", principal.Payload.Username)
+	// 角色以用户当前的角色为准
+	require.Equal(t, rbac.RoleTeller, principal.Payload.Role)
+	require.Equal(t, []rbac.Permission{rbac.AccountsRead}, principal.Permissions)
+}
+
+func TestVerifyInvalidKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	verifier := NewPostgresVerifier(querier, time.Minute)
+
+	// 格式不对的密钥不查询数据库
+	_, err := verifier.Verify(context.Background(), "not-a-key")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	key, err := Generate()
+	require.NoError(t, err)
+	querier.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKeys{}, sql.ErrNoRows)
+	_, err = verifier.Verify(context.Background(), key.Plaintext)
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	expiresAt := time.Now().Add(-time.Second)
+	querier.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(db.ApiKeys{ExpiresAt: &expiresAt}, nil)
+	_, err = verifier.Verify(context.Background(), key.Plaintext)
+	require.ErrorIs(t, err, ErrExpiredKey)
+}
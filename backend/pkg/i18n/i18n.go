@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+
+	// DefaultLocale 无法协商出语言时使用的默认语言
+	DefaultLocale = ZhCN
+)
+
+// 服务器支持的语言, 顺序与matcher的下标一一对应
+var (
+	supportedLocales = []string{ZhCN, EnUS}
+	matcher          = language.NewMatcher([]language.Tag{
+		language.SimplifiedChinese,
+		language.AmericanEnglish,
+	})
+)
+
+// 消息目录, key为语言, value为该语言的消息集合
+var catalog = map[string]map[string]string{
+	ZhCN: zhCN,
+	EnUS: enUS,
+}
+
+// Negotiate 根据用户偏好与Accept-Language协商出服务器支持的语言
+// preference 为用户显式指定的语言(例如 lang 查询参数或 cookie), 优先级高于 Accept-Language
+func Negotiate(preference string, acceptLanguage string) string {
+	if preference != "" {
+		if tag, err := language.Parse(preference); err == nil {
+			if _, index, confidence := matcher.Match(tag); confidence != language.No {
+				return supportedLocales[index]
+			}
+		}
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return supportedLocales[index]
+}
+
+// T 翻译消息, 找不到该语言时回退到默认语言, 找不到该消息时返回key本身
+func T(locale string, key string, args ...any) string {
+	messages, ok := catalog[locale]
+	if !ok {
+		messages = catalog[DefaultLocale]
+	}
+	message, ok := messages[key]
+	if !ok {
+		message, ok = catalog[DefaultLocale][key]
+		if !ok {
+			return key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
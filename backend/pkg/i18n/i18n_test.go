@@ -0,0 +1,48 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		preference     string
+		acceptLanguage string
+		expected       string
+	}{
+		{name: "默认语言", expected: DefaultLocale},
+		{name: "英文", acceptLanguage: "en-GB,en;q=0.9", expected: EnUS},
+		{name: "中文", acceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8", expected: ZhCN},
+		{name: "按权重协商", acceptLanguage: "fr;q=0.9,en;q=0.8,zh;q=0.1", expected: EnUS},
+		{name: "不支持的语言", acceptLanguage: "fr-FR", expected: DefaultLocale},
+		{name: "用户偏好优先", preference: "en", acceptLanguage: "zh-CN", expected: EnUS},
+		{name: "无效的用户偏好", preference: "!!", acceptLanguage: "en-US", expected: EnUS},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Negotiate(tc.preference, tc.acceptLanguage))
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	require.Equal(t, "密码错误", T(ZhCN, MsgPasswordIncorrect))
+	require.Equal(t, "incorrect password", T(EnUS, MsgPasswordIncorrect))
+	// 不支持的语言回退到默认语言
+	require.Equal(t, T(DefaultLocale, MsgPasswordIncorrect), T("fr-FR", MsgPasswordIncorrect))
+	// 不存在的key返回key本身
+	require.Equal(t, "unknown.key", T(EnUS, "unknown.key"))
+	require.Equal(t, "account '1' currency mismatch: 'USD' vs 'CNY'", T(EnUS, MsgCurrencyMismatchError, 1, "USD", "CNY"))
+
+	// 所有语言的消息目录都应包含相同的key
+	for key := range catalog[DefaultLocale] {
+		for locale, messages := range catalog {
+			require.Contains(t, messages, key, "locale %s", locale)
+		}
+	}
+}
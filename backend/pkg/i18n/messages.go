@@ -0,0 +1,203 @@
+package i18n
+
+// 消息的key, 与目录中的各个语言的消息一一对应
+const (
+	MsgAuthHeaderMissing     = "auth.header_missing"
+	MsgAuthHeaderInvalid     = "auth.header_invalid"
+	MsgAuthTypeUnsupported   = "auth.type_unsupported"
+	MsgTokenExpired          = "token.expired"
+	MsgTokenInvalid          = "token.invalid"
+	MsgTokenRevoked          = "token.revoked"
+	MsgUsernameExists        = "user.username_exists"
+	MsgPasswordIncorrect     = "user.password_incorrect"
+	MsgAccountNotOwned       = "account.not_owned"
+	MsgTransferNotOwner      = "transfer.not_owner"
+	MsgCurrencyMismatch      = "transfer.currency_mismatch"
+	MsgCurrencyMismatchError = "transfer.currency_mismatch_error"
+	MsgSessionNotFound       = "session.not_found"
+	MsgSessionBlocked        = "session.blocked"
+	MsgSessionExpired        = "session.expired"
+	MsgSessionMismatched     = "session.mismatched"
+	MsgSessionReused         = "session.reused"
+	MsgMfaChallengeInvalid   = "mfa.challenge_invalid"
+	MsgMfaCodeInvalid        = "mfa.code_invalid"
+	MsgTotpAlreadyEnabled    = "totp.already_enabled"
+	MsgTotpNotEnrolled       = "totp.not_enrolled"
+	MsgPasswordResetSent     = "password_reset.sent"
+	MsgPasswordResetInvalid  = "password_reset.invalid"
+	MsgPasswordResetSubject  = "password_reset.subject"
+	MsgPasswordResetBody     = "password_reset.body"
+	MsgEmailVerifySubject    = "email_verify.subject"
+	MsgEmailVerifyBody       = "email_verify.body"
+	MsgEmailVerifyInvalid    = "email_verify.invalid"
+	MsgEmailVerifySent       = "email_verify.sent"
+	MsgEmailNotVerified      = "email_verify.not_verified"
+	MsgEmailAlreadyVerified  = "email_verify.already_verified"
+	MsgInvalidCredentials    = "user.invalid_credentials"
+	MsgLoginThrottled        = "login.throttled"
+	MsgPermissionDenied      = "auth.permission_denied"
+	MsgAccountFrozen         = "account.frozen"
+	MsgNegativeBalance       = "account.negative_balance"
+	MsgAPIKeyInvalid         = "apikey.invalid"
+	MsgAPIKeyExpired         = "apikey.expired"
+	MsgAPIKeyNotGranted      = "apikey.not_granted"
+	MsgOAuthClientInvalid    = "oauth.client_invalid"
+	MsgOAuthRedirectInvalid  = "oauth.redirect_invalid"
+	MsgOAuthScopeInvalid     = "oauth.scope_invalid"
+	MsgOAuthGrantInvalid     = "oauth.grant_invalid"
+	MsgTokenScopeDenied      = "token.scope_insufficient"
+	MsgTokenAudienceInvalid  = "token.audience_invalid"
+	MsgStepUpRequired        = "transfer.step_up_required"
+	MsgStepUpInvalid         = "step_up.invalid"
+	MsgPasswordTooShort      = "password.too_short"
+	MsgPasswordTooLong       = "password.too_long"
+	MsgPasswordClasses       = "password.classes"
+	MsgPasswordSimilar       = "password.similar"
+	MsgPasswordWeak          = "password.weak"
+	MsgPasswordBreached      = "password.breached"
+	MsgProfileNoChanges      = "profile.no_changes"
+	MsgEmailTaken            = "email.taken"
+	MsgEmailChangeSent       = "email_change.sent"
+	MsgEmailChangeSubject    = "email_change.subject"
+	MsgEmailChangeBody       = "email_change.body"
+	MsgAccountNotEmpty       = "account.not_empty"
+	MsgAccountClosed         = "account.closed"
+	MsgExportFormatInvalid   = "export.format_invalid"
+	MsgUserDeleted           = "user.deleted"
+	MsgUserDeletedSubject    = "user_deleted.subject"
+	MsgUserDeletedBody       = "user_deleted.body"
+)
+
+var zhCN = map[string]string{
+	MsgAuthHeaderMissing:     "未提供 authorization 标头",
+	MsgAuthHeaderInvalid:     "授权标头格式无效",
+	MsgAuthTypeUnsupported:   "服务器不支持的授权类型",
+	MsgTokenExpired:          "令牌已过期",
+	MsgTokenInvalid:          "令牌无效",
+	MsgTokenRevoked:          "令牌已被撤销",
+	MsgUsernameExists:        "用户名已存在",
+	MsgPasswordIncorrect:     "密码错误",
+	MsgAccountNotOwned:       "该账户不属于该用户",
+	MsgTransferNotOwner:      "登录的用户非该账户的拥有者",
+	MsgCurrencyMismatch:      "转账时请使用相同的货币类型",
+	MsgCurrencyMismatchError: "用户ID'%d' 的货币类型不匹配: '%s' vs '%s'",
+	MsgSessionNotFound:       "会话不存在",
+	MsgSessionBlocked:        "会话已被禁用",
+	MsgSessionExpired:        "会话已过期",
+	MsgSessionMismatched:     "刷新令牌与会话不匹配",
+	MsgSessionReused:         "刷新令牌已被使用, 该登录的所有会话已被禁用",
+	MsgMfaChallengeInvalid:   "两步验证已过期或无效, 请重新登录",
+	MsgMfaCodeInvalid:        "验证码错误",
+	MsgTotpAlreadyEnabled:    "已开启两步验证",
+	MsgTotpNotEnrolled:       "未绑定两步验证",
+	MsgPasswordResetSent:     "如果该邮箱已注册, 重置密码的邮件已发送",
+	MsgPasswordResetInvalid:  "重置密码的链接无效或已过期",
+	MsgPasswordResetSubject:  "重置密码",
+	MsgPasswordResetBody:     "%s 你好,\n\n请在 %d 分钟内打开以下链接重置密码:\n%s\n\n如果这不是你本人的操作, 请忽略这封邮件。",
+	MsgEmailVerifySubject:    "验证邮箱",
+	MsgEmailVerifyBody:       "%s 你好,\n\n请在 %s 之前打开以下链接验证你的邮箱:\n%s\n\n如果这不是你本人的操作, 请忽略这封邮件。",
+	MsgEmailVerifyInvalid:    "验证邮箱的链接无效或已过期",
+	MsgEmailVerifySent:       "验证邮件已发送",
+	MsgEmailNotVerified:      "请先验证邮箱",
+	MsgEmailAlreadyVerified:  "邮箱已验证",
+	MsgInvalidCredentials:    "用户名或密码错误",
+	MsgLoginThrottled:        "登录失败次数过多, 请在 %d 秒之后重试",
+	MsgPermissionDenied:      "没有执行该操作的权限",
+	MsgAccountFrozen:         "账户'%d'已被冻结",
+	MsgNegativeBalance:       "调整之后账户的余额不能为负数",
+	MsgAPIKeyInvalid:         "API密钥无效",
+	MsgAPIKeyExpired:         "API密钥已过期",
+	MsgAPIKeyNotGranted:      "当前角色没有权限'%s', 不能授予API密钥",
+	MsgOAuthClientInvalid:    "第三方应用不存在或认证失败",
+	MsgOAuthRedirectInvalid:  "回调地址未在应用中注册",
+	MsgOAuthScopeInvalid:     "申请的授权范围无效或超出应用的范围",
+	MsgOAuthGrantInvalid:     "授权码或刷新令牌无效, 已过期或已被使用",
+	MsgTokenScopeDenied:      "令牌的授权范围不足, 需要'%s'",
+	MsgTokenAudienceInvalid:  "令牌不是颁发给本服务的",
+	MsgStepUpRequired:        "转账金额超过%d %s, 需要重新验证身份",
+	MsgStepUpInvalid:         "加强验证令牌无效或已过期, 请重新验证身份",
+	MsgPasswordTooShort:      "密码长度不能少于%d个字符",
+	MsgPasswordTooLong:       "密码长度不能超过%d个字符",
+	MsgPasswordClasses:       "密码至少需要包含以下%d类字符: 小写字母, 大写字母, 数字, 符号",
+	MsgPasswordSimilar:       "密码不能包含用户名, 邮箱或姓名",
+	MsgPasswordWeak:          "密码太容易被猜到, 请避免常见单词, 年份, 重复与键盘序列的字符",
+	MsgPasswordBreached:      "该密码出现在已泄露的密码中, 请换一个密码",
+	MsgProfileNoChanges:      "没有需要修改的内容",
+	MsgEmailTaken:            "该邮箱已被使用",
+	MsgEmailChangeSent:       "验证邮件已发送到新邮箱, 验证之后邮箱才会修改",
+	MsgEmailChangeSubject:    "邮箱修改申请",
+	MsgEmailChangeBody:       "%s 你好,\n\n你的账户申请将邮箱修改为 %s, 验证新邮箱之后生效。\n\n如果这不是你本人的操作, 请立即修改密码。",
+	MsgAccountNotEmpty:       "请先转出所有账户的余额",
+	MsgAccountClosed:         "账户'%d'已关闭",
+	MsgExportFormatInvalid:   "导出格式只能是 json 或 csv",
+	MsgUserDeleted:           "用户已删除",
+	MsgUserDeletedSubject:    "账户已删除",
+	MsgUserDeletedBody:       "%s 你好,\n\n你的用户已于 %s 删除, 账户已关闭, 个人信息已清除。交易记录按法规要求保留, 不再与你的个人信息关联。",
+}
+
+var enUS = map[string]string{
+	MsgAuthHeaderMissing:     "authorization header is not provided",
+	MsgAuthHeaderInvalid:     "invalid authorization header format",
+	MsgAuthTypeUnsupported:   "unsupported authorization type",
+	MsgTokenExpired:          "token has expired",
+	MsgTokenInvalid:          "token is invalid",
+	MsgTokenRevoked:          "token has been revoked",
+	MsgUsernameExists:        "username already exists",
+	MsgPasswordIncorrect:     "incorrect password",
+	MsgAccountNotOwned:       "account doesn't belong to the authenticated user",
+	MsgTransferNotOwner:      "the authenticated user is not the owner of the account",
+	MsgCurrencyMismatch:      "please use the same currency for transfers",
+	MsgCurrencyMismatchError: "account '%d' currency mismatch: '%s' vs '%s'",
+	MsgSessionNotFound:       "session not found",
+	MsgSessionBlocked:        "session is blocked",
+	MsgSessionExpired:        "session has expired",
+	MsgSessionMismatched:     "refresh token doesn't match the session",
+	MsgSessionReused:         "refresh token has already been used, all sessions of this login have been blocked",
+	MsgMfaChallengeInvalid:   "two-factor challenge is invalid or has expired, please log in again",
+	MsgMfaCodeInvalid:        "invalid verification code",
+	MsgTotpAlreadyEnabled:    "two-factor authentication is already enabled",
+	MsgTotpNotEnrolled:       "two-factor authentication is not enrolled",
+	MsgPasswordResetSent:     "if the email is registered, a password reset email has been sent",
+	MsgPasswordResetInvalid:  "password reset link is invalid or has expired",
+	MsgPasswordResetSubject:  "Reset your password",
+	MsgPasswordResetBody:     "Hi %s,\n\nOpen the following link within %d minutes to reset your password:\n%s\n\nIf you did not request this, please ignore this email.",
+	MsgEmailVerifySubject:    "Verify your email",
+	MsgEmailVerifyBody:       "Hi %s,\n\nOpen the following link before %s to verify your email:\n%s\n\nIf you did not request this, please ignore this email.",
+	MsgEmailVerifyInvalid:    "email verification link is invalid or has expired",
+	MsgEmailVerifySent:       "verification email has been sent",
+	MsgEmailNotVerified:      "please verify your email first",
+	MsgEmailAlreadyVerified:  "email is already verified",
+	MsgInvalidCredentials:    "invalid username or password",
+	MsgLoginThrottled:        "too many failed login attempts, please try again in %d seconds",
+	MsgPermissionDenied:      "permission denied",
+	MsgAccountFrozen:         "account '%d' is frozen",
+	MsgNegativeBalance:       "account balance cannot be negative after the adjustment",
+	MsgAPIKeyInvalid:         "api key is invalid",
+	MsgAPIKeyExpired:         "api key has expired",
+	MsgAPIKeyNotGranted:      "permission '%s' is not granted to your role and cannot be given to an api key",
+	MsgOAuthClientInvalid:    "oauth client is unknown or authentication failed",
+	MsgOAuthRedirectInvalid:  "redirect uri is not registered for the client",
+	MsgOAuthScopeInvalid:     "requested scope is invalid or exceeds the client's scopes",
+	MsgOAuthGrantInvalid:     "authorization code or refresh token is invalid, expired or already used",
+	MsgTokenScopeDenied:      "token scope is insufficient, '%s' is required",
+	MsgTokenAudienceInvalid:  "token was not issued for this service",
+	MsgStepUpRequired:        "transfers over %d %s require re-authentication",
+	MsgStepUpInvalid:         "step-up token is invalid or expired, please re-authenticate",
+	MsgPasswordTooShort:      "password must be at least %d characters long",
+	MsgPasswordTooLong:       "password must be at most %d characters long",
+	MsgPasswordClasses:       "password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+	MsgPasswordSimilar:       "password must not contain your username, email or name",
+	MsgPasswordWeak:          "password is too easy to guess, avoid common words, years, repeated characters and keyboard sequences",
+	MsgPasswordBreached:      "this password has appeared in a data breach, please choose another one",
+	MsgProfileNoChanges:      "nothing to update",
+	MsgEmailTaken:            "email is already in use",
+	MsgEmailChangeSent:       "a verification email has been sent to the new address, the email will change after it is verified",
+	MsgEmailChangeSubject:    "Email change requested",
+	MsgEmailChangeBody:       "Hi %s,\n\nA request was made to change the email of your account to %s. The change takes effect after the new address is verified.\n\nIf you did not request this, please change your password immediately.",
+	MsgAccountNotEmpty:       "please transfer out the balance of all accounts first",
+	MsgAccountClosed:         "account '%d' is closed",
+	MsgExportFormatInvalid:   "export format must be json or csv",
+	MsgUserDeleted:           "user has been deleted",
+	MsgUserDeletedSubject:    "Your account has been deleted",
+	MsgUserDeletedBody:       "Hi %s,\n\nYour user was deleted at %s. Your accounts have been closed and your personal information has been erased. Transaction records are retained as required by regulations and are no longer linked to your personal information.",
+}
@@ -0,0 +1,190 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "simple_bank/db/sqlc"
+)
+
+// Policy 登录失败的处理策略
+// 连续失败超过FreeFailures次之后, 每次失败都需要等待一段时间才能再次尝试, 等待时间从BaseDelay开始翻倍, 最长为MaxDelay
+// 连续失败达到MaxFailures次时锁定LockoutDuration, Window之内没有再失败则重新计数
+// MaxFailures与BaseDelay都为0时不做任何限制
+type Policy struct {
+	FreeFailures    int
+	MaxFailures     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+func (p Policy) disabled() bool {
+	return p.MaxFailures <= 0 && p.BaseDelay <= 0
+}
+
+// Wait 根据失败记录计算距离下一次允许尝试还需要等待的时间, 为0则允许尝试
+func (p Policy) Wait(failure db.LoginFailures, now time.Time) time.Duration {
+	if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+		return failure.LockedUntil.Sub(now)
+	}
+	if p.BaseDelay <= 0 || now.Sub(failure.LastFailedAt) >= p.Window {
+		return 0
+	}
+
+	excess := int(failure.FailedCount) - p.FreeFailures
+	if excess <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < excess && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if next := failure.LastFailedAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// UserSubject 按用户名统计的失败记录的标识
+func UserSubject(username string) string {
+	return "user:" + username
+}
+
+// IPSubject 按客户端IP统计的失败记录的标识
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// Guard 以Postgres记录登录失败的次数, 多个实例共享同一份记录
+// 用户名与客户端IP分别使用不同的策略, 同一IP后面可能有多个用户, 一般应设置更宽松的限制
+type Guard struct {
+	querier db.Querier
+	user    Policy
+	ip      Policy
+}
+
+func NewGuard(querier db.Querier, user Policy, ip Policy) *Guard {
+	return &Guard{
+		querier: querier,
+		user:    user,
+		ip:      ip,
+	}
+}
+
+// WithQuerier 返回使用 querier 读写失败记录的副本, 用于在事务中解除锁定
+func (g *Guard) WithQuerier(querier db.Querier) *Guard {
+	guard := *g
+	guard.querier = querier
+	return &guard
+}
+
+// 并发的尝试修改了同一条失败记录时重新读取的次数
+const maxReserveRetries = 5
+
+// contendedWait 重新读取之后仍然无法记录本次尝试时要求等待的时间
+const contendedWait = time.Second
+
+// Reserve 在校验密码之前先将本次尝试记为一次失败, 返回还需要等待多久才能再次尝试, 大于0时没有记录
+// 读取失败记录与增加次数之间以条件更新保证原子性, 并发的尝试依次计数, 不会在次数达到上限之前同时通过检查
+// 校验通过之后调用 Succeed 撤销本次记录
+func (g *Guard) Reserve(ctx context.Context, username string, ip string) (time.Duration, error) {
+	userSubject := UserSubject(username)
+	wait, err := g.reserve(ctx, g.user, userSubject)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	wait, err = g.reserve(ctx, g.ip, IPSubject(ip))
+	if err != nil || wait > 0 {
+		// 本次尝试被拒绝, 不计入用户名的失败次数
+		return wait, errors.Join(err, g.refund(ctx, g.user, userSubject))
+	}
+	return 0, nil
+}
+
+func (g *Guard) reserve(ctx context.Context, policy Policy, subject string) (time.Duration, error) {
+	if policy.disabled() {
+		return 0, nil
+	}
+
+	for range maxReserveRetries {
+		// 没有失败记录时以零值作为读取到的记录
+		failure, err := g.querier.GetLoginFailure(ctx, subject)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		now := time.Now()
+		if wait := policy.Wait(failure, now); wait > 0 {
+			return wait, nil
+		}
+
+		reserved, err := g.querier.ReserveLoginAttempt(ctx, db.ReserveLoginAttemptParams{
+			Subject:      subject,
+			WindowStart:  now.Add(-policy.Window),
+			FailedCount:  failure.FailedCount,
+			LastFailedAt: failure.LastFailedAt,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// 读取之后记录已被并发的尝试修改
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if policy.MaxFailures <= 0 || int(reserved.FailedCount) < policy.MaxFailures {
+			return 0, nil
+		}
+		lockedUntil := reserved.LastFailedAt.Add(policy.LockoutDuration)
+		return 0, g.querier.LockLogin(ctx, db.LockLoginParams{
+			Subject:     subject,
+			LockedUntil: &lockedUntil,
+		})
+	}
+	return contendedWait, nil
+}
+
+func (g *Guard) refund(ctx context.Context, policy Policy, subject string) error {
+	if policy.disabled() {
+		return nil
+	}
+	return g.querier.RefundLoginAttempt(ctx, db.RefundLoginAttemptParams{
+		MaxFailures: int32(policy.MaxFailures),
+		Subject:     subject,
+	})
+}
+
+// Succeed 校验通过之后清除该用户名的失败记录, 并撤销客户端IP预先记录的本次失败
+// 客户端IP的其它失败记录保留到过期为止, 避免使用自己的账户登录来重置对其它账户的猜测次数
+func (g *Guard) Succeed(ctx context.Context, username string, ip string) error {
+	if !g.user.disabled() {
+		if err := g.querier.ResetLoginFailures(ctx, UserSubject(username)); err != nil {
+			return err
+		}
+	}
+	return g.refund(ctx, g.ip, IPSubject(ip))
+}
+
+// SucceedFirstFactor 密码校验通过但还需要校验第二步时, 只撤销客户端IP预先记录的本次失败
+// 用户名预先记录的失败保留到第二步校验通过之后由 Succeed 清除
+func (g *Guard) SucceedFirstFactor(ctx context.Context, ip string) error {
+	return g.refund(ctx, g.ip, IPSubject(ip))
+}
+
+// Unlock 清除失败记录并解除锁定, subject 为 UserSubject 或 IPSubject 的返回值
+func (g *Guard) Unlock(ctx context.Context, subject string) error {
+	return g.querier.ResetLoginFailures(ctx, subject)
+}
+
+// Purge 清理已超出统计窗口且未锁定的失败记录
+func (g *Guard) Purge(ctx context.Context) error {
+	window := max(g.user.Window, g.ip.Window)
+	return g.querier.DeleteStaleLoginFailures(ctx, time.Now().Add(-window))
+}
@@ -0,0 +1,224 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+)
+
+var testPolicy = Policy{
+	FreeFailures:    3,
+	MaxFailures:     10,
+	BaseDelay:       time.Second,
+	MaxDelay:        8 * time.Second,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+func TestPolicyWait(t *testing.T) {
+	now := time.Now()
+	lockedUntil := now.Add(10 * time.Minute)
+	expiredLock := now.Add(-time.Minute)
+
+	testCases := []struct {
+		name    string
+		failure db.LoginFailures
+		wait    time.Duration
+	}{
+		{
+			name:    "未超过免延迟次数",
+			failure: db.LoginFailures{FailedCount: 3, LastFailedAt: now},
+			wait:    0,
+		},
+		{
+			name:    "第一次延迟",
+			failure: db.LoginFailures{FailedCount: 4, LastFailedAt: now},
+			wait:    time.Second,
+		},
+		{
+			name:    "延迟翻倍",
+			failure: db.LoginFailures{FailedCount: 6, LastFailedAt: now},
+			wait:    4 * time.Second,
+		},
+		{
+			name:    "延迟不超过上限",
+			failure: db.LoginFailures{FailedCount: 9, LastFailedAt: now},
+			wait:    8 * time.Second,
+		},
+		{
+			name:    "延迟已经过去",
+			failure: db.LoginFailures{FailedCount: 4, LastFailedAt: now.Add(-2 * time.Second)},
+			wait:    0,
+		},
+		{
+			name:    "超出统计窗口",
+			failure: db.LoginFailures{FailedCount: 9, LastFailedAt: now.Add(-time.Hour)},
+			wait:    0,
+		},
+		{
+			name:    "已锁定",
+			failure: db.LoginFailures{FailedCount: 10, LastFailedAt: now, LockedUntil: &lockedUntil},
+			wait:    10 * time.Minute,
+		},
+		{
+			name:    "锁定已过期",
+			failure: db.LoginFailures{FailedCount: 10, LastFailedAt: now.Add(-time.Hour), LockedUntil: &expiredLock},
+			wait:    0,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.wait, testPolicy.Wait(tc.failure, now))
+		})
+	}
+}
+
+func TestGuardReserveLocksAtMaxFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(querier, testPolicy, Policy{})
+	now := time.Now()
+	last := db.LoginFailures{Subject: UserSubject("alice"), FailedCount: 9, LastFailedAt: now.Add(-time.Minute)}
+
+	// 客户端IP的策略未开启, 只记录用户名的尝试
+	querier.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Eq(UserSubject("alice"))).
+		Times(1).
+		Return(last, nil)
+	querier.EXPECT().
+		ReserveLoginAttempt(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.ReserveLoginAttemptParams) (db.LoginFailures, error) {
+			require.Equal(t, UserSubject("alice"), arg.Subject)
+			// 以读取到的记录作为更新的条件
+			require.Equal(t, last.FailedCount, arg.FailedCount)
+			require.Equal(t, last.LastFailedAt, arg.LastFailedAt)
+			return db.LoginFailures{Subject: arg.Subject, FailedCount: 10, LastFailedAt: now}, nil
+		})
+	querier.EXPECT().
+		LockLogin(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.LockLoginParams) error {
+			require.Equal(t, UserSubject("alice"), arg.Subject)
+			require.Equal(t, now.Add(testPolicy.LockoutDuration), *arg.LockedUntil)
+			return nil
+		})
+
+	wait, err := guard.Reserve(context.Background(), "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+}
+
+func TestGuardReserveRetriesConcurrentUpdate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(querier, testPolicy, Policy{})
+	now := time.Now()
+
+	// 第一次读取之后并发的尝试已经增加了次数, 条件更新没有修改任何记录
+	// 重新读取时已超过免延迟次数, 需要等待
+	gomock.InOrder(
+		querier.EXPECT().
+			GetLoginFailure(gomock.Any(), gomock.Any()).
+			Return(db.LoginFailures{FailedCount: 3, LastFailedAt: now.Add(-time.Minute)}, nil),
+		querier.EXPECT().
+			ReserveLoginAttempt(gomock.Any(), gomock.Any()).
+			Return(db.LoginFailures{}, sql.ErrNoRows),
+		querier.EXPECT().
+			GetLoginFailure(gomock.Any(), gomock.Any()).
+			Return(db.LoginFailures{FailedCount: 4, LastFailedAt: now}, nil),
+	)
+
+	wait, err := guard.Reserve(context.Background(), "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.InDelta(t, time.Second, wait, float64(100*time.Millisecond))
+}
+
+func TestGuardReserveRefundsRejectedAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(querier, testPolicy, testPolicy)
+	lockedUntil := time.Now().Add(time.Minute)
+
+	// 用户名没有失败记录, 客户端IP已被锁定
+	querier.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Eq(UserSubject("alice"))).
+		Times(1).
+		Return(db.LoginFailures{}, sql.ErrNoRows)
+	querier.EXPECT().
+		ReserveLoginAttempt(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.ReserveLoginAttemptParams) (db.LoginFailures, error) {
+			require.Equal(t, UserSubject("alice"), arg.Subject)
+			require.Zero(t, arg.FailedCount)
+			return db.LoginFailures{Subject: arg.Subject, FailedCount: 1, LastFailedAt: time.Now()}, nil
+		})
+	querier.EXPECT().
+		GetLoginFailure(gomock.Any(), gomock.Eq(IPSubject("10.0.0.1"))).
+		Times(1).
+		Return(db.LoginFailures{FailedCount: 10, LastFailedAt: time.Now(), LockedUntil: &lockedUntil}, nil)
+	// 被拒绝的尝试不计入用户名的失败次数
+	querier.EXPECT().
+		RefundLoginAttempt(gomock.Any(), gomock.Eq(db.RefundLoginAttemptParams{
+			MaxFailures: int32(testPolicy.MaxFailures),
+			Subject:     UserSubject("alice"),
+		})).
+		Times(1).
+		Return(nil)
+
+	wait, err := guard.Reserve(context.Background(), "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.InDelta(t, time.Minute, wait, float64(time.Second))
+}
+
+func TestGuardSucceed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(querier, testPolicy, testPolicy)
+
+	// 清除用户名的失败记录, 客户端IP只撤销本次尝试
+	querier.EXPECT().
+		ResetLoginFailures(gomock.Any(), gomock.Eq(UserSubject("alice"))).
+		Times(1).
+		Return(nil)
+	querier.EXPECT().
+		RefundLoginAttempt(gomock.Any(), gomock.Eq(db.RefundLoginAttemptParams{
+			MaxFailures: int32(testPolicy.MaxFailures),
+			Subject:     IPSubject("10.0.0.1"),
+		})).
+		Times(1).
+		Return(nil)
+
+	require.NoError(t, guard.Succeed(context.Background(), "alice", "10.0.0.1"))
+}
+
+func TestDisabledGuard(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// 未开启时不访问数据库
+	querier := mockdb.NewMockStore(ctrl)
+	guard := NewGuard(querier, Policy{}, Policy{})
+
+	wait, err := guard.Reserve(context.Background(), "alice", "10.0.0.1")
+	require.NoError(t, err)
+	require.Zero(t, wait)
+	require.NoError(t, guard.Succeed(context.Background(), "alice", "10.0.0.1"))
+}
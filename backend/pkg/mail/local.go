@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogMailer 将邮件的收件人与主题输出到日志, 用于本地开发
+// 正文中有重置密码与验证邮箱的令牌, 不输出到日志, 需要查看正文时使用 FileMailer
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("mail from %s to %v: %q (body omitted)", m.from, msg.To, msg.Subject)
+	return nil
+}
+
+// FileMailer 将每封邮件写入目录中的一个 .eml 文件, 用于本地开发与测试
+type FileMailer struct {
+	from string
+	dir  string
+}
+
+func NewFileMailer(from string, dir string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file mailer requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail directory: %w", err)
+	}
+	return &FileMailer{from: from, dir: dir}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), uuid.NewString())
+	return os.WriteFile(filepath.Join(m.dir, name), encode(m.from, msg, now), 0o600)
+}
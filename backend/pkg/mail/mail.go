@@ -0,0 +1,70 @@
+// Package mail 发送邮件的抽象, 生产环境使用SMTP, 本地开发时写入文件或日志
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// 支持的发送方式
+const (
+	TypeLog  = "log"
+	TypeFile = "file"
+	TypeSMTP = "smtp"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config 创建Mailer所需的配置
+type Config struct {
+	Type     string
+	From     string
+	Dir      string // file: 邮件写入的目录
+	Host     string // smtp
+	Port     int
+	Username string
+	Password string
+}
+
+// NewMailer 根据发送方式创建Mailer, 未指定时输出到日志
+func NewMailer(cfg Config) (Mailer, error) {
+	switch cfg.Type {
+	case "", TypeLog:
+		return NewLogMailer(cfg.From), nil
+	case TypeFile:
+		return NewFileMailer(cfg.From, cfg.Dir)
+	case TypeSMTP:
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From)
+	default:
+		return nil, fmt.Errorf("unsupported mailer type %s", cfg.Type)
+	}
+}
+
+// encode 将邮件编码为RFC 5322格式, 主题使用RFC 2047编码以支持中文
+func encode(from string, msg Message, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
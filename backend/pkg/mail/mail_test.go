@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	msg := Message{
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "重置密码",
+		Body:    "line1\nline2",
+	}
+	data := string(encode("bank@example.com", msg, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	require.Contains(t, data, "From: bank@example.com\r\n")
+	require.Contains(t, data, "To: alice@example.com, bob@example.com\r\n")
+	require.Contains(t, data, "Subject: =?utf-8?q?")
+	require.Contains(t, data, "Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n")
+	require.True(t, strings.HasSuffix(data, "\r\n\r\nline1\r\nline2"))
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewMailer(Config{Type: TypeFile, From: "bank@example.com", Dir: dir})
+	require.NoError(t, err)
+
+	err = mailer.Send(context.Background(), Message{To: []string{"alice@example.com"}, Subject: "hi", Body: "hello"})
+	require.NoError(t, err)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "To: alice@example.com\r\n")
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	mailer := NewLogMailer("bank@example.com")
+	err := mailer.Send(context.Background(), Message{
+		To:      []string{"alice@example.com"},
+		Subject: "reset password",
+		Body:    "http://localhost:3000/reset-password?token=secret-token",
+	})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "alice@example.com")
+	require.Contains(t, buf.String(), "reset password")
+	// 正文中的令牌不能出现在日志中
+	require.NotContains(t, buf.String(), "secret-token")
+}
+
+func TestNewMailer(t *testing.T) {
+	mailer, err := NewMailer(Config{})
+	require.NoError(t, err)
+	require.IsType(t, &LogMailer{}, mailer)
+
+	mailer, err = NewMailer(Config{Type: TypeSMTP, Host: "localhost", Port: 25, From: "bank@example.com"})
+	require.NoError(t, err)
+	require.IsType(t, &SMTPMailer{}, mailer)
+
+	_, err = NewMailer(Config{Type: TypeSMTP, From: "bank@example.com"})
+	require.Error(t, err)
+	_, err = NewMailer(Config{Type: TypeFile})
+	require.Error(t, err)
+	_, err = NewMailer(Config{Type: "carrier-pigeon"})
+	require.Error(t, err)
+}
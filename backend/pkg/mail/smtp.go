@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPMailer 通过SMTP服务器发送邮件, 服务器支持时使用STARTTLS
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(host string, port int, username string, password string, from string) (*SMTPMailer, error) {
+	if host == "" || port == 0 {
+		return nil, errors.New("smtp mailer requires host and port")
+	}
+	if from == "" {
+		return nil, errors.New("smtp mailer requires a sender address")
+	}
+
+	mailer := &SMTPMailer{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, msg.To, encode(m.from, msg, time.Now()))
+}
@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"slices"
+	"strings"
+)
+
+// CodeChallengeMethodS256 唯一支持的PKCE方法, 不支持明文的plain
+const CodeChallengeMethodS256 = "S256"
+
+// ParseScope 解析以空格分隔的授权范围, 去掉重复的项并排序
+func ParseScope(scope string) []string {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+// FormatScope 将授权范围格式化为以空格分隔的字符串
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// Subset scopes 是否都在 allowed 之中
+func Subset(scopes []string, allowed []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyCodeChallenge 校验PKCE的code_verifier, 要求 BASE64URL(SHA256(verifier)) 与授权时的code_challenge相同
+func VerifyCodeChallenge(verifier string, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,29 @@
+package oauth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"simple_bank/pkg/token"
+)
+
+func TestParseScope(t *testing.T) {
+	require.Equal(t, []string{token.ScopeAccountsRead, token.ScopeTransfersWrite}, ParseScope(" transfers:write accounts:read  transfers:write"))
+	require.Empty(t, ParseScope(""))
+	require.Equal(t, "accounts:read transfers:write", FormatScope([]string{token.ScopeAccountsRead, token.ScopeTransfersWrite}))
+}
+
+func TestSubset(t *testing.T) {
+	allowed := []string{token.ScopeAccountsRead, token.ScopeTransfersWrite}
+	require.True(t, Subset([]string{token.ScopeAccountsRead}, allowed))
+	require.True(t, Subset(nil, allowed))
+	require.False(t, Subset([]string{token.ScopeAccountsWrite}, allowed))
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mJ92K2vHq0tDHkB-7VHoHrW33LFLRE"
+	challenge := "9L571f23m-2LtqfLVuayWwks06FUmgniUpJiJggmGZY"
+	require.True(t, VerifyCodeChallenge(verifier, challenge))
+	require.False(t, VerifyCodeChallenge("wrong", challenge))
+}
@@ -0,0 +1,129 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idParams argon2id的参数, 为零值的参数使用默认值
+type Argon2idParams struct {
+	// 使用的内存, 单位为KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams 默认使用64MiB内存, 3次迭代, 2个线程
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func (p Argon2idParams) withDefaults() Argon2idParams {
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2idParams.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultArgon2idParams.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultArgon2idParams.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = DefaultArgon2idParams.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultArgon2idParams.KeyLength
+	}
+	return p
+}
+
+// Argon2idHasher 散列值使用PHC字符串格式: $argon2id$v=19$m=65536,t=3,p=2$<盐值>$<散列>
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{params: params.withDefaults()}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password string, hashed string) error {
+	params, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return err
+	}
+	// 使用散列值中编码的参数计算, 修改参数之前生成的散列值同样可以校验
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) Recognizes(hashed string) bool {
+	return strings.HasPrefix(hashed, argon2idPrefix)
+}
+
+func (h *Argon2idHasher) Outdated(hashed string) bool {
+	params, _, _, err := decodeArgon2id(hashed)
+	if err != nil {
+		return true
+	}
+	return params != h.params
+}
+
+// decodeArgon2id 解析散列值中的参数, 盐值与散列
+func decodeArgon2id(hashed string) (params Argon2idParams, salt []byte, key []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,74 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// 按SHA-1散列值的前5个十六进制字符分桶, 与 Have I Been Pwned 的范围查询相同
+const breachPrefixLength = 5
+
+// BreachedList 从本地文件加载的已泄露密码的SHA-1散列值
+// 文件每行一个散列值, 格式为 <40位十六进制SHA-1>[:出现次数], 空行与#开头的行忽略
+type BreachedList struct {
+	// 前缀 -> 排好序的后缀
+	buckets map[string][]string
+}
+
+// LoadBreachedList 加载已泄露密码的散列值文件
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedList{buckets: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid sha-1 hash", path, line)
+		}
+		if _, err = hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid sha-1 hash", path, line)
+		}
+		prefix := hash[:breachPrefixLength]
+		list.buckets[prefix] = append(list.buckets[prefix], hash[breachPrefixLength:])
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range list.buckets {
+		slices.Sort(suffixes)
+	}
+	return list, nil
+}
+
+// Breached 只在密码散列值前缀对应的桶中查找
+func (l *BreachedList) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := slices.BinarySearch(l.buckets[hash[:breachPrefixLength]], hash[breachPrefixLength:])
+	return found, nil
+}
+
+// Len 加载的散列值数量
+func (l *BreachedList) Len() int {
+	n := 0
+	for _, suffixes := range l.buckets {
+		n += len(suffixes)
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 支持的密码散列算法
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrMismatchedPassword = errors.New("password does not match the hashed password")
+	ErrUnknownHash        = errors.New("unknown password hash format")
+)
+
+// Hasher 一种密码散列算法, 散列值中编码了算法与参数, 修改参数之后仍能校验旧的散列值
+type Hasher interface {
+	// Hash 使用当前的参数与随机的盐值生成散列值
+	Hash(password string) (string, error)
+	// Verify 校验密码与散列值是否匹配, 不匹配时返回 ErrMismatchedPassword
+	Verify(password string, hashed string) error
+	// Recognizes 散列值是否由该算法生成
+	Recognizes(hashed string) bool
+	// Outdated 散列值使用的参数是否与当前的参数不同
+	Outdated(hashed string) bool
+}
+
+// Config 创建 Manager 的配置, Algorithm 为空时使用 argon2id
+type Config struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2id   Argon2idParams
+}
+
+// Manager 使用当前的算法生成散列值, 校验时根据散列值的格式选择算法
+// 校验通过的旧算法或旧参数的散列值需要重新计算, 由调用方在登录成功之后保存
+type Manager struct {
+	current Hasher
+	hashers []Hasher
+}
+
+// NewManager 使用current生成散列值, 同时可以校验current与legacy生成的散列值
+func NewManager(current Hasher, legacy ...Hasher) *Manager {
+	return &Manager{
+		current: current,
+		hashers: append([]Hasher{current}, legacy...),
+	}
+}
+
+// New 根据配置创建 Manager, 其它支持的算法作为旧算法继续校验
+func New(config Config) (*Manager, error) {
+	argon2id := NewArgon2idHasher(config.Argon2id)
+	bcrypt := NewBcryptHasher(config.BcryptCost)
+
+	switch config.Algorithm {
+	case "", AlgorithmArgon2id:
+		return NewManager(argon2id, bcrypt), nil
+	case AlgorithmBcrypt:
+		return NewManager(bcrypt, argon2id), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %s", config.Algorithm)
+	}
+}
+
+// Hash 使用当前的算法与参数生成散列值
+func (m *Manager) Hash(password string) (string, error) {
+	return m.current.Hash(password)
+}
+
+// Verify 校验密码, needsRehash 表示散列值不是由当前的算法与参数生成, 应该使用 Hash 重新计算
+func (m *Manager) Verify(password string, hashed string) (needsRehash bool, err error) {
+	for _, hasher := range m.hashers {
+		if !hasher.Recognizes(hashed) {
+			continue
+		}
+		if err = hasher.Verify(password, hashed); err != nil {
+			return false, err
+		}
+		return hasher != m.current || hasher.Outdated(hashed), nil
+	}
+	return false, ErrUnknownHash
+}
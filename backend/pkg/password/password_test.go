@@ -0,0 +1,108 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 测试使用较小的参数, 避免每次计算都占用64MiB内存
+var testParams = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := NewArgon2idHasher(testParams)
+
+	hashed, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	require.True(t, hasher.Recognizes(hashed))
+	require.False(t, hasher.Outdated(hashed))
+
+	require.NoError(t, hasher.Verify("secret", hashed))
+	require.ErrorIs(t, hasher.Verify("wrong", hashed), ErrMismatchedPassword)
+
+	// 相同的密码使用不同的盐值
+	other, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	require.NotEqual(t, hashed, other)
+
+	// 修改参数之后仍能校验旧的散列值, 但需要重新计算
+	stronger := NewArgon2idHasher(Argon2idParams{Memory: 2048, Iterations: 2, Parallelism: 1})
+	require.NoError(t, stronger.Verify("secret", hashed))
+	require.True(t, stronger.Outdated(hashed))
+
+	for _, malformed := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+	} {
+		require.ErrorIs(t, hasher.Verify("secret", malformed), ErrUnknownHash, malformed)
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := NewBcryptHasher(bcrypt.MinCost)
+
+	hashed, err := hasher.Hash("secret")
+	require.NoError(t, err)
+	require.True(t, hasher.Recognizes(hashed))
+	require.False(t, hasher.Outdated(hashed))
+	require.NoError(t, hasher.Verify("secret", hashed))
+	require.ErrorIs(t, hasher.Verify("wrong", hashed), ErrMismatchedPassword)
+
+	require.True(t, NewBcryptHasher(bcrypt.MinCost+1).Outdated(hashed))
+}
+
+func TestManagerVerify(t *testing.T) {
+	argon2id := NewArgon2idHasher(testParams)
+	legacy := NewBcryptHasher(bcrypt.MinCost)
+	manager := NewManager(argon2id, legacy)
+
+	bcryptHash, err := legacy.Hash("secret")
+	require.NoError(t, err)
+	argon2idHash, err := manager.Hash("secret")
+	require.NoError(t, err)
+	weakHash, err := NewArgon2idHasher(Argon2idParams{Memory: 512, Iterations: 1, Parallelism: 1}).Hash("secret")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		password    string
+		hashed      string
+		needsRehash bool
+		err         error
+	}{
+		{name: "当前的算法与参数", password: "secret", hashed: argon2idHash},
+		{name: "旧的算法", password: "secret", hashed: bcryptHash, needsRehash: true},
+		{name: "旧的参数", password: "secret", hashed: weakHash, needsRehash: true},
+		{name: "密码错误", password: "wrong", hashed: bcryptHash, err: ErrMismatchedPassword},
+		{name: "未知的格式", password: "secret", hashed: "plaintext", err: ErrUnknownHash},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			needsRehash, err := manager.Verify(tc.password, tc.hashed)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.needsRehash, needsRehash)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	manager, err := New(Config{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	require.NoError(t, err)
+	hashed, err := manager.Hash("secret")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hashed, "$2a$"))
+
+	_, err = New(Config{Algorithm: "md5"})
+	require.Error(t, err)
+}
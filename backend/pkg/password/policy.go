@@ -0,0 +1,174 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 默认的密码长度限制, 过长的密码会增加散列的计算量
+const (
+	DefaultMinLength = 8
+	DefaultMaxLength = 128
+)
+
+// 用户输入中长度小于该值的部分不参与相似度检查, 避免误伤
+const minSimilarLength = 3
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordClasses  = errors.New("password does not contain enough character classes")
+	ErrPasswordSimilar  = errors.New("password is too similar to the user's information")
+	ErrPasswordWeak     = errors.New("password is too easy to guess")
+	ErrPasswordBreached = errors.New("password appears in a list of breached passwords")
+)
+
+// BreachChecker 检查密码是否出现在已泄露的密码中
+type BreachChecker interface {
+	Breached(password string) (bool, error)
+}
+
+// Policy 设置新密码时的强度要求, 为零值的长度使用默认值, MinClasses 与 MinScore 为0时不检查
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// 至少包含的字符类别数: 小写字母, 大写字母, 数字, 符号
+	MinClasses int
+	// 强度评分的最低要求, 取值0~4, 见 Score
+	MinScore int
+	// 为nil时不检查是否已泄露
+	Breaches BreachChecker
+}
+
+func (p Policy) minLength() int {
+	if p.MinLength > 0 {
+		return p.MinLength
+	}
+	return DefaultMinLength
+}
+
+func (p Policy) maxLength() int {
+	if p.MaxLength > 0 {
+		return p.MaxLength
+	}
+	return DefaultMaxLength
+}
+
+// Limits 返回实际生效的最短与最长长度, 用于提示用户
+func (p Policy) Limits() (minLength int, maxLength int) {
+	return p.minLength(), p.maxLength()
+}
+
+// Check 检查新密码是否满足要求, userInputs 为用户名, 邮箱等用户自己的信息, 密码不能与它们相似
+// 返回第一个不满足的要求对应的错误, 已泄露的检查放在最后
+func (p Policy) Check(password string, userInputs ...string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength() {
+		return ErrPasswordTooShort
+	}
+	if length > p.maxLength() {
+		return ErrPasswordTooLong
+	}
+	if p.MinClasses > 0 && classify(password).count() < p.MinClasses {
+		return ErrPasswordClasses
+	}
+	if similarToInputs(password, userInputs) {
+		return ErrPasswordSimilar
+	}
+	if p.MinScore > 0 && Score(password, userInputs...) < p.MinScore {
+		return ErrPasswordWeak
+	}
+	if p.Breaches != nil {
+		breached, err := p.Breaches.Breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return ErrPasswordBreached
+		}
+	}
+	return nil
+}
+
+// charClasses 密码中出现过的字符类别
+type charClasses struct {
+	lower, upper, digit, symbol bool
+}
+
+func classify(password string) charClasses {
+	var c charClasses
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// count 出现过的字符类别数
+func (c charClasses) count() int {
+	classes := 0
+	for _, ok := range []bool{c.lower, c.upper, c.digit, c.symbol} {
+		if ok {
+			classes++
+		}
+	}
+	return classes
+}
+
+// poolSize 出现过的字符类别的字符总数
+func (c charClasses) poolSize() int {
+	size := 0
+	if c.lower {
+		size += 26
+	}
+	if c.upper {
+		size += 26
+	}
+	if c.digit {
+		size += 10
+	}
+	if c.symbol {
+		size += 33
+	}
+	return max(size, 1)
+}
+
+// similarToInputs 密码包含用户的信息, 或者就是用户信息的一部分
+// 邮箱同时检查@之前的部分, 姓名按空格拆开检查
+func similarToInputs(password string, userInputs []string) bool {
+	lower := strings.ToLower(password)
+	for _, input := range expandInputs(userInputs) {
+		if strings.Contains(lower, input) || strings.Contains(input, lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandInputs 将用户信息拆成需要检查的小写片段
+func expandInputs(userInputs []string) []string {
+	var parts []string
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		candidates := append([]string{input}, strings.Fields(input)...)
+		if local, _, ok := strings.Cut(input, "@"); ok {
+			candidates = append(candidates, local)
+		}
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= minSimilarLength {
+				parts = append(parts, candidate)
+			}
+		}
+	}
+	return parts
+}
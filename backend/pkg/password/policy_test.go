@@ -0,0 +1,122 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	breaches := writeBreachedList(t, "Tr0ub4dor&3x")
+	policy := Policy{MinLength: 10, MinClasses: 3, MinScore: 3, Breaches: breaches}
+
+	testCases := []struct {
+		name     string
+		password string
+		err      error
+	}{
+		{name: "OK", password: "correct-Horse-7-battery"},
+		{name: "过短", password: "aB3$", err: ErrPasswordTooShort},
+		{name: "过长", password: strings.Repeat("aB3$", 40), err: ErrPasswordTooLong},
+		{name: "字符类别不足", password: "correcthorsebattery", err: ErrPasswordClasses},
+		{name: "包含用户名", password: "Alice_wonder-1987", err: ErrPasswordSimilar},
+		{name: "包含邮箱", password: "Wonderland.bob99!", err: ErrPasswordSimilar},
+		{name: "容易猜到", password: "P@ssw0rd1234", err: ErrPasswordWeak},
+		{name: "已泄露", password: "Tr0ub4dor&3x", err: ErrPasswordBreached},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Check(tc.password, "alice", "wonderland.bob@example.com", "Alice Liddell")
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestPolicyDefaults(t *testing.T) {
+	var policy Policy
+	minLength, maxLength := policy.Limits()
+	require.Equal(t, DefaultMinLength, minLength)
+	require.Equal(t, DefaultMaxLength, maxLength)
+
+	// 零值只检查长度与相似度
+	require.NoError(t, policy.Check("aaaaaaaa"))
+	require.ErrorIs(t, policy.Check("aaaaaaa"), ErrPasswordTooShort)
+}
+
+func TestScore(t *testing.T) {
+	testCases := []struct {
+		password string
+		maxScore int
+		minScore int
+	}{
+		{password: "password", maxScore: 0},
+		{password: "123456789", maxScore: 1},
+		{password: "qwertyuiop", maxScore: 1},
+		{password: "aaaaaaaaaaaa", maxScore: 1},
+		{password: "P@ssw0rd!", maxScore: 1},
+		{password: "Iloveyou2024", maxScore: 2},
+		{password: "correct-Horse-7-battery", minScore: 4},
+		{password: "x7#Kq9!mZp2w", minScore: 4},
+	}
+
+	for _, tc := range testCases {
+		score := Score(tc.password)
+		require.GreaterOrEqual(t, score, tc.minScore, tc.password)
+		if tc.maxScore > 0 || tc.minScore == 0 {
+			require.LessOrEqual(t, score, tc.maxScore, tc.password)
+		}
+	}
+
+	// 用户自己的信息按单词处理
+	require.Less(t, Score("liddellliddell", "Alice Liddell"), Score("liddellliddell"))
+}
+
+func TestBreachedList(t *testing.T) {
+	list := writeBreachedList(t, "hunter2", "letmein")
+	require.Equal(t, 2, list.Len())
+
+	for _, password := range []string{"hunter2", "letmein"} {
+		breached, err := list.Breached(password)
+		require.NoError(t, err)
+		require.True(t, breached, password)
+	}
+	breached, err := list.Breached("correct-Horse-7-battery")
+	require.NoError(t, err)
+	require.False(t, breached)
+
+	// 格式错误的文件
+	path := filepath.Join(t.TempDir(), "invalid.txt")
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash:1\n"), 0o600))
+	_, err = LoadBreachedList(path)
+	require.Error(t, err)
+}
+
+// writeBreachedList 将密码的SHA-1散列值写入临时文件并加载, 混用大小写与出现次数
+func writeBreachedList(t *testing.T, passwords ...string) *BreachedList {
+	lines := []string{"# breached passwords", ""}
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		hash := hex.EncodeToString(sum[:])
+		if i%2 == 0 {
+			hash = strings.ToUpper(hash) + ":42"
+		}
+		lines = append(lines, hash)
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+	list, err := LoadBreachedList(path)
+	require.NoError(t, err)
+	return list
+}
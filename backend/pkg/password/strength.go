@@ -0,0 +1,127 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// 常见的密码与单词, 匹配时不区分大小写, 并且还原常见的字符替换, 例如 p@ssw0rd
+var commonWords = []string{
+	"password", "qwerty", "letmein", "welcome", "admin", "login", "iloveyou", "monkey",
+	"dragon", "football", "baseball", "soccer", "hockey", "sunshine", "princess", "master",
+	"shadow", "superman", "batman", "michael", "jordan", "charlie", "thomas", "jessica",
+	"ashley", "daniel", "hello", "freedom", "whatever", "starwars", "secret", "summer",
+	"winter", "spring", "autumn", "love", "money", "bank", "simplebank", "simple",
+	"test", "guest", "root", "user", "changeme", "computer", "internet", "google",
+	"apple", "ranger", "harley", "pepper", "cheese", "flower", "hunter", "buster",
+	"tigger", "matrix", "access", "mustang", "maggie", "ginger", "woaini", "nihao",
+	"china", "beijing", "shanghai", "qazwsx", "zaq", "trustno",
+}
+
+// 年份只有1900~2099两百种可能
+var yearBits = math.Log2(200)
+
+// 常见的字符替换
+var leetReplacer = strings.NewReplacer(
+	"4", "a", "@", "a", "8", "b", "3", "e", "6", "g", "1", "i", "!", "i",
+	"0", "o", "5", "s", "$", "s", "7", "t", "+", "t", "2", "z",
+)
+
+// 键盘上的各行, 同一行相邻的字符视为键盘序列
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// Score 估算猜中密码所需的尝试次数, 按数量级给出0~4的评分, 评分的含义与zxcvbn相同:
+// 0: 少于10^3次, 1: 少于10^6次, 2: 少于10^8次, 3: 少于10^10次, 4: 更多
+// 常见单词, 用户自己的信息, 年份, 重复, 连续与键盘序列的字符只计很少的熵
+func Score(password string, userInputs ...string) int {
+	log10Guesses := entropyBits(password, userInputs) * math.Log10(2)
+	switch {
+	case log10Guesses < 3:
+		return 0
+	case log10Guesses < 6:
+		return 1
+	case log10Guesses < 8:
+		return 2
+	case log10Guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// entropyBits 从左到右匹配单词与模式, 估算密码的熵
+func entropyBits(password string, userInputs []string) float64 {
+	lower := []rune(strings.ToLower(password))
+	normalized := []rune(leetReplacer.Replace(string(lower)))
+	// 替换都是单个字符之间的替换, 两者的位置一一对应
+	if len(normalized) != len(lower) {
+		normalized = lower
+	}
+
+	words := append(expandInputs(userInputs), commonWords...)
+	// 猜测时先尝试单词表中的单词, 每个单词约需要单词表大小的尝试次数, 大小写与替换再多一倍
+	wordBits := math.Log2(float64(len(words))) + 1
+	charBits := math.Log2(float64(classify(password).poolSize()))
+
+	bits := 0.0
+	for i := 0; i < len(lower); {
+		if n := longestWord(normalized[i:], words); n > 0 {
+			bits += wordBits
+			i += n
+			continue
+		}
+		if isYear(lower[i:]) {
+			bits += yearBits
+			i += 4
+			continue
+		}
+		if i > 0 && predictable(lower[i-1], lower[i]) {
+			bits++
+		} else {
+			bits += charBits
+		}
+		i++
+	}
+	return bits
+}
+
+// isYear 以 19xx 或 20xx 形式的年份开头
+func isYear(s []rune) bool {
+	if len(s) < 4 || !(s[0] == '1' && s[1] == '9' || s[0] == '2' && s[1] == '0') {
+		return false
+	}
+	return unicode.IsDigit(s[2]) && unicode.IsDigit(s[3])
+}
+
+// longestWord 以s开头的最长单词的长度, 没有则返回0
+func longestWord(s []rune, words []string) int {
+	longest := 0
+	for _, word := range words {
+		n := len([]rune(word))
+		if n > longest && n <= len(s) && string(s[:n]) == word {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// predictable 与前一个字符重复, 连续, 或者在键盘上相邻
+func predictable(prev rune, r rune) bool {
+	if r == prev || r == prev+1 || r == prev-1 {
+		return true
+	}
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, prev)
+		j := strings.IndexRune(row, r)
+		if i >= 0 && j >= 0 && (i-j == 1 || j-i == 1) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package rbac
+
+import "slices"
+
+// 用户的角色
+const (
+	RoleCustomer = "customer"
+	RoleTeller   = "teller"
+	RoleAuditor  = "auditor"
+	RoleAdmin    = "admin"
+)
+
+// Permission 路由声明需要的权限
+type Permission string
+
+const (
+	AccountsCreate  Permission = "accounts:create"
+	AccountsRead    Permission = "accounts:read"
+	TransfersCreate Permission = "transfers:create"
+	TransfersRead   Permission = "transfers:read"
+	AccountsFreeze  Permission = "accounts:freeze"
+	AccountsAdjust  Permission = "accounts:adjust"
+	UsersSearch     Permission = "users:search"
+	LoginUnlock     Permission = "login:unlock"
+	UsersRoles      Permission = "users:roles"
+	AuditRead       Permission = "audit:read"
+)
+
+// 所有的权限
+var permissions = []Permission{
+	AccountsCreate,
+	AccountsRead,
+	TransfersCreate,
+	TransfersRead,
+	AccountsFreeze,
+	AccountsAdjust,
+	UsersSearch,
+	LoginUnlock,
+	UsersRoles,
+	AuditRead,
+}
+
+// Scope 权限的作用范围
+type Scope int
+
+const (
+	// ScopeNone 没有该权限
+	ScopeNone Scope = iota
+	// ScopeOwn 只能操作自己的资源
+	ScopeOwn
+	// ScopeAll 可以操作所有用户的资源
+	ScopeAll
+)
+
+// 各个角色拥有的权限与作用范围
+var policy = map[string]map[Permission]Scope{
+	RoleCustomer: {
+		AccountsCreate:  ScopeOwn,
+		AccountsRead:    ScopeOwn,
+		TransfersCreate: ScopeOwn,
+	},
+	// 柜员可以为客户开户与查询客户的账户, 但不能从客户的账户转出金额
+	RoleTeller: {
+		AccountsCreate:  ScopeAll,
+		AccountsRead:    ScopeAll,
+		TransfersCreate: ScopeOwn,
+	},
+	// 审计只读, 只有审计可以查询审计事件, 管理员的操作同样被记录
+	RoleAuditor: {
+		AccountsRead: ScopeAll,
+		AuditRead:    ScopeAll,
+	},
+	// 管理员可以使用后台接口
+	RoleAdmin: {
+		AccountsCreate:  ScopeAll,
+		AccountsRead:    ScopeAll,
+		TransfersCreate: ScopeOwn,
+		TransfersRead:   ScopeAll,
+		AccountsFreeze:  ScopeAll,
+		AccountsAdjust:  ScopeAll,
+		UsersSearch:     ScopeAll,
+		LoginUnlock:     ScopeAll,
+		UsersRoles:      ScopeAll,
+	},
+}
+
+// ValidRole 是否为支持的角色
+func ValidRole(role string) bool {
+	_, ok := policy[role]
+	return ok
+}
+
+// ValidPermission 是否为支持的权限
+func ValidPermission(permission Permission) bool {
+	return slices.Contains(permissions, permission)
+}
+
+// ScopeOf 返回角色拥有的权限的作用范围, 未知的角色没有任何权限
+func ScopeOf(role string, permission Permission) Scope {
+	return policy[role][permission]
+}
@@ -0,0 +1,35 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidRole(t *testing.T) {
+	for _, role := range []string{RoleCustomer, RoleTeller, RoleAuditor, RoleAdmin} {
+		require.True(t, ValidRole(role))
+	}
+	require.False(t, ValidRole(""))
+	require.False(t, ValidRole("root"))
+}
+
+func TestScopeOf(t *testing.T) {
+	require.Equal(t, ScopeOwn, ScopeOf(RoleCustomer, AccountsRead))
+	require.Equal(t, ScopeAll, ScopeOf(RoleTeller, AccountsRead))
+	require.Equal(t, ScopeAll, ScopeOf(RoleAuditor, AccountsRead))
+	require.Equal(t, ScopeNone, ScopeOf(RoleAuditor, TransfersCreate))
+	require.Equal(t, ScopeNone, ScopeOf(RoleCustomer, LoginUnlock))
+	require.Equal(t, ScopeAll, ScopeOf(RoleAdmin, UsersRoles))
+	// 只有审计可以查询审计事件
+	require.Equal(t, ScopeAll, ScopeOf(RoleAuditor, AuditRead))
+	require.Equal(t, ScopeNone, ScopeOf(RoleAdmin, AuditRead))
+	// 未知的角色没有任何权限
+	require.Equal(t, ScopeNone, ScopeOf("root", AccountsRead))
+}
+
+func TestValidPermission(t *testing.T) {
+	require.True(t, ValidPermission(AccountsRead))
+	require.True(t, ValidPermission(UsersRoles))
+	require.False(t, ValidPermission("accounts:delete"))
+}
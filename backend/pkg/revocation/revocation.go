@@ -0,0 +1,164 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg/token"
+)
+
+// Checker 检查令牌是否已被撤销
+type Checker interface {
+	IsRevoked(ctx context.Context, payload *token.Payload) (bool, error)
+}
+
+// Store 令牌撤销存储
+type Store interface {
+	Checker
+	// Revoke 撤销单个令牌, 直到令牌过期
+	Revoke(ctx context.Context, payload *token.Payload) error
+	// RevokeAll 撤销用户在 before 之前颁发的所有令牌
+	RevokeAll(ctx context.Context, username string, before time.Time) error
+}
+
+// 缓存的检查结果
+type cacheEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+// 本实例执行过的用户级别撤销
+type userRevocation struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+// PostgresStore 以Postgres持久化撤销记录, 并在内存中缓存检查结果
+// 已撤销的结果缓存到令牌过期为止, 未撤销的结果只缓存cacheTTL,
+// 因此其它实例撤销的令牌最多在cacheTTL之后被本实例拒绝
+type PostgresStore struct {
+	querier  db.Querier
+	cacheTTL time.Duration
+
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]cacheEntry
+	// 本实例执行过的用户级别撤销, key为用户名
+	// 撤销之前缓存的未撤销结果最多保留cacheTTL, 之后查询数据库即可得到撤销结果, 因此只需保留cacheTTL
+	users map[string]userRevocation
+}
+
+func NewPostgresStore(querier db.Querier, cacheTTL time.Duration) *PostgresStore {
+	return &PostgresStore{
+		querier:  querier,
+		cacheTTL: cacheTTL,
+		tokens:   make(map[uuid.UUID]cacheEntry),
+		users:    make(map[string]userRevocation),
+	}
+}
+
+func (s *PostgresStore) IsRevoked(ctx context.Context, payload *token.Payload) (bool, error) {
+	now := time.Now()
+	issuedAt := payload.IssuedAt.Time
+
+	s.mu.RLock()
+	userRevoked, ok := s.users[payload.Username]
+	entry, cached := s.tokens[payload.ID]
+	s.mu.RUnlock()
+
+	if ok && now.Before(userRevoked.expiresAt) && issuedAt.Before(userRevoked.before) {
+		return true, nil
+	}
+	if cached && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := s.querier.IsTokenRevoked(ctx, db.IsTokenRevokedParams{
+		ID:       payload.ID,
+		Username: payload.Username,
+		IssuedAt: issuedAt,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	entry = cacheEntry{revoked: revoked, expiresAt: now.Add(s.cacheTTL)}
+	if revoked {
+		entry.expiresAt = payload.ExpiresAt.Time
+	}
+	s.mu.Lock()
+	s.tokens[payload.ID] = entry
+	s.mu.Unlock()
+
+	return revoked, nil
+}
+
+func (s *PostgresStore) Revoke(ctx context.Context, payload *token.Payload) error {
+	err := s.querier.CreateRevokedToken(ctx, db.CreateRevokedTokenParams{
+		ID:        payload.ID,
+		Username:  payload.Username,
+		ExpiresAt: payload.ExpiresAt.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.tokens[payload.ID] = cacheEntry{revoked: true, expiresAt: payload.ExpiresAt.Time}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *PostgresStore) RevokeAll(ctx context.Context, username string, before time.Time) error {
+	// 令牌的颁发时间与数据库都精确到微秒, 同一秒内撤销之前颁发的令牌被撤销, 之后颁发的不受影响
+	before = before.Truncate(time.Microsecond)
+	err := s.querier.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username:      username,
+		RevokedBefore: before,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.users[username] = userRevocation{before: before, expiresAt: time.Now().Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return nil
+}
+
+// Purge 清理已过期的撤销记录与缓存
+func (s *PostgresStore) Purge(ctx context.Context) error {
+	now := time.Now()
+	s.mu.Lock()
+	for id, entry := range s.tokens {
+		if now.After(entry.expiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+	for username, revocation := range s.users {
+		if now.After(revocation.expiresAt) {
+			delete(s.users, username)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.querier.DeleteExpiredRevokedTokens(ctx)
+}
+
+// Run 每隔interval清理一次过期的撤销记录, 直到ctx被取消
+func (s *PostgresStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Purge(ctx)
+		}
+	}
+}
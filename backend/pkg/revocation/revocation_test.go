@@ -0,0 +1,169 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	mockdb "simple_bank/db/mock"
+	db "simple_bank/db/sqlc"
+	"simple_bank/pkg"
+	"simple_bank/pkg/rbac"
+	"simple_bank/pkg/token"
+)
+
+func TestIsRevokedCachesResult(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	store := NewPostgresStore(querier, time.Minute)
+	payload, err := token.NewPayload(uuid.New(), pkg.RandomString(6), rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+
+	// 第二次检查命中缓存, 只查询一次数据库
+	querier.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(false, nil)
+
+	for i := 0; i < 2; i++ {
+		revoked, err := store.IsRevoked(context.Background(), payload)
+		require.NoError(t, err)
+		require.False(t, revoked)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	store := NewPostgresStore(querier, time.Minute)
+	payload, err := token.NewPayload(uuid.New(), pkg.RandomString(6), rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+
+	querier.EXPECT().
+		CreateRevokedToken(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+	querier.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	require.NoError(t, store.Revoke(context.Background(), payload))
+
+	// 本实例撤销的令牌无需查询数据库
+	revoked, err := store.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevokeAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	store := NewPostgresStore(querier, time.Minute)
+	username := pkg.RandomString(6)
+
+	oldPayload, err := token.NewPayload(uuid.New(), username, rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+	oldPayload.IssuedAt.Time = time.Now().Add(-time.Hour)
+
+	querier.EXPECT().
+		RevokeUserTokens(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+	require.NoError(t, store.RevokeAll(context.Background(), username, time.Now()))
+
+	revoked, err := store.IsRevoked(context.Background(), oldPayload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// 撤销之后颁发的令牌仍然有效
+	newPayload, err := token.NewPayload(uuid.New(), username, rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+	newPayload.IssuedAt.Time = time.Now().Add(time.Second)
+	querier.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(false, nil)
+
+	revoked, err = store.IsRevoked(context.Background(), newPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestRevokeAllSameSecond(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	store := NewPostgresStore(querier, time.Minute)
+	username := pkg.RandomString(6)
+	before := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+
+	// 不截断到秒, 同一秒内撤销之前颁发的令牌也被撤销
+	querier.EXPECT().
+		RevokeUserTokens(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.RevokeUserTokensParams) error {
+			require.Equal(t, before, arg.RevokedBefore)
+			return nil
+		})
+	require.NoError(t, store.RevokeAll(context.Background(), username, before))
+
+	oldPayload, err := token.NewPayload(uuid.New(), username, rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+	oldPayload.IssuedAt.Time = before.Add(-100 * time.Millisecond)
+	revoked, err := store.IsRevoked(context.Background(), oldPayload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// 同一秒内撤销之后颁发的令牌不受影响
+	newPayload, err := token.NewPayload(uuid.New(), username, rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+	newPayload.IssuedAt.Time = before.Add(100 * time.Millisecond)
+	querier.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(false, nil)
+	revoked, err = store.IsRevoked(context.Background(), newPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestRevokeAllExpires(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	querier := mockdb.NewMockStore(ctrl)
+	store := NewPostgresStore(querier, time.Millisecond)
+	username := pkg.RandomString(6)
+
+	querier.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	require.NoError(t, store.RevokeAll(context.Background(), username, time.Now()))
+	require.Len(t, store.users, 1)
+
+	// 超过cacheTTL之后以数据库中的撤销记录为准
+	time.Sleep(5 * time.Millisecond)
+	payload, err := token.NewPayload(uuid.New(), username, rbac.RoleCustomer, time.Minute)
+	require.NoError(t, err)
+	payload.IssuedAt.Time = time.Now().Add(-time.Hour)
+	querier.EXPECT().
+		IsTokenRevoked(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(true, nil)
+	revoked, err := store.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	querier.EXPECT().DeleteExpiredRevokedTokens(gomock.Any()).Times(1).Return(nil)
+	require.NoError(t, store.Purge(context.Background()))
+	require.Empty(t, store.users)
+}
@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// RandomSecret 使用密码学安全的随机数生成n个字节的令牌, 以base64url编码返回
+// 与 RandomString 不同, 该令牌可以作为凭证使用
+func RandomSecret(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashSecret 高熵的随机令牌只需要SHA-256散列后保存, 便于按散列值直接查询
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JWK 公钥的JSON Web Key(RFC 7517)表示, 只包含校验签名所需的字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	// OKP(Ed25519)公钥
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	// RSA公钥
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKSet /.well-known/jwks.json 返回的公钥集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySetProvider 使用非对称密钥签名的Maker, 可以发布用于校验令牌的公钥
+type KeySetProvider interface {
+	JWKS() JWKSet
+}
+
+// NewEd25519JWK 根据Ed25519公钥生成JWK, kid为公钥的RFC 7638指纹
+func NewEd25519JWK(publicKey ed25519.PublicKey, alg string) JWK {
+	jwk := JWK{
+		Kty: "OKP",
+		Use: "sig",
+		Alg: alg,
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(publicKey),
+	}
+	jwk.Kid = jwk.Thumbprint()
+	return jwk
+}
+
+// NewRSAJWK 根据RSA公钥生成JWK, kid为公钥的RFC 7638指纹
+func NewRSAJWK(publicKey *rsa.PublicKey, alg string) JWK {
+	jwk := JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: alg,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+	jwk.Kid = jwk.Thumbprint()
+	return jwk
+}
+
+// Thumbprint 计算JWK的RFC 7638指纹: 按字典序序列化必需字段后做SHA-256
+func (jwk JWK) Thumbprint() string {
+	var members any
+	switch jwk.Kty {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		return ""
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const rsaKeyMinBits = 2048
+
+// JWTAsymmetricMaker 使用非对称密钥(EdDSA, RS256)签名的JWT
+// 校验令牌只需要公钥, 没有私钥时只能用于校验
+type JWTAsymmetricMaker struct {
+	method     jwt.SigningMethod
+	privateKey crypto.PrivateKey
+	publicKey  crypto.PublicKey
+	jwk        JWK
+}
+
+// NewJWTAsymmetricMaker 根据私钥的类型选择签名算法: Ed25519使用EdDSA, RSA使用RS256
+func NewJWTAsymmetricMaker(privateKey crypto.PrivateKey) (Maker, error) {
+	switch key := privateKey.(type) {
+	case ed25519.PrivateKey:
+		maker, err := newJWTAsymmetricVerifier(key.Public())
+		if err != nil {
+			return nil, err
+		}
+		maker.privateKey = key
+		return maker, nil
+	case *rsa.PrivateKey:
+		maker, err := newJWTAsymmetricVerifier(&key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		maker.privateKey = key
+		return maker, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt private key type %T", privateKey)
+	}
+}
+
+// NewJWTAsymmetricVerifier 只持有公钥的Maker, 用于其它服务校验令牌
+func NewJWTAsymmetricVerifier(publicKey crypto.PublicKey) (Maker, error) {
+	return newJWTAsymmetricVerifier(publicKey)
+}
+
+func newJWTAsymmetricVerifier(publicKey crypto.PublicKey) (*JWTAsymmetricMaker, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return &JWTAsymmetricMaker{
+			method:    jwt.SigningMethodEdDSA,
+			publicKey: key,
+			jwk:       NewEd25519JWK(key, jwt.SigningMethodEdDSA.Alg()),
+		}, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < rsaKeyMinBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", rsaKeyMinBits)
+		}
+		return &JWTAsymmetricMaker{
+			method:    jwt.SigningMethodRS256,
+			publicKey: key,
+			jwk:       NewRSAJWK(key, jwt.SigningMethodRS256.Alg()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported jwt public key type %T", publicKey)
+	}
+}
+
+func (maker *JWTAsymmetricMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	claims, err := NewPayload(uuid.New(), username, role, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return maker.sign(claims)
+}
+
+func (maker *JWTAsymmetricMaker) CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error) {
+	claims, err := NewScopedPayload(uuid.New(), username, role, restriction, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return maker.sign(claims)
+}
+
+func (maker *JWTAsymmetricMaker) sign(claims *Payload) (string, *Payload, error) {
+	if maker.privateKey == nil {
+		return "", nil, ErrSigningKeyUnavailable
+	}
+
+	token := jwt.NewWithClaims(maker.method, claims)
+	token.Header["kid"] = maker.jwk.Kid
+	tokenString, err := token.SignedString(maker.privateKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, claims, nil
+}
+
+func (maker *JWTAsymmetricMaker) VerifyToken(tokenString string) (*Payload, error) {
+	funcKey := func(t *jwt.Token) (interface{}, error) {
+		if kid, ok := t.Header["kid"].(string); ok && kid != maker.jwk.Kid {
+			return nil, errors.New("unknown key id")
+		}
+		return maker.publicKey, nil
+	}
+	// 只接受该Maker的签名算法, 防止算法混淆攻击(例如使用公钥作为HS256的密钥)
+	token, err := jwt.ParseWithClaims(tokenString, &Payload{}, funcKey, jwt.WithValidMethods([]string{maker.method.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*Payload); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+func (maker *JWTAsymmetricMaker) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{maker.jwk}}
+}
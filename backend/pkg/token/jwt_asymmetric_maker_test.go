@@ -0,0 +1,81 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestJWTAsymmetricMaker(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		privateKey any
+		publicKey  any
+		alg        string
+	}{
+		{name: "EdDSA", privateKey: edKey, publicKey: edKey.Public(), alg: "EdDSA"},
+		{name: "RS256", privateKey: rsaKey, publicKey: &rsaKey.PublicKey, alg: "RS256"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			maker, err := NewJWTAsymmetricMaker(tc.privateKey)
+			require.NoError(t, err)
+
+			username := pkg.RandomString(5)
+			tokenString, payload, err := maker.CreateToken(username, testRole, time.Minute)
+			require.NoError(t, err)
+
+			// 令牌头部携带与JWKS中一致的kid
+			keySet := maker.(KeySetProvider).JWKS()
+			require.Len(t, keySet.Keys, 1)
+			require.Equal(t, tc.alg, keySet.Keys[0].Alg)
+			parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, &Payload{})
+			require.NoError(t, err)
+			require.Equal(t, keySet.Keys[0].Kid, parsed.Header["kid"])
+
+			verifier, err := NewJWTAsymmetricVerifier(tc.publicKey)
+			require.NoError(t, err)
+			verified, err := verifier.VerifyToken(tokenString)
+			require.NoError(t, err)
+			require.Equal(t, payload.ID, verified.ID)
+
+			_, _, err = verifier.CreateToken(username, testRole, time.Minute)
+			require.ErrorIs(t, err, ErrSigningKeyUnavailable)
+
+			expired, _, err := maker.CreateToken(username, testRole, -time.Minute)
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(expired)
+			require.ErrorIs(t, err, jwt.ErrTokenExpired)
+		})
+	}
+}
+
+// 使用HS256伪造的令牌不能通过非对称Maker的校验
+func TestJWTAsymmetricMakerRejectsOtherAlg(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	maker, err := NewJWTAsymmetricMaker(edKey)
+	require.NoError(t, err)
+
+	hsMaker, err := NewJWTMaker(pkg.RandomString(32))
+	require.NoError(t, err)
+	tokenString, _, err := hsMaker.CreateToken(pkg.RandomString(5), testRole, time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(tokenString)
+	require.Error(t, err)
+	require.Nil(t, payload)
+}
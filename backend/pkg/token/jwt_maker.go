@@ -19,6 +19,8 @@ var (
 
 type JWTMaker struct {
 	secretKey []byte
+	// 密钥id, 非空时写入令牌头部的kid, 用于密钥轮换时选择校验的密钥
+	keyID string
 }
 
 func NewJWTMaker(secretKey string) (Maker, error) {
@@ -29,15 +31,33 @@ func NewJWTMaker(secretKey string) (Maker, error) {
 }
 
 // CreateToken 用户名与过期时间, 对特定用户的令牌或有效时期进行颁发
-func (maker JWTMaker) CreateToken(username string, duration time.Duration) (string, error) {
+func (maker JWTMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
 	tokenID := uuid.New()
-	claims, err := NewPayload(tokenID, username, duration)
+	claims, err := NewPayload(tokenID, username, role, duration)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
+	return maker.sign(claims)
+}
+
+func (maker JWTMaker) CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error) {
+	claims, err := NewScopedPayload(uuid.New(), username, role, restriction, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return maker.sign(claims)
+}
 
+func (maker JWTMaker) sign(claims *Payload) (string, *Payload, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(maker.secretKey)
+	if maker.keyID != "" {
+		token.Header["kid"] = maker.keyID
+	}
+	tokenString, err := token.SignedString(maker.secretKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, claims, nil
 }
 
 // VerifyToken 验证token是否合法
@@ -71,7 +71,7 @@ func TestExpiredJWTToken(t *testing.T) {
 // 测试Alg None攻击
 func TestInvalidJWTTokenAlgNone(t *testing.T) {
 	tokenID := uuid.New()
-	payload, err := NewPayload(tokenID, pkg.RandomString(5), time.Minute)
+	payload, err := NewPayload(tokenID, pkg.RandomString(5), testRole, time.Minute)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
@@ -93,8 +93,11 @@ func randomCreateToken(t *testing.T, username string, duration time.Duration) st
 		secretKey: []byte(secretKey),
 	}
 
-	token, err := maker.CreateToken(username, duration)
+	token, payload, err := maker.CreateToken(username, testRole, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, testRole, payload.Role)
 	return token
 }
@@ -0,0 +1,39 @@
+package token
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrSigningKeyUnavailable = errors.New("token maker has no private key to sign tokens")
+
+// LoadPrivateKey 读取PEM格式的私钥文件, 支持PKCS#8(Ed25519, RSA)与PKCS#1(RSA)
+// 生成密钥: openssl genpkey -algorithm ed25519 -out token_ed25519.pem
+func LoadPrivateKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key file: %w", err)
+	}
+	return ParsePrivateKey(data)
+}
+
+// ParsePrivateKey 解析PEM格式的私钥
+func ParsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+}
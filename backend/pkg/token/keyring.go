@@ -0,0 +1,285 @@
+package token
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyringManifest 密钥目录中描述所有密钥的清单文件
+const KeyringManifest = "keyring.json"
+
+var ErrNoActiveKey = errors.New("keyring has no active signing key")
+
+// KeyConfig 密钥环中的一个密钥
+// ActivatesAt 之后该密钥可以用于签名, 多个密钥同时可用时使用最晚生效的密钥签名
+// RetiresAt 之后该密钥签名的令牌不再被接受, 因此退役时间应晚于下一个密钥的生效时间加上令牌的最长有效期
+type KeyConfig struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	SymmetricKey     string    `json:"symmetricKey,omitempty"`
+	SymmetricKeyFile string    `json:"symmetricKeyFile,omitempty"`
+	PrivateKeyFile   string    `json:"privateKeyFile,omitempty"`
+	ActivatesAt      time.Time `json:"activatesAt"`
+	RetiresAt        time.Time `json:"retiresAt"`
+}
+
+type keyringEntry struct {
+	config KeyConfig
+	maker  Maker
+}
+
+func (e keyringEntry) active(now time.Time) bool {
+	return !e.config.ActivatesAt.After(now) && !e.retired(now)
+}
+
+func (e keyringEntry) retired(now time.Time) bool {
+	return !e.config.RetiresAt.IsZero() && !now.Before(e.config.RetiresAt)
+}
+
+// KeyringMaker 支持密钥轮换的Maker
+// 使用当前生效的密钥签名并在令牌中标记密钥id, 校验时使用令牌标记的任意未退役的密钥
+type KeyringMaker struct {
+	mu   sync.RWMutex
+	keys []keyringEntry
+	now  func() time.Time
+}
+
+func NewKeyringMaker(keys []KeyConfig) (*KeyringMaker, error) {
+	keyring := &KeyringMaker{now: time.Now}
+	if err := keyring.SetKeys(keys); err != nil {
+		return nil, err
+	}
+	return keyring, nil
+}
+
+// SetKeys 校验并替换密钥环中的所有密钥
+func (k *KeyringMaker) SetKeys(keys []KeyConfig) error {
+	if len(keys) == 0 {
+		return errors.New("keyring must contain at least one key")
+	}
+
+	entries := make([]keyringEntry, 0, len(keys))
+	ids := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" {
+			return errors.New("keyring key id must not be empty")
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("duplicate keyring key id %s", key.ID)
+		}
+		ids[key.ID] = true
+		if !key.RetiresAt.IsZero() && !key.RetiresAt.After(key.ActivatesAt) {
+			return fmt.Errorf("keyring key %s must retire after it activates", key.ID)
+		}
+
+		maker, err := newKeyedMaker(key)
+		if err != nil {
+			return fmt.Errorf("keyring key %s: %w", key.ID, err)
+		}
+		entries = append(entries, keyringEntry{config: key, maker: maker})
+	}
+
+	k.mu.Lock()
+	k.keys = entries
+	k.mu.Unlock()
+	return nil
+}
+
+// signingKey 当前生效的密钥中最晚生效的一个
+func (k *KeyringMaker) signingKey() (keyringEntry, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.now()
+	var signing keyringEntry
+	found := false
+	for _, entry := range k.keys {
+		if entry.active(now) && (!found || entry.config.ActivatesAt.After(signing.config.ActivatesAt)) {
+			signing = entry
+			found = true
+		}
+	}
+	return signing, found
+}
+
+func (k *KeyringMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	entry, ok := k.signingKey()
+	if !ok {
+		return "", nil, ErrNoActiveKey
+	}
+	return entry.maker.CreateToken(username, role, duration)
+}
+
+func (k *KeyringMaker) CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error) {
+	entry, ok := k.signingKey()
+	if !ok {
+		return "", nil, ErrNoActiveKey
+	}
+	return entry.maker.CreateScopedToken(username, role, restriction, duration)
+}
+
+func (k *KeyringMaker) VerifyToken(token string) (*Payload, error) {
+	k.mu.RLock()
+	keys := k.keys
+	k.mu.RUnlock()
+
+	now := k.now()
+	keyID := TokenKeyID(token)
+	if keyID != "" {
+		for _, entry := range keys {
+			if entry.config.ID == keyID {
+				if entry.retired(now) {
+					return nil, ErrInvalidToken
+				}
+				return entry.maker.VerifyToken(token)
+			}
+		}
+		return nil, ErrInvalidToken
+	}
+
+	// 密钥轮换之前颁发的令牌没有密钥id, 依次尝试所有未退役的密钥
+	err := ErrInvalidToken
+	for _, entry := range keys {
+		if entry.retired(now) {
+			continue
+		}
+		var payload *Payload
+		payload, err = entry.maker.VerifyToken(token)
+		if err == nil {
+			return payload, nil
+		}
+	}
+	return nil, err
+}
+
+// JWKS 发布所有未退役的非对称密钥的公钥, 包括尚未生效的密钥, 方便其它服务提前获取
+func (k *KeyringMaker) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.now()
+	keySet := JWKSet{Keys: []JWK{}}
+	for _, entry := range k.keys {
+		if entry.retired(now) {
+			continue
+		}
+		if provider, ok := entry.maker.(KeySetProvider); ok {
+			keySet.Keys = append(keySet.Keys, provider.JWKS().Keys...)
+		}
+	}
+	return keySet
+}
+
+// Run 每隔interval重新加载一次密钥目录, 直到ctx被取消
+// 加载失败时保留原有的密钥
+func (k *KeyringMaker) Run(ctx context.Context, dir string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			keys, err := LoadKeyDir(dir)
+			if err == nil {
+				err = k.SetKeys(keys)
+			}
+			if err != nil {
+				log.Printf("reload token keyring from %s: %v", dir, err)
+			}
+		}
+	}
+}
+
+// LoadKeyDir 读取密钥目录中的清单文件, 清单中的文件路径相对于密钥目录
+func LoadKeyDir(dir string) ([]KeyConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, KeyringManifest))
+	if err != nil {
+		return nil, fmt.Errorf("read keyring manifest: %w", err)
+	}
+
+	var keys []KeyConfig
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parse keyring manifest: %w", err)
+	}
+
+	for i := range keys {
+		if keys[i].PrivateKeyFile != "" && !filepath.IsAbs(keys[i].PrivateKeyFile) {
+			keys[i].PrivateKeyFile = filepath.Join(dir, keys[i].PrivateKeyFile)
+		}
+		if keys[i].SymmetricKeyFile != "" {
+			path := keys[i].SymmetricKeyFile
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			secret, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read symmetric key of %s: %w", keys[i].ID, err)
+			}
+			keys[i].SymmetricKey = strings.TrimSpace(string(secret))
+		}
+	}
+	return keys, nil
+}
+
+// newKeyedMaker 创建一个会在令牌中标记密钥id的Maker
+func newKeyedMaker(key KeyConfig) (Maker, error) {
+	maker, err := NewMaker(key.Type, key.SymmetricKey, key.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch m := maker.(type) {
+	case *PasetoMaker:
+		m.keyID = key.ID
+	case *JWTMaker:
+		m.keyID = key.ID
+	case *PasetoV4PublicMaker:
+		m.jwk.Kid = key.ID
+	case *JWTAsymmetricMaker:
+		m.jwk.Kid = key.ID
+	}
+	return maker, nil
+}
+
+// TokenKeyID 在不校验签名的情况下读取令牌标记的密钥id
+// PASETO的密钥id在footer中, JWT的密钥id在头部的kid中
+func TokenKeyID(token string) string {
+	for _, header := range []string{"v2.local.", pasetoV4PublicHeader} {
+		if !strings.HasPrefix(token, header) {
+			continue
+		}
+		parts := strings.Split(token[len(header):], ".")
+		if len(parts) != 2 {
+			return ""
+		}
+		data, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return ""
+		}
+		var footer pasetoFooter
+		if json.Unmarshal(data, &footer) != nil {
+			return ""
+		}
+		return footer.Kid
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Payload{})
+	if err != nil {
+		return ""
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
@@ -0,0 +1,171 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestKeyringMakerRotation(t *testing.T) {
+	now := time.Now()
+	keyring, err := NewKeyringMaker([]KeyConfig{
+		{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: now.Add(-2 * time.Hour)},
+		{ID: "k2", Type: TypeJWTHS256, SymmetricKey: pkg.RandomString(32), ActivatesAt: now.Add(-time.Hour)},
+		{ID: "k3", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: now.Add(time.Hour)},
+	})
+	require.NoError(t, err)
+
+	username := pkg.RandomString(6)
+
+	// 使用已生效的密钥中最晚生效的k2签名, 尚未生效的k3不参与签名
+	tokenString, payload, err := keyring.CreateToken(username, testRole, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "k2", TokenKeyID(tokenString))
+
+	verified, err := keyring.VerifyToken(tokenString)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+
+	// k3生效之后使用k3签名, k2签名的令牌在退役之前仍然有效
+	keyring.now = func() time.Time { return now.Add(2 * time.Hour) }
+	rotated, _, err := keyring.CreateToken(username, testRole, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "k3", TokenKeyID(rotated))
+	_, err = keyring.VerifyToken(rotated)
+	require.NoError(t, err)
+}
+
+func TestKeyringMakerRetiredKey(t *testing.T) {
+	now := time.Now()
+	oldKey := KeyConfig{ID: "old", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: now.Add(-time.Hour)}
+	newKey := KeyConfig{ID: "new", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: now.Add(-time.Minute)}
+
+	keyring, err := NewKeyringMaker([]KeyConfig{oldKey})
+	require.NoError(t, err)
+	tokenString, _, err := keyring.CreateToken(pkg.RandomString(6), testRole, time.Hour)
+	require.NoError(t, err)
+
+	oldKey.RetiresAt = now.Add(-time.Second)
+	require.NoError(t, keyring.SetKeys([]KeyConfig{oldKey, newKey}))
+
+	_, err = keyring.VerifyToken(tokenString)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// 未知的密钥id同样被拒绝
+	newKey.ID = "other"
+	require.NoError(t, keyring.SetKeys([]KeyConfig{newKey}))
+	_, err = keyring.VerifyToken(tokenString)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestKeyringMakerLegacyToken(t *testing.T) {
+	secret := pkg.RandomString(32)
+	legacy, err := NewPasetoMaker(secret)
+	require.NoError(t, err)
+	tokenString, payload, err := legacy.CreateToken(pkg.RandomString(6), testRole, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, TokenKeyID(tokenString))
+
+	// 轮换之前颁发的令牌没有密钥id, 仍然可以被对应的密钥校验
+	keyring, err := NewKeyringMaker([]KeyConfig{
+		{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: secret},
+		{ID: "k2", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: time.Now()},
+	})
+	require.NoError(t, err)
+	verified, err := keyring.VerifyToken(tokenString)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+}
+
+func TestKeyringMakerNoActiveKey(t *testing.T) {
+	keyring, err := NewKeyringMaker([]KeyConfig{
+		{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: pkg.RandomString(32), ActivatesAt: time.Now().Add(time.Hour)},
+	})
+	require.NoError(t, err)
+
+	_, _, err = keyring.CreateToken(pkg.RandomString(6), testRole, time.Minute)
+	require.ErrorIs(t, err, ErrNoActiveKey)
+}
+
+func TestKeyringMakerInvalidKeys(t *testing.T) {
+	secret := pkg.RandomString(32)
+	now := time.Now()
+
+	testCases := []struct {
+		name string
+		keys []KeyConfig
+	}{
+		{name: "Empty", keys: nil},
+		{name: "MissingID", keys: []KeyConfig{{Type: TypePasetoV2Local, SymmetricKey: secret}}},
+		{name: "DuplicateID", keys: []KeyConfig{
+			{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: secret},
+			{ID: "k1", Type: TypeJWTHS256, SymmetricKey: secret},
+		}},
+		{name: "RetiresBeforeActivates", keys: []KeyConfig{
+			{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: secret, ActivatesAt: now, RetiresAt: now.Add(-time.Minute)},
+		}},
+		{name: "InvalidKey", keys: []KeyConfig{{ID: "k1", Type: TypePasetoV2Local, SymmetricKey: "short"}}},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewKeyringMaker(tc.keys)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ed25519.pem"), keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hs256.key"), []byte(pkg.RandomString(32)+"\n"), 0o600))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	manifest, err := json.Marshal([]KeyConfig{
+		{ID: "hs-1", Type: TypeJWTHS256, SymmetricKeyFile: "hs256.key", ActivatesAt: now.Add(-time.Hour)},
+		{ID: "ed-1", Type: TypeJWTEdDSA, PrivateKeyFile: "ed25519.pem", ActivatesAt: now.Add(time.Hour)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, KeyringManifest), manifest, 0o600))
+
+	keys, err := LoadKeyDir(dir)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	require.Len(t, keys[0].SymmetricKey, 32)
+	require.Equal(t, filepath.Join(dir, "ed25519.pem"), keys[1].PrivateKeyFile)
+
+	keyring, err := NewKeyringMaker(keys)
+	require.NoError(t, err)
+
+	// 尚未生效的非对称密钥提前发布到JWKS, kid为密钥环中的密钥id
+	keySet := keyring.JWKS()
+	require.Len(t, keySet.Keys, 1)
+	require.Equal(t, "ed-1", keySet.Keys[0].Kid)
+
+	// ed-1生效之后使用ed-1签名
+	keyring.now = func() time.Time { return now.Add(2 * time.Hour) }
+	tokenString, _, err := keyring.CreateToken(pkg.RandomString(6), testRole, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "ed-1", TokenKeyID(tokenString))
+	_, err = keyring.VerifyToken(tokenString)
+	require.NoError(t, err)
+
+	_, err = LoadKeyDir(t.TempDir())
+	require.Error(t, err)
+}
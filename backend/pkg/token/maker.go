@@ -1,13 +1,67 @@
 package token
 
 import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
 	"time"
 )
 
 // Maker 通用的Token接口管理令牌的颁发和校验, 用于切换不同的Token类型
 type Maker interface {
-	// CreateToken 用户名与过期时间, 对特定用户的令牌或有效时期进行颁发
-	CreateToken(username string, duration time.Duration) (string, error)
+	// CreateToken 用户名, 角色与过期时间, 对特定用户的令牌或有效时期进行颁发, 同时返回令牌的荷载
+	CreateToken(username string, role string, duration time.Duration) (string, *Payload, error)
+	// CreateScopedToken 颁发受限的令牌, 只能访问授权范围之内的接口, 用于第三方应用与用户申请的只读等令牌
+	CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error)
 	// VerifyToken 验证token是否合法
 	VerifyToken(token string) (*Payload, error)
 }
+
+// 支持的令牌类型
+const (
+	TypePasetoV2Local  = "paseto-v2-local"
+	TypePasetoV4Public = "paseto-v4-public"
+	TypeJWTHS256       = "jwt-hs256"
+	TypeJWTEdDSA       = "jwt-eddsa"
+	TypeJWTRS256       = "jwt-rs256"
+)
+
+// NewMaker 根据令牌类型创建Maker, 对称类型使用symmetricKey, 非对称类型从privateKeyFile读取私钥
+// 未指定类型时使用 PASETO v2.local
+func NewMaker(tokenType string, symmetricKey string, privateKeyFile string) (Maker, error) {
+	switch tokenType {
+	case "", TypePasetoV2Local:
+		return NewPasetoMaker(symmetricKey)
+	case TypeJWTHS256:
+		return NewJWTMaker(symmetricKey)
+	}
+
+	if privateKeyFile == "" {
+		return nil, fmt.Errorf("token type %s requires a private key file", tokenType)
+	}
+	privateKey, err := LoadPrivateKey(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tokenType {
+	case TypePasetoV4Public:
+		key, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("token type %s requires an ed25519 private key", tokenType)
+		}
+		return NewPasetoV4PublicMaker(key)
+	case TypeJWTEdDSA:
+		if _, ok := privateKey.(ed25519.PrivateKey); !ok {
+			return nil, fmt.Errorf("token type %s requires an ed25519 private key", tokenType)
+		}
+		return NewJWTAsymmetricMaker(privateKey)
+	case TypeJWTRS256:
+		if _, ok := privateKey.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("token type %s requires an rsa private key", tokenType)
+		}
+		return NewJWTAsymmetricMaker(privateKey)
+	default:
+		return nil, fmt.Errorf("unsupported token type %s", tokenType)
+	}
+}
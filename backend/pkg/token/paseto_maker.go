@@ -16,19 +16,37 @@ var (
 type PasetoMaker struct {
 	paseto       *paseto.V2
 	symmetricKey []byte
+	// 密钥id, 非空时写入令牌的footer, 用于密钥轮换时选择校验的密钥
+	keyID string
 }
 
-func (p PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
+func (p PasetoMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
 	tokenID := uuid.New()
-	payload, err := NewPayload(tokenID, username, duration)
+	payload, err := NewPayload(tokenID, username, role, duration)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	encrypt, err := p.paseto.Encrypt(p.symmetricKey, payload, nil)
+	return p.encrypt(payload)
+}
+
+func (p PasetoMaker) CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewScopedPayload(uuid.New(), username, role, restriction, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.encrypt(payload)
+}
+
+func (p PasetoMaker) encrypt(payload *Payload) (string, *Payload, error) {
+	var footer interface{}
+	if p.keyID != "" {
+		footer = pasetoFooter{Kid: p.keyID}
+	}
+	encrypt, err := p.paseto.Encrypt(p.symmetricKey, payload, footer)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	return encrypt, nil
+	return encrypt, payload, nil
 }
 
 func (p PasetoMaker) VerifyToken(token string) (*Payload, error) {
@@ -0,0 +1,147 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const pasetoV4PublicHeader = "v4.public."
+
+// PasetoV4PublicAlg 发布到JWKS中的PASETO v4.public公钥的alg
+const PasetoV4PublicAlg = "v4.public"
+
+// PasetoV4PublicMaker 使用Ed25519私钥签名的PASETO v4.public令牌
+// 校验令牌只需要公钥, 没有私钥时只能用于校验
+type PasetoV4PublicMaker struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	jwk        JWK
+}
+
+// 令牌的footer, 携带签名公钥的kid
+type pasetoFooter struct {
+	Kid string `json:"kid"`
+}
+
+func NewPasetoV4PublicMaker(privateKey ed25519.PrivateKey) (Maker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("ed25519 private key must be 64 bytes")
+	}
+	maker := newPasetoV4PublicVerifier(privateKey.Public().(ed25519.PublicKey))
+	maker.privateKey = privateKey
+	return maker, nil
+}
+
+// NewPasetoV4PublicVerifier 只持有公钥的Maker, 用于其它服务校验令牌
+func NewPasetoV4PublicVerifier(publicKey ed25519.PublicKey) (Maker, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("ed25519 public key must be 32 bytes")
+	}
+	return newPasetoV4PublicVerifier(publicKey), nil
+}
+
+func newPasetoV4PublicVerifier(publicKey ed25519.PublicKey) *PasetoV4PublicMaker {
+	return &PasetoV4PublicMaker{
+		publicKey: publicKey,
+		jwk:       NewEd25519JWK(publicKey, PasetoV4PublicAlg),
+	}
+}
+
+func (p *PasetoV4PublicMaker) CreateToken(username string, role string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(uuid.New(), username, role, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.sign(payload)
+}
+
+func (p *PasetoV4PublicMaker) CreateScopedToken(username string, role string, restriction Restriction, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewScopedPayload(uuid.New(), username, role, restriction, duration)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.sign(payload)
+}
+
+func (p *PasetoV4PublicMaker) sign(payload *Payload) (string, *Payload, error) {
+	if p.privateKey == nil {
+		return "", nil, ErrSigningKeyUnavailable
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	footer, err := json.Marshal(pasetoFooter{Kid: p.jwk.Kid})
+	if err != nil {
+		return "", nil, err
+	}
+
+	signature := ed25519.Sign(p.privateKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil))
+	token := pasetoV4PublicHeader +
+		base64.RawURLEncoding.EncodeToString(append(message, signature...)) +
+		"." + base64.RawURLEncoding.EncodeToString(footer)
+	return token, payload, nil
+}
+
+func (p *PasetoV4PublicMaker) VerifyToken(token string) (*Payload, error) {
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		return nil, ErrInvalidToken
+	}
+	parts := strings.Split(token[len(pasetoV4PublicHeader):], ".")
+	if len(parts) > 2 {
+		return nil, ErrInvalidToken
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+	var footer []byte
+	if len(parts) == 2 {
+		footer, err = base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	message := body[:len(body)-ed25519.SignatureSize]
+	signature := body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(p.publicKey, pae([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err = json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err = payload.Valid(); err != nil {
+		return nil, ErrExpiredToken
+	}
+	return payload, nil
+}
+
+func (p *PasetoV4PublicMaker) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{p.jwk}}
+}
+
+// pae PASETO的Pre-Authentication Encoding, 防止拼接的各部分产生歧义
+func pae(pieces ...[]byte) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(len(pieces)))
+	for _, piece := range pieces {
+		length := make([]byte, 8)
+		// 最高位必须清零
+		binary.LittleEndian.PutUint64(length, uint64(len(piece))&^(1<<63))
+		buf = append(buf, length...)
+		buf = append(buf, piece...)
+	}
+	return buf
+}
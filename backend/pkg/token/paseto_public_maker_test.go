@@ -0,0 +1,77 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"simple_bank/pkg"
+)
+
+func TestPasetoV4PublicMaker(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoV4PublicMaker(privateKey)
+	require.NoError(t, err)
+
+	username := pkg.RandomString(5)
+	token, payload, err := maker.CreateToken(username, testRole, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.Equal(t, username, payload.Username)
+
+	// 只持有公钥的服务可以校验令牌, 但不能颁发令牌
+	verifier, err := NewPasetoV4PublicVerifier(privateKey.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	verified, err := verifier.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+	require.Equal(t, username, verified.Username)
+
+	_, _, err = verifier.CreateToken(username, testRole, time.Minute)
+	require.ErrorIs(t, err, ErrSigningKeyUnavailable)
+
+	// 其它密钥签名的令牌
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherMaker, err := NewPasetoV4PublicMaker(otherKey)
+	require.NoError(t, err)
+	otherToken, _, err := otherMaker.CreateToken(username, testRole, time.Minute)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(otherToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// 过期的令牌
+	expiredToken, _, err := maker.CreateToken(username, testRole, -time.Minute)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(expiredToken)
+	require.ErrorIs(t, err, ErrExpiredToken)
+
+	// JWKS发布的公钥与footer中的kid一致
+	keySet := maker.(KeySetProvider).JWKS()
+	require.Len(t, keySet.Keys, 1)
+	require.Equal(t, "OKP", keySet.Keys[0].Kty)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)), keySet.Keys[0].X)
+}
+
+// PASETO官方测试向量 4-S-1
+func TestPasetoV4PublicVector(t *testing.T) {
+	secretKey, err := hex.DecodeString("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	require.NoError(t, err)
+	message := []byte(`{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`)
+	expected := "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+
+	signature := ed25519.Sign(secretKey, pae([]byte(pasetoV4PublicHeader), message, nil, nil))
+	require.Equal(t, expected, pasetoV4PublicHeader+base64.RawURLEncoding.EncodeToString(append(message, signature...)))
+}
+
+func TestPAE(t *testing.T) {
+	require.Equal(t, "\x00\x00\x00\x00\x00\x00\x00\x00", string(pae()))
+	require.Equal(t, "\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", string(pae([]byte{})))
+	require.Equal(t, "\x01\x00\x00\x00\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00test", string(pae([]byte("test"))))
+}
@@ -10,6 +10,9 @@ import (
 
 var symmetricKey = pkg.RandomString(32)
 
+// testRole 测试颁发令牌时使用的角色
+const testRole = "customer"
+
 func TestCreateToken(t *testing.T) {
 	token := createToken(t)
 	require.NotEmpty(t, token)
@@ -37,9 +40,12 @@ func createToken(t *testing.T) string {
 	require.NotEmpty(t, maker)
 
 	username := pkg.RandomString(5)
-	token, err := maker.CreateToken(username, time.Minute*2)
+	token, payload, err := maker.CreateToken(username, testRole, time.Minute*2)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
+	require.Equal(t, username, payload.Username)
+	require.Equal(t, testRole, payload.Role)
 
 	return token
 }
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -8,20 +9,74 @@ import (
 	"github.com/google/uuid"
 )
 
+func init() {
+	// 令牌中的时间保留到微秒, 与数据库的精度一致, 撤销之后在同一秒内颁发的令牌也能区分先后
+	jwt.TimePrecision = time.Microsecond
+}
+
 type Payload struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	// 第三方应用的令牌记录应用的id
+	ClientID string `json:"clientId,omitempty"`
+	// 令牌的授权范围, 为空则表示可以访问用户有权限的所有接口
+	Scopes []string `json:"scopes,omitempty"`
+	// 用户最近一次输入密码或一次性密码的时间, 只有加强验证令牌记录
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// 令牌的用途, 为空则为访问令牌
+	Purpose string `json:"purpose,omitempty"`
 
+	// 受限令牌的接收方记录在aud中
 	jwt.RegisteredClaims
 }
 
+// 令牌的授权范围
+const (
+	ScopeAccountsRead   = "accounts:read"
+	ScopeAccountsWrite  = "accounts:write"
+	ScopeTransfersWrite = "transfers:write"
+)
+
+// ScopeStepUp 加强验证令牌的授权范围, 只用于证明用户刚刚重新验证过身份, 不能访问任何业务接口
+// 不在 scopes 之中, 用户与第三方应用都不能申请
+const ScopeStepUp = "step_up"
+
+// PurposeRefresh 刷新令牌的用途, 只能用于换取新的访问令牌, 不能访问任何接口
+const PurposeRefresh = "refresh"
+
+// 所有的授权范围
+var scopes = []string{
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeTransfersWrite,
+}
+
+// ValidScope 是否为支持的授权范围
+func ValidScope(scope string) bool {
+	return slices.Contains(scopes, scope)
+}
+
+// Restriction 受限令牌的限制
+type Restriction struct {
+	// 第三方应用的id, 用户自己申请的令牌为空
+	ClientID string
+	Scopes   []string
+	Audience []string
+	// 用户重新验证身份的时间, 为零值则不记录
+	AuthTime time.Time
+	// 令牌的用途, 为空则为访问令牌
+	Purpose string
+}
+
 // NewPayload 创建一个荷载
 // 创建一个uuid, 用于管理token
-func NewPayload(id uuid.UUID, username string, duration time.Duration) (*Payload, error) {
+func NewPayload(id uuid.UUID, username string, role string, duration time.Duration) (*Payload, error) {
 	// 生成随机的uuid
 	payload := &Payload{
 		ID:       id,
 		Username: username,
+		Role:     role,
 
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
@@ -34,6 +89,52 @@ func NewPayload(id uuid.UUID, username string, duration time.Duration) (*Payload
 	return payload, nil
 }
 
+// NewScopedPayload 创建受限令牌的荷载
+func NewScopedPayload(id uuid.UUID, username string, role string, restriction Restriction, duration time.Duration) (*Payload, error) {
+	payload, err := NewPayload(id, username, role, duration)
+	if err != nil {
+		return nil, err
+	}
+	payload.ClientID = restriction.ClientID
+	payload.Scopes = restriction.Scopes
+	payload.Audience = restriction.Audience
+	payload.Purpose = restriction.Purpose
+	if !restriction.AuthTime.IsZero() {
+		payload.AuthTime = jwt.NewNumericDate(restriction.AuthTime)
+	}
+	return payload, nil
+}
+
+// IsScoped 是否为受限令牌
+func (payload *Payload) IsScoped() bool {
+	return payload.ClientID != "" || len(payload.Scopes) > 0
+}
+
+// IsRefresh 是否为刷新令牌
+func (payload *Payload) IsRefresh() bool {
+	return payload.Purpose == PurposeRefresh
+}
+
+// IsAccess 是否为访问令牌, 只有访问令牌可以通过Authorization头访问接口
+func (payload *Payload) IsAccess() bool {
+	return payload.Purpose == ""
+}
+
+// HasScope 令牌能否访问需要该授权范围的接口, 不受限的令牌可以访问所有接口
+func (payload *Payload) HasScope(scope string) bool {
+	return !payload.IsScoped() || slices.Contains(payload.Scopes, scope)
+}
+
+// ForAudience 令牌没有指定接收方, 或者接收方包含audience
+func (payload *Payload) ForAudience(audience string) bool {
+	return len(payload.Audience) == 0 || slices.Contains(payload.Audience, audience)
+}
+
+// AuthenticatedWithin 用户是否在maxAge之内重新验证过身份
+func (payload *Payload) AuthenticatedWithin(maxAge time.Duration) bool {
+	return payload.AuthTime != nil && time.Since(payload.AuthTime.Time) <= maxAge
+}
+
 // Valid 校验Token是否过期
 func (payload *Payload) Valid() error {
 	if time.Now().After(payload.RegisteredClaims.ExpiresAt.Time) {
@@ -0,0 +1,105 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateScopedToken(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pasetoMaker, err := NewPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+	jwtMaker, err := NewJWTMaker(symmetricKey)
+	require.NoError(t, err)
+	pasetoPublicMaker, err := NewPasetoV4PublicMaker(edKey)
+	require.NoError(t, err)
+	jwtEdDSAMaker, err := NewJWTAsymmetricMaker(edKey)
+	require.NoError(t, err)
+
+	makers := map[string]Maker{
+		TypePasetoV2Local:  pasetoMaker,
+		TypeJWTHS256:       jwtMaker,
+		TypePasetoV4Public: pasetoPublicMaker,
+		TypeJWTEdDSA:       jwtEdDSAMaker,
+	}
+	restriction := Restriction{
+		ClientID: "client",
+		Scopes:   []string{ScopeAccountsRead, ScopeTransfersWrite},
+		Audience: []string{"simple_bank"},
+		AuthTime: time.Now(),
+	}
+
+	for name, maker := range makers {
+		t.Run(name, func(t *testing.T) {
+			tokenString, payload, err := maker.CreateScopedToken("alice", testRole, restriction, time.Minute)
+			require.NoError(t, err)
+			require.True(t, payload.IsScoped())
+
+			verified, err := maker.VerifyToken(tokenString)
+			require.NoError(t, err)
+			require.Equal(t, "client", verified.ClientID)
+			require.Equal(t, restriction.Scopes, verified.Scopes)
+			require.True(t, verified.IsScoped())
+			require.True(t, verified.ForAudience("simple_bank"))
+			require.False(t, verified.ForAudience("other"))
+			require.WithinDuration(t, restriction.AuthTime, verified.AuthTime.Time, time.Second)
+
+			// 用户自己登录颁发的令牌没有授权范围
+			tokenString, payload, err = maker.CreateToken("alice", testRole, time.Minute)
+			require.NoError(t, err)
+			verified, err = maker.VerifyToken(tokenString)
+			require.NoError(t, err)
+			// 颁发时间保留到微秒, 撤销时可以区分同一秒内颁发的令牌
+			require.WithinDuration(t, payload.IssuedAt.Time, verified.IssuedAt.Time, time.Microsecond)
+			require.False(t, verified.IsScoped())
+			require.Empty(t, verified.Scopes)
+			require.Nil(t, verified.AuthTime)
+			require.True(t, verified.IsAccess())
+
+			// 刷新令牌记录用途, 不是访问令牌
+			tokenString, _, err = maker.CreateScopedToken("alice", testRole, Restriction{Purpose: PurposeRefresh}, time.Minute)
+			require.NoError(t, err)
+			verified, err = maker.VerifyToken(tokenString)
+			require.NoError(t, err)
+			require.True(t, verified.IsRefresh())
+			require.False(t, verified.IsAccess())
+			require.False(t, verified.IsScoped())
+		})
+	}
+}
+
+func TestPayloadHasScope(t *testing.T) {
+	payload, err := NewPayload(uuid.New(), "alice", testRole, time.Minute)
+	require.NoError(t, err)
+	// 不受限的令牌可以访问所有接口
+	require.True(t, payload.HasScope(ScopeTransfersWrite))
+	require.True(t, payload.ForAudience("simple_bank"))
+
+	// 只读令牌
+	payload, err = NewScopedPayload(uuid.New(), "alice", testRole, Restriction{Scopes: []string{ScopeAccountsRead}}, time.Minute)
+	require.NoError(t, err)
+	require.True(t, payload.IsScoped())
+	require.True(t, payload.HasScope(ScopeAccountsRead))
+	require.False(t, payload.HasScope(ScopeTransfersWrite))
+}
+
+func TestPayloadAuthenticatedWithin(t *testing.T) {
+	// 没有记录重新验证的时间
+	payload, err := NewPayload(uuid.New(), "alice", testRole, time.Minute)
+	require.NoError(t, err)
+	require.False(t, payload.AuthenticatedWithin(time.Hour))
+
+	restriction := Restriction{Scopes: []string{ScopeStepUp}, AuthTime: time.Now().Add(-2 * time.Minute)}
+	payload, err = NewScopedPayload(uuid.New(), "alice", testRole, restriction, time.Minute)
+	require.NoError(t, err)
+	require.True(t, payload.AuthenticatedWithin(5*time.Minute))
+	require.False(t, payload.AuthenticatedWithin(time.Minute))
+	require.False(t, payload.HasScope(ScopeTransfersWrite))
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 附录B的SHA1测试向量, 取后6位
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tc := range testCases {
+		code, err := Code(secret, Step(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tc.code, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 1)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// 允许一个时间步的时钟偏差
+	_, ok = Validate(secret, code, now.Add(Period), 1)
+	require.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(3*Period), 1)
+	require.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 1)
+	require.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 1)
+	require.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("SimpleBank", "alice", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/SimpleBank:alice", parsed.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	require.Equal(t, "SimpleBank", parsed.Query().Get("issuer"))
+	require.Equal(t, "6", parsed.Query().Get("digits"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		require.False(t, seen[code])
+		seen[code] = true
+		require.Equal(t, code, NormalizeRecoveryCode(" "+strings.ToUpper(code)+"\n"))
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	db "simple_bank/db/sqlc"
+)
+
+// 每次读取的审计事件数
+const verifyAuditBatchSize = 1000
+
+// verifyAudit 校验审计散列链, 输出第一个断开的链接, 返回进程的退出码: 0 完整, 1 断开, 2 校验出错
+func verifyAudit(ctx context.Context, store db.Store) int {
+	report, err := db.VerifyAuditChain(ctx, store, verifyAuditBatchSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verify audit chain: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("events:      %d\n", report.Events)
+	fmt.Printf("unchained:   %d\n", report.Unchained)
+	fmt.Printf("checkpoints: %d\n", report.Checkpoints)
+	if report.Broken != nil {
+		fmt.Printf("BROKEN at event %d: %s\n", report.Broken.EventID, report.Broken.Reason)
+		return 1
+	}
+	fmt.Printf("head:        event %d hash %x\n", report.HeadID, report.Head)
+	fmt.Println("OK")
+	return 0
+}